@@ -2,10 +2,10 @@ package acme
 
 import (
 	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
+	"crypto/ecdsa"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"reflect"
 	"sort"
@@ -24,6 +24,12 @@ type Account struct {
 	PrivateKey         []byte
 	DomainsCertificate DomainsCertificates
 	ChallengeCerts     map[string]*ChallengeCert
+	// HTTPChallenge holds the key authorizations of the HTTP-01 challenges in
+	// progress, by token and domain
+	HTTPChallenge map[string]map[string][]byte
+	// DomainsFailures records the failed certificate requests, by key type and
+	// domains
+	DomainsFailures map[string]*DomainFailure
 }
 
 // ChallengeCert stores a challenge certificate
@@ -59,10 +65,14 @@ func (a *Account) Init() error {
 	return nil
 }
 
-// NewAccount creates an account
-func NewAccount(email string) (*Account, error) {
+// NewAccount creates an account with a private key of the key type
+func NewAccount(email string, keyType acme.KeyType) (*Account, error) {
 	// Create a user. New accounts need an email and private key to start
-	privateKey, err := rsa.GenerateKey(rand.Reader, 4096)
+	privateKey, err := generatePrivateKey(keyType)
+	if err != nil {
+		return nil, err
+	}
+	privateKeyDER, err := marshalPrivateKey(privateKey)
 	if err != nil {
 		return nil, err
 	}
@@ -70,7 +80,7 @@ func NewAccount(email string) (*Account, error) {
 	domainsCerts.Init()
 	return &Account{
 		Email:              email,
-		PrivateKey:         x509.MarshalPKCS1PrivateKey(privateKey),
+		PrivateKey:         privateKeyDER,
 		DomainsCertificate: DomainsCertificates{Certs: domainsCerts.Certs},
 		ChallengeCerts:     map[string]*ChallengeCert{}}, nil
 }
@@ -90,6 +100,9 @@ func (a *Account) GetPrivateKey() crypto.PrivateKey {
 	if privateKey, err := x509.ParsePKCS1PrivateKey(a.PrivateKey); err == nil {
 		return privateKey
 	}
+	if privateKey, err := x509.ParseECPrivateKey(a.PrivateKey); err == nil {
+		return privateKey
+	}
 	log.Errorf("Cannot unmarshall private key %+v", a.PrivateKey)
 	return nil
 }
@@ -119,6 +132,9 @@ func (dc *DomainsCertificates) Swap(i, j int) {
 
 func (dc *DomainsCertificates) Less(i, j int) bool {
 	if reflect.DeepEqual(dc.Certs[i].Domains, dc.Certs[j].Domains) {
+		if dc.Certs[i].isECDSA() != dc.Certs[j].isECDSA() {
+			return !dc.Certs[i].isECDSA()
+		}
 		return dc.Certs[i].tlsCert.Leaf.NotAfter.After(dc.Certs[j].tlsCert.Leaf.NotAfter)
 	}
 	if dc.Certs[i].Domains.Main == dc.Certs[j].Domains.Main {
@@ -131,7 +147,7 @@ func (dc *DomainsCertificates) removeDuplicates() {
 	sort.Sort(dc)
 	for i := 0; i < len(dc.Certs); i++ {
 		for i2 := i + 1; i2 < len(dc.Certs); i2++ {
-			if reflect.DeepEqual(dc.Certs[i].Domains, dc.Certs[i2].Domains) {
+			if reflect.DeepEqual(dc.Certs[i].Domains, dc.Certs[i2].Domains) && dc.Certs[i].isECDSA() == dc.Certs[i2].isECDSA() {
 				// delete
 				log.Warnf("Remove duplicate cert: %+v, expiration :%s", dc.Certs[i2].Domains, dc.Certs[i2].tlsCert.Leaf.NotAfter.String())
 				dc.Certs = append(dc.Certs[:i2], dc.Certs[i2+1:]...)
@@ -167,12 +183,13 @@ func (dc *DomainsCertificates) renewCertificates(acmeCert *Certificate, domain D
 	dc.lock.Lock()
 	defer dc.lock.Unlock()
 
+	tlsCert, err := tls.X509KeyPair(acmeCert.Certificate, acmeCert.PrivateKey)
+	if err != nil {
+		return err
+	}
+	renewed := &DomainsCertificate{tlsCert: &tlsCert}
 	for _, domainsCertificate := range dc.Certs {
-		if reflect.DeepEqual(domain, domainsCertificate.Domains) {
-			tlsCert, err := tls.X509KeyPair(acmeCert.Certificate, acmeCert.PrivateKey)
-			if err != nil {
-				return err
-			}
+		if reflect.DeepEqual(domain, domainsCertificate.Domains) && domainsCertificate.isECDSA() == renewed.isECDSA() {
 			domainsCertificate.Certificate = acmeCert
 			domainsCertificate.tlsCert = &tlsCert
 			return nil
@@ -194,20 +211,50 @@ func (dc *DomainsCertificates) addCertificateForDomains(acmeCert *Certificate, d
 	return &cert, nil
 }
 
-func (dc *DomainsCertificates) getCertificateForDomain(domainToFind string) (*DomainsCertificate, bool) {
+// tlsCertificates returns the loaded certificates
+func (dc *DomainsCertificates) tlsCertificates() []*tls.Certificate {
 	dc.lock.RLock()
 	defer dc.lock.RUnlock()
+	var certificates []*tls.Certificate
+	for _, domainsCertificate := range dc.Certs {
+		if domainsCertificate.tlsCert != nil {
+			certificates = append(certificates, domainsCertificate.tlsCert)
+		}
+	}
+	return certificates
+}
+
+// getCertificateForDomain returns a certificate of the domain, the ECDSA one
+// if the client supports it and the RSA one otherwise when both exist
+func (dc *DomainsCertificates) getCertificateForDomain(domainToFind string, clientHello *tls.ClientHelloInfo) (*DomainsCertificate, bool) {
+	dc.lock.RLock()
+	defer dc.lock.RUnlock()
+	var rsaCertificate, ecdsaCertificate *DomainsCertificate
 	for _, domainsCertificate := range dc.Certs {
 		domains := []string{}
 		domains = append(domains, domainsCertificate.Domains.Main)
 		domains = append(domains, domainsCertificate.Domains.SANs...)
 		for _, domain := range domains {
 			if domain == domainToFind {
-				return domainsCertificate, true
+				if !domainsCertificate.isECDSA() && rsaCertificate == nil {
+					rsaCertificate = domainsCertificate
+				} else if domainsCertificate.isECDSA() && ecdsaCertificate == nil {
+					ecdsaCertificate = domainsCertificate
+				}
+				break
 			}
 		}
 	}
-	return nil, false
+	if ecdsaCertificate != nil && rsaCertificate == nil {
+		return ecdsaCertificate, true
+	}
+	// the ECDSA certificate can only be checked against the client once loaded
+	if ecdsaCertificate != nil && ecdsaCertificate.tlsCert != nil {
+		if privateKey, ok := ecdsaCertificate.tlsCert.PrivateKey.(*ecdsa.PrivateKey); ok && supportsECDSA(clientHello, privateKey.Curve) {
+			return ecdsaCertificate, true
+		}
+	}
+	return rsaCertificate, rsaCertificate != nil
 }
 
 func (dc *DomainsCertificates) exists(domainToFind Domain) (*DomainsCertificate, bool) {
@@ -221,13 +268,55 @@ func (dc *DomainsCertificates) exists(domainToFind Domain) (*DomainsCertificate,
 	return nil, false
 }
 
+// countOnDemandDomains returns the number of domains with certificates
+// obtained on demand
+func (dc *DomainsCertificates) countOnDemandDomains() int {
+	dc.lock.RLock()
+	defer dc.lock.RUnlock()
+	domains := map[string]bool{}
+	for _, domainsCertificate := range dc.Certs {
+		if domainsCertificate.OnDemand {
+			domains[domainsCertificate.Domains.Main] = true
+		}
+	}
+	return len(domains)
+}
+
+// existsWithKeyType returns whether a certificate of the domain exists with a
+// key of the same algorithm as the key type
+func (dc *DomainsCertificates) existsWithKeyType(domainToFind Domain, keyType acme.KeyType) bool {
+	dc.lock.RLock()
+	defer dc.lock.RUnlock()
+	for _, domainsCertificate := range dc.Certs {
+		if reflect.DeepEqual(domainToFind, domainsCertificate.Domains) && domainsCertificate.isECDSA() == (keyTypeCurve(keyType) != nil) {
+			return true
+		}
+	}
+	return false
+}
+
 // DomainsCertificate contains a certificate for multiple domains
 type DomainsCertificate struct {
 	Domains     Domain
 	Certificate *Certificate
+	OnDemand    bool // obtained during a TLS handshake
 	tlsCert     *tls.Certificate
 }
 
+// isECDSA returns whether the certificate has an ECDSA key
+func (dc *DomainsCertificate) isECDSA() bool {
+	if dc.tlsCert == nil {
+		// not loaded yet, look at the stored key
+		if dc.Certificate == nil {
+			return false
+		}
+		block, _ := pem.Decode(dc.Certificate.PrivateKey)
+		return block != nil && block.Type == "EC PRIVATE KEY"
+	}
+	_, ok := dc.tlsCert.PrivateKey.(*ecdsa.PrivateKey)
+	return ok
+}
+
 func (dc *DomainsCertificate) needRenew() bool {
 	for _, c := range dc.tlsCert.Certificate {
 		crt, err := x509.ParseCertificate(c)
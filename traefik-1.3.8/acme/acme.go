@@ -10,6 +10,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/BurntSushi/ty/fun"
@@ -24,31 +25,44 @@ import (
 	"github.com/xenolf/lego/providers/dns"
 )
 
-var (
-	// OSCPMustStaple enables OSCP stapling as from https://github.com/xenolf/lego/issues/270
-	OSCPMustStaple = false
-)
-
 // ACME allows to connect to lets encrypt and retrieve certs
 type ACME struct {
-	Email               string   `description:"Email address used for registration"`
-	Domains             []Domain `description:"SANs (alternative domains) to each main domain using format: --acme.domains='main.com,san1.com,san2.com' --acme.domains='main.net,san1.net,san2.net'"`
-	Storage             string   `description:"File or key used for certificates storage."`
-	StorageFile         string   // deprecated
-	OnDemand            bool     `description:"Enable on demand certificate. This will request a certificate from Let's Encrypt during the first TLS handshake for a hostname that does not yet have a certificate."`
-	OnHostRule          bool     `description:"Enable certificate generation on frontends Host rules."`
-	CAServer            string   `description:"CA server to use."`
-	EntryPoint          string   `description:"Entrypoint to proxy acme challenge to."`
-	DNSProvider         string   `description:"Use a DNS based challenge provider rather than HTTPS."`
-	DelayDontCheckDNS   int      `description:"Assume DNS propagates after a delay in seconds rather than finding and querying nameservers."`
-	ACMELogging         bool     `description:"Enable debug logging of ACME actions."`
-	client              *acme.Client
-	defaultCertificate  *tls.Certificate
-	store               cluster.Store
-	challengeProvider   *challengeProvider
-	checkOnDemandDomain func(domain string) bool
-	jobs                *channels.InfiniteChannel
-	TLSConfig           *tls.Config `description:"TLS config in case wildcard certs are used"`
+	Email                 string         `description:"Email address used for registration"`
+	Domains               []Domain       `description:"SANs (alternative domains) to each main domain using format: --acme.domains='main.com,san1.com,san2.com' --acme.domains='main.net,san1.net,san2.net'"`
+	Storage               string         `description:"File or key used for certificates storage."`
+	StorageFile           string         // deprecated
+	OnDemand              bool           `description:"Enable on demand certificate. This will request a certificate from Let's Encrypt during the first TLS handshake for a hostname that does not yet have a certificate."`
+	OnDemandAsk           string         `description:"URL queried with the domain before obtaining an on demand certificate, answering 2xx to allow it."`
+	OnDemandDomains       []string       // domain globs allowed for the on demand certificates, all by default, TOML only
+	OnDemandMax           int            `description:"Maximum number of domains with on demand certificates, unlimited by default."`
+	OnHostRule            bool           `description:"Enable certificate generation on frontends Host rules."`
+	CAServer              string         `description:"CA server to use."`
+	EntryPoint            string         `description:"Entrypoint to proxy acme challenge to."`
+	DNSProvider           string         `description:"Use a DNS based challenge provider rather than HTTPS."`
+	HTTPChallenge         *HTTPChallenge `description:"Use the HTTP-01 challenge on an HTTP entrypoint rather than TLS-SNI-01."`
+	DelayDontCheckDNS     int            `description:"Assume DNS propagates after a delay in seconds rather than finding and querying nameservers."`
+	ACMELogging           bool           `description:"Enable debug logging of ACME actions."`
+	KeyType               string         `description:"Key type of the account and certificates: RSA2048, RSA4096, EC256 or EC384 (RSA4096 by default)."`
+	DualCertificates      bool           `description:"Obtain both an RSA and an ECDSA certificate per domain, serving the ECDSA one to the clients supporting it."`
+	client                *acme.Client
+	defaultCertificate    *tls.Certificate
+	store                 cluster.Store
+	challengeProvider     *challengeProvider
+	challengeHTTPProvider *challengeHTTPProvider
+	checkOnDemandDomain   func(domain string) bool
+	mustStaple            bool
+	jobs                  *channels.InfiniteChannel
+	keyTypes              []acme.KeyType
+	pendingDomains        map[string]time.Time
+	reservedOnDemand      map[string]int
+	onDemandAnswers       map[string]*onDemandAnswer
+	domainsLock           sync.RWMutex
+	TLSConfig             *tls.Config `description:"TLS config in case wildcard certs are used"`
+}
+
+// HTTPChallenge contains the HTTP-01 challenge configuration
+type HTTPChallenge struct {
+	EntryPoint string `description:"HTTP entrypoint answering the HTTP-01 challenges"`
 }
 
 //Domains parse []Domain
@@ -98,8 +112,21 @@ func (a *ACME) init() error {
 	} else {
 		acme.Logger = fmtlog.New(ioutil.Discard, "", 0)
 	}
+	keyType, err := parseKeyType(a.KeyType)
+	if err != nil {
+		return err
+	}
+	a.keyTypes = []acme.KeyType{keyType}
+	if a.DualCertificates {
+		// the other key type of the dual certificates
+		if keyTypeCurve(keyType) != nil {
+			a.keyTypes = append(a.keyTypes, acme.RSA2048)
+		} else {
+			a.keyTypes = append(a.keyTypes, acme.EC256)
+		}
+	}
 	// no certificates in TLS config, so we add a default one
-	cert, err := generateDefaultCertificate()
+	cert, err := generateDefaultCertificate(keyType)
 	if err != nil {
 		return err
 	}
@@ -113,8 +140,10 @@ func (a *ACME) init() error {
 	return nil
 }
 
-// CreateClusterConfig creates a tls.config using ACME configuration in cluster mode
-func (a *ACME) CreateClusterConfig(leadership *cluster.Leadership, tlsConfig *tls.Config, checkOnDemandDomain func(domain string) bool) error {
+// CreateClusterConfig creates a tls.config using ACME configuration in cluster
+// mode. The certificates are requested with the OCSP must staple extension
+// when mustStaple is set.
+func (a *ACME) CreateClusterConfig(leadership *cluster.Leadership, tlsConfig *tls.Config, checkOnDemandDomain func(domain string) bool, mustStaple bool) error {
 	err := a.init()
 	if err != nil {
 		return err
@@ -123,6 +152,7 @@ func (a *ACME) CreateClusterConfig(leadership *cluster.Leadership, tlsConfig *tl
 		return errors.New("Empty Store, please provide a key for certs storage")
 	}
 	a.checkOnDemandDomain = checkOnDemandDomain
+	a.mustStaple = mustStaple
 	tlsConfig.Certificates = append(tlsConfig.Certificates, *a.defaultCertificate)
 	tlsConfig.GetCertificate = a.getCertificate
 	a.TLSConfig = tlsConfig
@@ -152,6 +182,7 @@ func (a *ACME) CreateClusterConfig(leadership *cluster.Leadership, tlsConfig *tl
 
 	a.store = datastore
 	a.challengeProvider = &challengeProvider{store: a.store}
+	a.challengeHTTPProvider = &challengeHTTPProvider{store: a.store}
 
 	ticker := time.NewTicker(24 * time.Hour)
 	leadership.Pool.AddGoCtx(func(ctx context.Context) {
@@ -181,7 +212,7 @@ func (a *ACME) CreateClusterConfig(leadership *cluster.Leadership, tlsConfig *tl
 			account.Init()
 			var needRegister bool
 			if account == nil || len(account.Email) == 0 {
-				account, err = NewAccount(a.Email)
+				account, err = NewAccount(a.Email, a.keyTypes[0])
 				if err != nil {
 					return err
 				}
@@ -233,8 +264,10 @@ func (a *ACME) CreateClusterConfig(leadership *cluster.Leadership, tlsConfig *tl
 	return nil
 }
 
-// CreateLocalConfig creates a tls.config using local ACME configuration
-func (a *ACME) CreateLocalConfig(tlsConfig *tls.Config, checkOnDemandDomain func(domain string) bool) error {
+// CreateLocalConfig creates a tls.config using local ACME configuration. The
+// certificates are requested with the OCSP must staple extension when
+// mustStaple is set.
+func (a *ACME) CreateLocalConfig(tlsConfig *tls.Config, checkOnDemandDomain func(domain string) bool, mustStaple bool) error {
 	err := a.init()
 	if err != nil {
 		return err
@@ -243,12 +276,14 @@ func (a *ACME) CreateLocalConfig(tlsConfig *tls.Config, checkOnDemandDomain func
 		return errors.New("Empty Store, please provide a filename for certs storage")
 	}
 	a.checkOnDemandDomain = checkOnDemandDomain
+	a.mustStaple = mustStaple
 	tlsConfig.Certificates = append(tlsConfig.Certificates, *a.defaultCertificate)
 	tlsConfig.GetCertificate = a.getCertificate
 	a.TLSConfig = tlsConfig
 	localStore := NewLocalStore(a.Storage)
 	a.store = localStore
 	a.challengeProvider = &challengeProvider{store: a.store}
+	a.challengeHTTPProvider = &challengeHTTPProvider{store: a.store}
 
 	var needRegister bool
 	var account *Account
@@ -263,7 +298,7 @@ func (a *ACME) CreateLocalConfig(tlsConfig *tls.Config, checkOnDemandDomain func
 		account = object.(*Account)
 	} else {
 		log.Infof("Generating ACME Account...")
-		account, err = NewAccount(a.Email)
+		account, err = NewAccount(a.Email, a.keyTypes[0])
 		if err != nil {
 			return err
 		}
@@ -337,7 +372,7 @@ func (a *ACME) getCertificate(clientHello *tls.ClientHelloInfo) (*tls.Certificat
 		log.Debugf("ACME got challenge %s", domain)
 		return challengeCert, nil
 	}
-	if domainCert, ok := account.DomainsCertificate.getCertificateForDomain(domain); ok {
+	if domainCert, ok := account.DomainsCertificate.getCertificateForDomain(domain, clientHello); ok {
 		log.Debugf("ACME got domain cert %s", domain)
 		return domainCert.tlsCert, nil
 	}
@@ -345,23 +380,42 @@ func (a *ACME) getCertificate(clientHello *tls.ClientHelloInfo) (*tls.Certificat
 		if a.checkOnDemandDomain != nil && !a.checkOnDemandDomain(domain) {
 			return nil, nil
 		}
+		// domains backing off are refused before querying the OnDemandAsk URL
+		if err := a.checkDomainFailure([]string{domain}, a.clientKeyType(clientHello)); err != nil {
+			return nil, err
+		}
+		if err := a.authorizeOnDemandDomain(domain); err != nil {
+			log.Debugf("ACME on demand certificate refused: %v", err)
+			return nil, nil
+		}
+		defer a.releaseOnDemandDomain(domain)
 		return a.loadCertificateOnDemand(clientHello)
 	}
 	log.Debugf("ACME got nothing %s", domain)
 	return nil, nil
 }
 
+// GetHTTPChallengeToken returns the key authorization answering the HTTP-01
+// challenge of the token for the domain
+func (a *ACME) GetHTTPChallengeToken(token, domain string) ([]byte, bool) {
+	if a.challengeHTTPProvider == nil {
+		return nil, false
+	}
+	keyAuth := a.challengeHTTPProvider.getTokenValue(token, domain)
+	return keyAuth, keyAuth != nil
+}
+
 func (a *ACME) retrieveCertificates() {
 	a.jobs.In() <- func() {
 		log.Infof("Retrieving ACME certificates...")
 		for _, domain := range a.Domains {
 			// check if cert isn't already loaded
 			account := a.store.Get().(*Account)
-			if _, exists := account.DomainsCertificate.exists(domain); !exists {
+			for _, keyType := range a.missingKeyTypes(account, domain) {
 				domains := []string{}
 				domains = append(domains, domain.Main)
 				domains = append(domains, domain.SANs...)
-				certificateResource, err := a.getDomainsCertificates(domains)
+				certificateResource, err := a.getDomainsCertificates(domains, keyType)
 				if err != nil {
 					log.Errorf("Error getting ACME certificate for domain %s: %s", domains, err.Error())
 					continue
@@ -401,7 +455,7 @@ func (a *ACME) renewCertificates() {
 					CertStableURL: certificateResource.Certificate.CertStableURL,
 					PrivateKey:    certificateResource.Certificate.PrivateKey,
 					Certificate:   certificateResource.Certificate.Certificate,
-				}, true, OSCPMustStaple)
+				}, true, a.mustStaple)
 				if err != nil {
 					log.Errorf("Error renewing certificate: %v", err)
 					continue
@@ -455,7 +509,11 @@ func (a *ACME) buildACMEClient(account *Account) (*acme.Client, error) {
 	if len(a.CAServer) > 0 {
 		caServer = a.CAServer
 	}
-	client, err := acme.NewClient(caServer, account, acme.RSA4096)
+	keyType, err := parseKeyType(a.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	client, err := acme.NewClient(caServer, account, keyType)
 	if err != nil {
 		return nil, err
 	}
@@ -476,6 +534,10 @@ func (a *ACME) buildACMEClient(account *Account) (*acme.Client, error) {
 
 		client.ExcludeChallenges([]acme.Challenge{acme.HTTP01, acme.TLSSNI01})
 		err = client.SetChallengeProvider(acme.DNS01, provider)
+	} else if a.HTTPChallenge != nil && len(a.HTTPChallenge.EntryPoint) > 0 {
+		log.Debugf("Using HTTP Challenge provider on entrypoint %s", a.HTTPChallenge.EntryPoint)
+		client.ExcludeChallenges([]acme.Challenge{acme.DNS01, acme.TLSSNI01})
+		err = client.SetChallengeProvider(acme.HTTP01, a.challengeHTTPProvider)
 	} else {
 		client.ExcludeChallenges([]acme.Challenge{acme.HTTP01, acme.DNS01})
 		err = client.SetChallengeProvider(acme.TLSSNI01, a.challengeProvider)
@@ -490,10 +552,12 @@ func (a *ACME) buildACMEClient(account *Account) (*acme.Client, error) {
 func (a *ACME) loadCertificateOnDemand(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	domain := types.CanonicalDomain(clientHello.ServerName)
 	account := a.store.Get().(*Account)
-	if certificateResource, ok := account.DomainsCertificate.getCertificateForDomain(domain); ok {
+	if certificateResource, ok := account.DomainsCertificate.getCertificateForDomain(domain, clientHello); ok {
 		return certificateResource.tlsCert, nil
 	}
-	certificate, err := a.getDomainsCertificates([]string{domain})
+	// the certificate supported by the client is obtained first, the other
+	// dual certificate afterwards
+	certificate, err := a.getDomainsCertificates([]string{domain}, a.clientKeyType(clientHello))
 	if err != nil {
 		return nil, err
 	}
@@ -508,12 +572,28 @@ func (a *ACME) loadCertificateOnDemand(clientHello *tls.ClientHelloInfo) (*tls.C
 	if err != nil {
 		return nil, err
 	}
+	cert.OnDemand = true
 	if err = transaction.Commit(account); err != nil {
 		return nil, err
 	}
+	if len(a.keyTypes) > 1 {
+		a.LoadCertificateForDomains([]string{domain})
+	}
 	return cert.tlsCert, nil
 }
 
+// Certificates returns the ACME certificates served by the entrypoint
+func (a *ACME) Certificates() []*tls.Certificate {
+	if a.store == nil {
+		return nil
+	}
+	account, ok := a.store.Get().(*Account)
+	if !ok || account == nil {
+		return nil
+	}
+	return account.DomainsCertificate.tlsCertificates()
+}
+
 // LoadCertificateForDomains loads certificates from ACME for given domains
 func (a *ACME) LoadCertificateForDomains(domains []string) {
 	a.jobs.In() <- func() {
@@ -554,31 +634,29 @@ func (a *ACME) LoadCertificateForDomains(domains []string) {
 		} else {
 			domain = Domain{Main: domains[0]}
 		}
-		if _, exists := account.DomainsCertificate.exists(domain); exists {
-			// domain already exists
-			return
-		}
-		certificate, err := a.getDomainsCertificates(domains)
-		if err != nil {
-			log.Errorf("Error getting ACME certificates %+v : %v", domains, err)
-			return
-		}
-		log.Debugf("Got certificate for domains %+v", domains)
-		transaction, object, err := a.store.Begin()
+		for _, keyType := range a.missingKeyTypes(account, domain) {
+			certificate, err := a.getDomainsCertificates(domains, keyType)
+			if err != nil {
+				log.Errorf("Error getting ACME certificates %+v : %v", domains, err)
+				return
+			}
+			log.Debugf("Got certificate for domains %+v", domains)
+			transaction, object, err := a.store.Begin()
 
-		if err != nil {
-			log.Errorf("Error creating transaction %+v : %v", domains, err)
-			return
-		}
-		account = object.(*Account)
-		_, err = account.DomainsCertificate.addCertificateForDomains(certificate, domain)
-		if err != nil {
-			log.Errorf("Error adding ACME certificates %+v : %v", domains, err)
-			return
-		}
-		if err = transaction.Commit(account); err != nil {
-			log.Errorf("Error Saving ACME account %+v: %v", account, err)
-			return
+			if err != nil {
+				log.Errorf("Error creating transaction %+v : %v", domains, err)
+				return
+			}
+			account = object.(*Account)
+			_, err = account.DomainsCertificate.addCertificateForDomains(certificate, domain)
+			if err != nil {
+				log.Errorf("Error adding ACME certificates %+v : %v", domains, err)
+				return
+			}
+			if err = transaction.Commit(account); err != nil {
+				log.Errorf("Error Saving ACME account %+v: %v", account, err)
+				return
+			}
 		}
 	}
 }
@@ -606,15 +684,62 @@ func (a *ACME) getProvidedCertificate(domains []string) *tls.Certificate {
 	return nil
 }
 
-func (a *ACME) getDomainsCertificates(domains []string) (*Certificate, error) {
+// clientKeyType returns the key type of the certificate to obtain for the
+// client, preferring ECDSA when it supports it
+func (a *ACME) clientKeyType(clientHello *tls.ClientHelloInfo) acme.KeyType {
+	for _, keyType := range a.keyTypes {
+		if curve := keyTypeCurve(keyType); curve != nil && supportsECDSA(clientHello, curve) {
+			return keyType
+		}
+	}
+	for _, keyType := range a.keyTypes {
+		if keyTypeCurve(keyType) == nil {
+			return keyType
+		}
+	}
+	return a.keyTypes[0]
+}
+
+// missingKeyTypes returns the key types of the certificates to obtain for the
+// domain: all of them without certificate, and the missing dual certificate
+func (a *ACME) missingKeyTypes(account *Account, domain Domain) []acme.KeyType {
+	if len(a.keyTypes) < 2 {
+		if _, exists := account.DomainsCertificate.exists(domain); exists {
+			return nil
+		}
+		return a.keyTypes
+	}
+	var missing []acme.KeyType
+	for _, keyType := range a.keyTypes {
+		if !account.DomainsCertificate.existsWithKeyType(domain, keyType) {
+			missing = append(missing, keyType)
+		}
+	}
+	return missing
+}
+
+func (a *ACME) getDomainsCertificates(domains []string, keyType acme.KeyType) (*Certificate, error) {
 	domains = fun.Map(types.CanonicalDomain, domains).([]string)
-	log.Debugf("Loading ACME certificates %s...", domains)
+	if err := a.checkDomainFailure(domains, keyType); err != nil {
+		return nil, err
+	}
+	if !a.startPending(domains) {
+		return nil, fmt.Errorf("ACME certificates %s already being obtained", domains)
+	}
+	defer a.stopPending(domains)
+	log.Debugf("Loading ACME %s certificates %s...", keyType, domains)
+	privateKey, err := generatePrivateKey(keyType)
+	if err != nil {
+		return nil, err
+	}
 	bundle := true
-	certificate, failures := a.client.ObtainCertificate(domains, bundle, nil, OSCPMustStaple)
+	certificate, failures := a.client.ObtainCertificate(domains, bundle, privateKey, a.mustStaple)
 	if len(failures) > 0 {
 		log.Error(failures)
-		return nil, fmt.Errorf("Cannot obtain certificates %s+v", failures)
+		a.updateDomainFailure(domains, keyType, obtainError(failures))
+		return nil, obtainError(failures)
 	}
+	a.updateDomainFailure(domains, keyType, nil)
 	log.Debugf("Loaded ACME certificates %s", domains)
 	return &Certificate{
 		Domain:        certificate.Domain,
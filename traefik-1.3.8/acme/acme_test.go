@@ -1,10 +1,16 @@
 package acme
 
 import (
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"crypto/tls"
 	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"reflect"
 	"sync"
 	"testing"
@@ -70,8 +76,8 @@ func TestDomainsSetAppend(t *testing.T) {
 }
 
 func TestCertificatesRenew(t *testing.T) {
-	foo1Cert, foo1Key, _ := generateKeyPair("foo1.com", time.Now())
-	foo2Cert, foo2Key, _ := generateKeyPair("foo2.com", time.Now())
+	foo1Cert, foo1Key, _ := generateKeyPair("foo1.com", time.Now(), acme.RSA2048)
+	foo2Cert, foo2Key, _ := generateKeyPair("foo2.com", time.Now(), acme.RSA2048)
 	domainsCertificates := DomainsCertificates{
 		lock: sync.RWMutex{},
 		Certs: []*DomainsCertificate{
@@ -101,7 +107,7 @@ func TestCertificatesRenew(t *testing.T) {
 			},
 		},
 	}
-	foo1Cert, foo1Key, _ = generateKeyPair("foo1.com", time.Now())
+	foo1Cert, foo1Key, _ = generateKeyPair("foo1.com", time.Now(), acme.RSA2048)
 	newCertificate := &Certificate{
 		Domain:        "foo1.com",
 		CertURL:       "url",
@@ -128,10 +134,10 @@ func TestCertificatesRenew(t *testing.T) {
 
 func TestRemoveDuplicates(t *testing.T) {
 	now := time.Now()
-	fooCert, fooKey, _ := generateKeyPair("foo.com", now)
-	foo24Cert, foo24Key, _ := generateKeyPair("foo.com", now.Add(24*time.Hour))
-	foo48Cert, foo48Key, _ := generateKeyPair("foo.com", now.Add(48*time.Hour))
-	barCert, barKey, _ := generateKeyPair("bar.com", now)
+	fooCert, fooKey, _ := generateKeyPair("foo.com", now, acme.RSA2048)
+	foo24Cert, foo24Key, _ := generateKeyPair("foo.com", now.Add(24*time.Hour), acme.RSA2048)
+	foo48Cert, foo48Key, _ := generateKeyPair("foo.com", now.Add(48*time.Hour), acme.RSA2048)
+	barCert, barKey, _ := generateKeyPair("bar.com", now, acme.RSA2048)
 	domainsCertificates := DomainsCertificates{
 		lock: sync.RWMutex{},
 		Certs: []*DomainsCertificate{
@@ -217,6 +223,100 @@ func TestRemoveDuplicates(t *testing.T) {
 	}
 }
 
+func TestParseKeyType(t *testing.T) {
+	for name, expected := range map[string]acme.KeyType{
+		"":        acme.RSA4096,
+		"RSA2048": acme.RSA2048,
+		"rsa4096": acme.RSA4096,
+		"EC256":   acme.EC256,
+		"EC384":   acme.EC384,
+	} {
+		keyType, err := parseKeyType(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, expected, keyType, name)
+	}
+	_, err := parseKeyType("DSA1024")
+	assert.Error(t, err)
+}
+
+func TestNewAccountKeyType(t *testing.T) {
+	account, err := NewAccount("f@f", acme.EC256)
+	assert.NoError(t, err)
+	assert.IsType(t, &ecdsa.PrivateKey{}, account.GetPrivateKey())
+
+	account, err = NewAccount("f@f", acme.RSA2048)
+	assert.NoError(t, err)
+	assert.IsType(t, &rsa.PrivateKey{}, account.GetPrivateKey())
+}
+
+func TestDualCertificates(t *testing.T) {
+	rsaCert, rsaKey, err := generateKeyPair("foo.com", time.Now().Add(time.Hour), acme.RSA2048)
+	assert.NoError(t, err)
+	ecdsaCert, ecdsaKey, err := generateKeyPair("foo.com", time.Now().Add(time.Hour), acme.EC256)
+	assert.NoError(t, err)
+	domain := Domain{Main: "foo.com"}
+
+	a := ACME{DualCertificates: true}
+	assert.NoError(t, a.init())
+	assert.Equal(t, []acme.KeyType{acme.RSA4096, acme.EC256}, a.keyTypes)
+
+	account := &Account{DomainsCertificate: DomainsCertificates{Certs: []*DomainsCertificate{}}}
+	assert.Equal(t, []acme.KeyType{acme.RSA4096, acme.EC256}, a.missingKeyTypes(account, domain))
+	_, err = account.DomainsCertificate.addCertificateForDomains(&Certificate{Domain: "foo.com", PrivateKey: rsaKey, Certificate: rsaCert}, domain)
+	assert.NoError(t, err)
+	assert.Equal(t, []acme.KeyType{acme.EC256}, a.missingKeyTypes(account, domain))
+	_, err = account.DomainsCertificate.addCertificateForDomains(&Certificate{Domain: "foo.com", PrivateKey: ecdsaKey, Certificate: ecdsaCert}, domain)
+	assert.NoError(t, err)
+	assert.Empty(t, a.missingKeyTypes(account, domain))
+
+	// both certificates are kept
+	assert.NoError(t, account.DomainsCertificate.Init())
+	assert.Len(t, account.DomainsCertificate.Certs, 2)
+
+	ecdsaClient := &tls.ClientHelloInfo{
+		CipherSuites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		SupportedCurves:  []tls.CurveID{tls.X25519, tls.CurveP256},
+		SignatureSchemes: []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256, tls.PKCS1WithSHA256},
+	}
+	rsaClient := &tls.ClientHelloInfo{
+		CipherSuites:     []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		SupportedCurves:  []tls.CurveID{tls.CurveP256},
+		SignatureSchemes: []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256, tls.PKCS1WithSHA256},
+	}
+	noECDSASchemeClient := &tls.ClientHelloInfo{
+		CipherSuites:     []uint16{tls.TLS_AES_128_GCM_SHA256},
+		SignatureSchemes: []tls.SignatureScheme{tls.PSSWithSHA256},
+	}
+	for desc, test := range map[string]struct {
+		clientHello *tls.ClientHelloInfo
+		ecdsa       bool
+	}{
+		"ECDSA client":              {clientHello: ecdsaClient, ecdsa: true},
+		"RSA cipher suites":         {clientHello: rsaClient},
+		"RSA signature schemes":     {clientHello: noECDSASchemeClient},
+		"TLS 1.3 client":            {clientHello: &tls.ClientHelloInfo{CipherSuites: []uint16{tls.TLS_AES_128_GCM_SHA256}}, ecdsa: true},
+		"unsupported curve":         {clientHello: &tls.ClientHelloInfo{CipherSuites: []uint16{tls.TLS_AES_128_GCM_SHA256}, SupportedCurves: []tls.CurveID{tls.CurveP384}}},
+		"without client hello info": {},
+	} {
+		certificate, ok := account.DomainsCertificate.getCertificateForDomain("foo.com", test.clientHello)
+		assert.True(t, ok, desc)
+		assert.Equal(t, test.ecdsa, certificate.isECDSA(), desc)
+		if test.clientHello != nil {
+			assert.Equal(t, test.ecdsa, keyTypeCurve(a.clientKeyType(test.clientHello)) != nil, desc)
+		}
+	}
+
+	// the ECDSA certificate which isn't loaded falls back to the RSA one
+	for _, certificate := range account.DomainsCertificate.Certs {
+		if certificate.isECDSA() {
+			certificate.tlsCert = nil
+		}
+	}
+	certificate, ok := account.DomainsCertificate.getCertificateForDomain("foo.com", ecdsaClient)
+	assert.True(t, ok)
+	assert.False(t, certificate.isECDSA())
+}
+
 func TestNoPreCheckOverride(t *testing.T) {
 	acme.PreCheckDNS = nil // Irreversable - but not expecting real calls into this during testing process
 	err := dnsOverrideDelay(0)
@@ -280,6 +380,68 @@ cijFkALeQp/qyeXdFld2v9gUN3eCgljgcl0QweRoIc=---`)
 	}
 }
 
+func TestAcmeClientCreationWithHTTPChallenge(t *testing.T) {
+	account, err := NewAccount("f@f", acme.RSA2048)
+	assert.NoError(t, err)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+"new-authz": "https://foo/acme/new-authz",
+"new-cert": "https://foo/acme/new-cert",
+"new-reg": "https://foo/acme/new-reg",
+"revoke-cert": "https://foo/acme/revoke-cert"
+}`))
+	}))
+	defer ts.Close()
+	a := ACME{HTTPChallenge: &HTTPChallenge{EntryPoint: "http"}, CAServer: ts.URL}
+
+	client, err := a.buildACMEClient(account)
+	assert.NoError(t, err)
+	assert.NotNil(t, client)
+}
+
+func TestHTTPChallenge(t *testing.T) {
+	file, err := ioutil.TempFile("", "acme")
+	assert.NoError(t, err)
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store := NewLocalStore(file.Name())
+	account, err := NewAccount("f@f", acme.RSA2048)
+	assert.NoError(t, err)
+	transaction, _, err := store.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, transaction.Commit(account))
+
+	a := ACME{}
+	_, ok := a.GetHTTPChallengeToken("token", "foo.com")
+	assert.False(t, ok)
+
+	a.challengeHTTPProvider = &challengeHTTPProvider{store: store}
+	assert.NoError(t, a.challengeHTTPProvider.Present("foo.com", "token", "token.key"))
+	assert.NoError(t, a.challengeHTTPProvider.Present("Bar.com", "token", "token.bar"))
+	keyAuth, ok := a.GetHTTPChallengeToken("token", "FOO.com")
+	assert.True(t, ok)
+	assert.Equal(t, "token.key", string(keyAuth))
+	keyAuth, ok = a.GetHTTPChallengeToken("token", "bar.com")
+	assert.True(t, ok)
+	assert.Equal(t, "token.bar", string(keyAuth))
+
+	// the unknown tokens are answered right away
+	start := time.Now()
+	_, ok = a.GetHTTPChallengeToken("other", "foo.com")
+	assert.False(t, ok)
+	assert.True(t, time.Since(start) < time.Second)
+
+	// the challenges are shared through the store
+	loaded, err := NewLocalStore(file.Name()).Load()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("token.bar"), loaded.(*Account).HTTPChallenge["token"]["bar.com"])
+
+	assert.NoError(t, a.challengeHTTPProvider.CleanUp("foo.com", "token", "token.key"))
+	assert.NoError(t, a.challengeHTTPProvider.CleanUp("BAR.com", "token", "token.bar"))
+	assert.Empty(t, store.Get().(*Account).HTTPChallenge)
+}
+
 func TestAcme_getProvidedCertificate(t *testing.T) {
 	mm := make(map[string]*tls.Certificate)
 	mm["*.containo.us"] = &tls.Certificate{}
@@ -294,3 +456,177 @@ func TestAcme_getProvidedCertificate(t *testing.T) {
 	certificate = a.getProvidedCertificate(domains)
 	assert.Nil(t, certificate)
 }
+
+func TestNextAttemptDelay(t *testing.T) {
+	assert.Equal(t, 5*time.Minute, nextAttemptDelay(1, false))
+	assert.Equal(t, 10*time.Minute, nextAttemptDelay(2, false))
+	assert.Equal(t, 40*time.Minute, nextAttemptDelay(4, false))
+	assert.Equal(t, 24*time.Hour, nextAttemptDelay(20, false))
+	assert.Equal(t, 3*time.Hour, nextAttemptDelay(1, true))
+	assert.Equal(t, 24*time.Hour, nextAttemptDelay(20, true))
+}
+
+func TestIsRateLimited(t *testing.T) {
+	rateLimited := acme.RemoteError{StatusCode: http.StatusTooManyRequests, Type: "urn:acme:error:rateLimited", Detail: "too many certificates already issued"}
+	assert.True(t, isRateLimited(rateLimited))
+	assert.True(t, isRateLimited(obtainError{"foo.com": rateLimited}))
+	assert.True(t, isRateLimited(fmt.Errorf("%v\nError Detail:\n", rateLimited)))
+	assert.False(t, isRateLimited(obtainError{"foo.com": acme.RemoteError{StatusCode: http.StatusForbidden, Type: "urn:acme:error:unauthorized"}}))
+	assert.False(t, isRateLimited(errors.New("connection refused")))
+}
+
+func TestDomainFailures(t *testing.T) {
+	file, err := ioutil.TempFile("", "acme")
+	assert.NoError(t, err)
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store := NewLocalStore(file.Name())
+	account, err := NewAccount("f@f", acme.RSA2048)
+	assert.NoError(t, err)
+	transaction, _, err := store.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, transaction.Commit(account))
+
+	a := ACME{store: store}
+	domains := []string{"foo.com", "www.foo.com"}
+	assert.NoError(t, a.checkDomainFailure(domains, acme.RSA2048))
+
+	a.updateDomainFailure(domains, acme.RSA2048, obtainError{"foo.com": errors.New("invalid response")})
+	err = a.checkDomainFailure(domains, acme.RSA2048)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "last error: Cannot obtain certificates map[foo.com:invalid response]")
+	assert.NoError(t, a.checkDomainFailure([]string{"foo.com"}, acme.RSA2048))
+	assert.NoError(t, a.checkDomainFailure(domains, acme.EC256))
+
+	a.updateDomainFailure(domains, acme.RSA2048, acme.RemoteError{StatusCode: http.StatusTooManyRequests, Type: "urn:acme:error:rateLimited"})
+	failure, ok := store.Get().(*Account).getDomainFailure(domains, acme.RSA2048, time.Now())
+	assert.True(t, ok)
+	assert.Equal(t, 2, failure.Failures)
+	assert.True(t, failure.RateLimited)
+	assert.WithinDuration(t, time.Now().Add(3*time.Hour), failure.NextAttempt, time.Minute)
+
+	// the failures are shared through the store
+	loaded, err := NewLocalStore(file.Name()).Load()
+	assert.NoError(t, err)
+	failure, ok = loaded.(*Account).getDomainFailure(domains, acme.RSA2048, time.Now())
+	assert.True(t, ok)
+	assert.Equal(t, 2, failure.Failures)
+
+	// the domains are retried after the backoff
+	failure.NextAttempt = time.Now().Add(-time.Second)
+	assert.NoError(t, (&ACME{store: &LocalStore{account: loaded.(*Account)}}).checkDomainFailure(domains, acme.RSA2048))
+
+	assert.True(t, a.startPending([]string{"bar.com"}))
+	assert.False(t, a.startPending([]string{"bar.com"}))
+	statuses := a.DomainsStatuses()
+	assert.Len(t, statuses, 2)
+	assert.Equal(t, "pending", statuses[0].Status)
+	assert.Equal(t, []string{"bar.com"}, statuses[0].Domains)
+	assert.Equal(t, "failed", statuses[1].Status)
+	assert.Equal(t, domains, statuses[1].Domains)
+	assert.Equal(t, "RSA2048", statuses[1].KeyType)
+	assert.Equal(t, 2, statuses[1].Failures)
+	assert.True(t, statuses[1].RateLimited)
+	a.stopPending([]string{"bar.com"})
+
+	// a success only clears the failures of its key type
+	a.updateDomainFailure(domains, acme.EC256, obtainError{"foo.com": errors.New("invalid response")})
+	a.updateDomainFailure(domains, acme.RSA2048, nil)
+	assert.NoError(t, a.checkDomainFailure(domains, acme.RSA2048))
+	assert.Error(t, a.checkDomainFailure(domains, acme.EC256))
+	a.updateDomainFailure(domains, acme.EC256, nil)
+	assert.Empty(t, a.DomainsStatuses())
+}
+
+func TestDomainFailuresExpiration(t *testing.T) {
+	now := time.Now()
+	account := &Account{}
+	for i := 0; i < 10; i++ {
+		account.addDomainFailure([]string{"old.com"}, acme.RSA2048, errors.New("invalid response"), now.Add(-failureMaxBackoff))
+	}
+	account.addDomainFailure([]string{"new.com"}, acme.RSA2048, errors.New("invalid response"), now.Add(-time.Hour))
+
+	// expired records are ignored, and a new failure starts a new backoff
+	_, ok := account.getDomainFailure([]string{"old.com"}, acme.RSA2048, now)
+	assert.False(t, ok)
+	failure := account.addDomainFailure([]string{"old.com"}, acme.EC256, errors.New("invalid response"), now.Add(-failureMaxBackoff))
+	assert.Equal(t, 1, failure.Failures)
+
+	account.pruneDomainFailures(now)
+	assert.Len(t, account.DomainsFailures, 1)
+	_, ok = account.getDomainFailure([]string{"new.com"}, acme.RSA2048, now)
+	assert.True(t, ok)
+}
+
+func TestAuthorizeOnDemandDomain(t *testing.T) {
+	file, err := ioutil.TempFile("", "acme")
+	assert.NoError(t, err)
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store := NewLocalStore(file.Name())
+	account, err := NewAccount("f@f", acme.RSA2048)
+	assert.NoError(t, err)
+	transaction, _, err := store.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, transaction.Commit(account))
+
+	var asked []string
+	ask := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		asked = append(asked, r.URL.Query().Get("domain"))
+		assert.Equal(t, "tenants", r.URL.Query().Get("pool"))
+		if r.URL.Query().Get("domain") != "shop.customer.com" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer ask.Close()
+
+	a := ACME{store: store}
+	assert.NoError(t, a.authorizeOnDemandDomain("anything.com"))
+	a.releaseOnDemandDomain("anything.com")
+
+	a.OnDemandDomains = []string{"*.customer.com", "*.Example.org"}
+	assert.NoError(t, a.authorizeOnDemandDomain("www.example.org"))
+	a.releaseOnDemandDomain("www.example.org")
+	assert.Error(t, a.authorizeOnDemandDomain("example.net"))
+
+	a.OnDemandAsk = ask.URL + "/allowed?pool=tenants"
+	assert.NoError(t, a.authorizeOnDemandDomain("shop.customer.com"))
+	a.releaseOnDemandDomain("shop.customer.com")
+	assert.Error(t, a.authorizeOnDemandDomain("other.customer.com"))
+	assert.Error(t, a.authorizeOnDemandDomain("example.net"))
+	assert.Equal(t, []string{"shop.customer.com", "other.customer.com"}, asked)
+
+	// the answers are cached
+	assert.NoError(t, a.authorizeOnDemandDomain("shop.customer.com"))
+	a.releaseOnDemandDomain("shop.customer.com")
+	assert.Error(t, a.authorizeOnDemandDomain("other.customer.com"))
+	assert.Len(t, asked, 2)
+	a.onDemandAnswers["shop.customer.com"].expires = time.Now()
+	assert.NoError(t, a.authorizeOnDemandDomain("shop.customer.com"))
+	a.releaseOnDemandDomain("shop.customer.com")
+	assert.Len(t, asked, 3)
+	assert.Empty(t, a.reservedOnDemand)
+
+	a.OnDemandMax = 1
+	a.onDemandAnswers = nil
+	assert.NoError(t, a.authorizeOnDemandDomain("shop.customer.com"))
+	// the slot stays reserved while the certificate is obtained
+	err = a.authorizeOnDemandDomain("www.customer.com")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "maximum")
+	a.releaseOnDemandDomain("shop.customer.com")
+
+	cert, key, err := generateKeyPair("foo.customer.com", time.Now().Add(time.Hour), acme.RSA2048)
+	assert.NoError(t, err)
+	domainsCertificate, err := account.DomainsCertificate.addCertificateForDomains(&Certificate{Domain: "foo.customer.com", PrivateKey: key, Certificate: cert}, Domain{Main: "foo.customer.com"})
+	assert.NoError(t, err)
+	assert.NoError(t, a.authorizeOnDemandDomain("shop.customer.com"), "only the on demand certificates are counted")
+	a.releaseOnDemandDomain("shop.customer.com")
+	domainsCertificate.OnDemand = true
+	err = a.authorizeOnDemandDomain("shop.customer.com")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "maximum")
+	assert.Len(t, asked, 4, "the URL isn't asked over the maximum")
+}
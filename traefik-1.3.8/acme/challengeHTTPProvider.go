@@ -0,0 +1,103 @@
+package acme
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cenk/backoff"
+	"github.com/containous/traefik/cluster"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
+	"github.com/xenolf/lego/acme"
+)
+
+var _ acme.ChallengeProviderTimeout = (*challengeHTTPProvider)(nil)
+
+// challengeHTTPProvider shares the HTTP-01 challenge tokens through the store,
+// so that any node of the cluster can answer the challenges
+type challengeHTTPProvider struct {
+	store cluster.Store
+	lock  sync.RWMutex
+}
+
+// accountLoadTimeout is how long the challenge lookups wait for the ACME
+// account to be loaded from the store, while the node is starting
+const accountLoadTimeout = 2 * time.Second
+
+// getTokenValue returns the key authorization of the token for the domain, or
+// nil if no challenge is presented for them
+func (c *challengeHTTPProvider) getTokenValue(token, domain string) []byte {
+	domain = types.CanonicalDomain(domain)
+	log.Debugf("Looking for an existing ACME challenge for token %v...", token)
+	var account *Account
+	operation := func() error {
+		var ok bool
+		account, ok = c.store.Get().(*Account)
+		if !ok || account == nil {
+			return fmt.Errorf("ACME account not loaded")
+		}
+		return nil
+	}
+	notify := func(err error, time time.Duration) {
+		log.Debugf("Error getting challenge for token: %v, retrying in %s", err, time)
+	}
+	ebo := backoff.NewExponentialBackOff()
+	ebo.MaxElapsedTime = accountLoadTimeout
+	if err := backoff.RetryNotify(safe.OperationWithRecover(operation), ebo, notify); err != nil {
+		log.Errorf("Error getting challenge for token: %v", err)
+		return nil
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if keyAuth, ok := account.HTTPChallenge[token][domain]; ok {
+		return keyAuth
+	}
+	log.Debugf("Cannot find challenge for token %v and domain %s", token, domain)
+	return nil
+}
+
+func (c *challengeHTTPProvider) Present(domain, token, keyAuth string) error {
+	domain = types.CanonicalDomain(domain)
+	log.Debugf("Challenge Present %s", domain)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	transaction, object, err := c.store.Begin()
+	if err != nil {
+		return err
+	}
+	account := object.(*Account)
+	if account.HTTPChallenge == nil {
+		account.HTTPChallenge = map[string]map[string][]byte{}
+	}
+	if _, ok := account.HTTPChallenge[token]; !ok {
+		account.HTTPChallenge[token] = map[string][]byte{}
+	}
+	account.HTTPChallenge[token][domain] = []byte(keyAuth)
+	return transaction.Commit(account)
+}
+
+func (c *challengeHTTPProvider) CleanUp(domain, token, keyAuth string) error {
+	domain = types.CanonicalDomain(domain)
+	log.Debugf("Challenge CleanUp %s", domain)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	transaction, object, err := c.store.Begin()
+	if err != nil {
+		return err
+	}
+	account := object.(*Account)
+	if _, ok := account.HTTPChallenge[token]; ok {
+		delete(account.HTTPChallenge[token], domain)
+		if len(account.HTTPChallenge[token]) == 0 {
+			delete(account.HTTPChallenge, token)
+		}
+	}
+	return transaction.Commit(account)
+}
+
+func (c *challengeHTTPProvider) Timeout() (timeout, interval time.Duration) {
+	return 60 * time.Second, 5 * time.Second
+}
@@ -3,6 +3,7 @@ package acme
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -13,10 +14,81 @@ import (
 	"encoding/pem"
 	"fmt"
 	"math/big"
+	"strings"
 	"time"
+
+	"github.com/xenolf/lego/acme"
 )
 
-func generateDefaultCertificate() (*tls.Certificate, error) {
+// keyTypes are the supported key types, by name
+var keyTypes = map[string]acme.KeyType{
+	"rsa2048": acme.RSA2048,
+	"rsa4096": acme.RSA4096,
+	"ec256":   acme.EC256,
+	"ec384":   acme.EC384,
+}
+
+// parseKeyType returns the key type of its name, RSA4096 by default
+func parseKeyType(name string) (acme.KeyType, error) {
+	if len(name) == 0 {
+		return acme.RSA4096, nil
+	}
+	keyType, ok := keyTypes[strings.ToLower(name)]
+	if !ok {
+		return "", fmt.Errorf("unknown key type %s, expected RSA2048, RSA4096, EC256 or EC384", name)
+	}
+	return keyType, nil
+}
+
+// keyTypeName returns the name of the key type, as configured
+func keyTypeName(keyType acme.KeyType) string {
+	for name, t := range keyTypes {
+		if t == keyType {
+			return strings.ToUpper(name)
+		}
+	}
+	return string(keyType)
+}
+
+// keyTypeCurve returns the curve of the ECDSA key type, or nil for the RSA key
+// types
+func keyTypeCurve(keyType acme.KeyType) elliptic.Curve {
+	switch keyType {
+	case acme.EC256:
+		return elliptic.P256()
+	case acme.EC384:
+		return elliptic.P384()
+	}
+	return nil
+}
+
+func generatePrivateKey(keyType acme.KeyType) (crypto.PrivateKey, error) {
+	switch keyType {
+	case acme.EC256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case acme.EC384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case acme.RSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case acme.RSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	}
+	return nil, fmt.Errorf("invalid key type %s", keyType)
+}
+
+// marshalPrivateKey returns the DER encoding of the private key, in PKCS#1 for
+// the RSA keys and SEC 1 for the ECDSA keys
+func marshalPrivateKey(privateKey crypto.PrivateKey) ([]byte, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return x509.MarshalPKCS1PrivateKey(key), nil
+	case *ecdsa.PrivateKey:
+		return x509.MarshalECPrivateKey(key)
+	}
+	return nil, fmt.Errorf("unsupported private key %T", privateKey)
+}
+
+func generateDefaultCertificate(keyType acme.KeyType) (*tls.Certificate, error) {
 	randomBytes := make([]byte, 100)
 	_, err := rand.Read(randomBytes)
 	if err != nil {
@@ -26,7 +98,7 @@ func generateDefaultCertificate() (*tls.Certificate, error) {
 	z := hex.EncodeToString(zBytes[:sha256.Size])
 	domain := fmt.Sprintf("%s.%s.traefik.default", z[:32], z[32:])
 
-	certPEM, keyPEM, err := generateKeyPair(domain, time.Time{})
+	certPEM, keyPEM, err := generateKeyPair(domain, time.Time{}, keyType)
 	if err != nil {
 		return nil, err
 	}
@@ -39,21 +111,21 @@ func generateDefaultCertificate() (*tls.Certificate, error) {
 	return &certificate, nil
 }
 
-func generateKeyPair(domain string, expiration time.Time) ([]byte, []byte, error) {
-	rsaPrivKey, err := rsa.GenerateKey(rand.Reader, 2048)
+func generateKeyPair(domain string, expiration time.Time, keyType acme.KeyType) ([]byte, []byte, error) {
+	privKey, err := generatePrivateKey(keyType)
 	if err != nil {
 		return nil, nil, err
 	}
-	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaPrivKey)})
+	keyPEM := pemEncode(privKey)
 
-	certPEM, err := generatePemCert(rsaPrivKey, domain, expiration)
+	certPEM, err := generatePemCert(privKey, domain, expiration)
 	if err != nil {
 		return nil, nil, err
 	}
 	return certPEM, keyPEM, nil
 }
 
-func generatePemCert(privKey *rsa.PrivateKey, domain string, expiration time.Time) ([]byte, error) {
+func generatePemCert(privKey crypto.PrivateKey, domain string, expiration time.Time) ([]byte, error) {
 	derBytes, err := generateDerCert(privKey, expiration, domain)
 	if err != nil {
 		return nil, err
@@ -62,7 +134,7 @@ func generatePemCert(privKey *rsa.PrivateKey, domain string, expiration time.Tim
 	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes}), nil
 }
 
-func generateDerCert(privKey *rsa.PrivateKey, expiration time.Time, domain string) ([]byte, error) {
+func generateDerCert(privKey crypto.PrivateKey, expiration time.Time, domain string) ([]byte, error) {
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
 	if err != nil {
@@ -81,12 +153,22 @@ func generateDerCert(privKey *rsa.PrivateKey, expiration time.Time, domain strin
 		NotBefore: time.Now(),
 		NotAfter:  expiration,
 
-		KeyUsage:              x509.KeyUsageKeyEncipherment,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
 		BasicConstraintsValid: true,
 		DNSNames:              []string{domain},
 	}
 
-	return x509.CreateCertificate(rand.Reader, &template, &template, &privKey.PublicKey, privKey)
+	var publicKey crypto.PublicKey
+	switch key := privKey.(type) {
+	case *rsa.PrivateKey:
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+		publicKey = &key.PublicKey
+	case *ecdsa.PrivateKey:
+		publicKey = &key.PublicKey
+	default:
+		return nil, fmt.Errorf("unsupported private key %T", privKey)
+	}
+	return x509.CreateCertificate(rand.Reader, &template, &template, publicKey, privKey)
 }
 
 // TLSSNI01ChallengeCert returns a certificate and target domain for the `tls-sni-01` challenge
@@ -133,3 +215,58 @@ func pemEncode(data interface{}) []byte {
 
 	return pem.EncodeToMemory(pemBlock)
 }
+
+// supportsECDSA returns whether the client supports the ECDSA certificates of
+// the curve, according to its signature schemes, curves and cipher suites
+func supportsECDSA(clientHello *tls.ClientHelloInfo, curve elliptic.Curve) bool {
+	if clientHello == nil {
+		return false
+	}
+
+	if len(clientHello.SignatureSchemes) > 0 {
+		supported := false
+		for _, scheme := range clientHello.SignatureSchemes {
+			switch scheme {
+			case tls.ECDSAWithP256AndSHA256, tls.ECDSAWithP384AndSHA384, tls.ECDSAWithP521AndSHA512, tls.ECDSAWithSHA1:
+				supported = true
+			}
+		}
+		if !supported {
+			return false
+		}
+	}
+
+	if len(clientHello.SupportedCurves) > 0 {
+		var curveID tls.CurveID
+		switch curve.Params().Name {
+		case "P-256":
+			curveID = tls.CurveP256
+		case "P-384":
+			curveID = tls.CurveP384
+		case "P-521":
+			curveID = tls.CurveP521
+		}
+		supported := false
+		for _, supportedCurve := range clientHello.SupportedCurves {
+			if supportedCurve == curveID {
+				supported = true
+			}
+		}
+		if !supported {
+			return false
+		}
+	}
+
+	for _, cipherSuite := range clientHello.CipherSuites {
+		switch cipherSuite {
+		case tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305, tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA, tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA,
+			// TLS 1.3 cipher suites don't depend on the certificate
+			tls.TLS_AES_128_GCM_SHA256, tls.TLS_AES_256_GCM_SHA384, tls.TLS_CHACHA20_POLY1305_SHA256:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,259 @@
+package acme
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/xenolf/lego/acme"
+)
+
+const (
+	// failureBackoff is the delay before retrying the domains after their
+	// first failure, doubled on each new failure up to failureMaxBackoff
+	failureBackoff    = 5 * time.Minute
+	failureMaxBackoff = 24 * time.Hour
+	// rateLimitBackoff is the minimum delay after a rate limit error, the CA
+	// not telling when the limit resets
+	rateLimitBackoff = 3 * time.Hour
+)
+
+// DomainFailure records the failed attempts to obtain a certificate for
+// domains, the next attempt being delayed with an exponential backoff. The
+// record expires failureMaxBackoff after the last failure.
+type DomainFailure struct {
+	Domains     []string
+	KeyType     acme.KeyType
+	Failures    int
+	LastError   string
+	LastFailure time.Time
+	NextAttempt time.Time
+	RateLimited bool
+}
+
+// DomainStatus is the status of the domains being obtained or whose last
+// attempt failed
+type DomainStatus struct {
+	Domains     []string   `json:"domains"`
+	KeyType     string     `json:"keyType,omitempty"`
+	Status      string     `json:"status"`
+	Since       *time.Time `json:"since,omitempty"`
+	Failures    int        `json:"failures,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastFailure *time.Time `json:"lastFailure,omitempty"`
+	NextAttempt *time.Time `json:"nextAttempt,omitempty"`
+	RateLimited bool       `json:"rateLimited,omitempty"`
+}
+
+// obtainError holds the errors of a certificate request by domain
+type obtainError map[string]error
+
+func (e obtainError) Error() string {
+	return fmt.Sprintf("Cannot obtain certificates %+v", map[string]error(e))
+}
+
+// isRateLimited returns whether the CA rejected the request because of its
+// rate limits
+func isRateLimited(err error) bool {
+	switch err := err.(type) {
+	case obtainError:
+		for _, domainErr := range err {
+			if isRateLimited(domainErr) {
+				return true
+			}
+		}
+		return false
+	case acme.RemoteError:
+		return err.StatusCode == http.StatusTooManyRequests || strings.HasSuffix(err.Type, ":rateLimited")
+	case *acme.RemoteError:
+		return err.StatusCode == http.StatusTooManyRequests || strings.HasSuffix(err.Type, ":rateLimited")
+	}
+	// the challenge errors embed the remote error in an unexported type
+	return err != nil && strings.Contains(err.Error(), ":rateLimited")
+}
+
+// domainsKey returns the key of the domains in the failure records
+func domainsKey(domains []string) string {
+	return strings.Join(domains, ",")
+}
+
+// domainFailureKey returns the key of the failure record of the domains for
+// the key type, the certificates of each key type being obtained separately
+func domainFailureKey(domains []string, keyType acme.KeyType) string {
+	return string(keyType) + ":" + domainsKey(domains)
+}
+
+// nextAttemptDelay returns the delay before the next attempt after failures
+func nextAttemptDelay(failures int, rateLimited bool) time.Duration {
+	delay := failureBackoff
+	for i := 1; i < failures && delay < failureMaxBackoff; i++ {
+		delay *= 2
+	}
+	if rateLimited && delay < rateLimitBackoff {
+		delay = rateLimitBackoff
+	}
+	if delay > failureMaxBackoff {
+		delay = failureMaxBackoff
+	}
+	return delay
+}
+
+// isExpired tells whether the failure is too old to be taken into account
+func (f *DomainFailure) isExpired(now time.Time) bool {
+	return !now.Before(f.LastFailure.Add(failureMaxBackoff))
+}
+
+// getDomainFailure returns the unexpired failure record of the domains for
+// the key type, if any
+func (a *Account) getDomainFailure(domains []string, keyType acme.KeyType, now time.Time) (*DomainFailure, bool) {
+	failure, ok := a.DomainsFailures[domainFailureKey(domains, keyType)]
+	if !ok || failure.isExpired(now) {
+		return nil, false
+	}
+	return failure, true
+}
+
+// addDomainFailure records a new failure of the domains for the key type
+func (a *Account) addDomainFailure(domains []string, keyType acme.KeyType, err error, now time.Time) *DomainFailure {
+	if a.DomainsFailures == nil {
+		a.DomainsFailures = map[string]*DomainFailure{}
+	}
+	failure, ok := a.getDomainFailure(domains, keyType, now)
+	if !ok {
+		failure = &DomainFailure{Domains: domains, KeyType: keyType}
+		a.DomainsFailures[domainFailureKey(domains, keyType)] = failure
+	}
+	failure.Failures++
+	failure.LastError = err.Error()
+	failure.LastFailure = now
+	failure.RateLimited = isRateLimited(err)
+	failure.NextAttempt = now.Add(nextAttemptDelay(failure.Failures, failure.RateLimited))
+	return failure
+}
+
+// removeDomainFailure removes the failure record of the domains for the key
+// type
+func (a *Account) removeDomainFailure(domains []string, keyType acme.KeyType) {
+	delete(a.DomainsFailures, domainFailureKey(domains, keyType))
+}
+
+// pruneDomainFailures removes the expired failure records, so that the
+// failures of domains which are not requested anymore don't pile up in the
+// store
+func (a *Account) pruneDomainFailures(now time.Time) {
+	for key, failure := range a.DomainsFailures {
+		if failure.isExpired(now) {
+			delete(a.DomainsFailures, key)
+		}
+	}
+}
+
+// checkDomainFailure returns an error while the domains are backing off after
+// a failure to obtain their certificate for the key type
+func (a *ACME) checkDomainFailure(domains []string, keyType acme.KeyType) error {
+	a.domainsLock.RLock()
+	defer a.domainsLock.RUnlock()
+	account := a.store.Get().(*Account)
+	now := time.Now()
+	if failure, ok := account.getDomainFailure(domains, keyType, now); ok && now.Before(failure.NextAttempt) {
+		return fmt.Errorf("Cannot obtain %s certificates %s before %s after %d failures, last error: %s", keyTypeName(keyType), domains, failure.NextAttempt.Format(time.RFC3339), failure.Failures, failure.LastError)
+	}
+	return nil
+}
+
+// updateDomainFailure records the failure of the domains for the key type in
+// the store, shared by the cluster, or removes their failure record after a
+// success. The expired records are pruned meanwhile.
+func (a *ACME) updateDomainFailure(domains []string, keyType acme.KeyType, err error) {
+	a.domainsLock.Lock()
+	defer a.domainsLock.Unlock()
+	now := time.Now()
+	if err == nil {
+		if _, ok := a.store.Get().(*Account).DomainsFailures[domainFailureKey(domains, keyType)]; !ok {
+			return
+		}
+	}
+	transaction, object, txErr := a.store.Begin()
+	if txErr != nil {
+		log.Errorf("Error creating ACME store transaction for domains %s: %v", domains, txErr)
+		return
+	}
+	account := object.(*Account)
+	account.pruneDomainFailures(now)
+	if err == nil {
+		account.removeDomainFailure(domains, keyType)
+	} else {
+		failure := account.addDomainFailure(domains, keyType, err, now)
+		log.Warnf("ACME %s certificates %s failed %d times, next attempt at %s", keyTypeName(keyType), domains, failure.Failures, failure.NextAttempt.Format(time.RFC3339))
+	}
+	if txErr = transaction.Commit(account); txErr != nil {
+		log.Errorf("Error Saving ACME account %+v: %v", account, txErr)
+	}
+}
+
+// startPending marks the domains as being obtained, returning false if they
+// already are
+func (a *ACME) startPending(domains []string) bool {
+	a.domainsLock.Lock()
+	defer a.domainsLock.Unlock()
+	if _, ok := a.pendingDomains[domainsKey(domains)]; ok {
+		return false
+	}
+	if a.pendingDomains == nil {
+		a.pendingDomains = map[string]time.Time{}
+	}
+	a.pendingDomains[domainsKey(domains)] = time.Now()
+	return true
+}
+
+func (a *ACME) stopPending(domains []string) {
+	a.domainsLock.Lock()
+	defer a.domainsLock.Unlock()
+	delete(a.pendingDomains, domainsKey(domains))
+}
+
+// DomainsStatuses returns the status of the domains being obtained by this
+// node, and of the domains whose last attempt failed
+func (a *ACME) DomainsStatuses() []*DomainStatus {
+	statuses := []*DomainStatus{}
+	a.domainsLock.RLock()
+	defer a.domainsLock.RUnlock()
+	for key, since := range a.pendingDomains {
+		since := since
+		statuses = append(statuses, &DomainStatus{Domains: strings.Split(key, ","), Status: "pending", Since: &since})
+	}
+	if a.store != nil {
+		if account, ok := a.store.Get().(*Account); ok && account != nil {
+			now := time.Now()
+			for _, failure := range account.DomainsFailures {
+				if failure.isExpired(now) {
+					continue
+				}
+				lastFailure, nextAttempt := failure.LastFailure, failure.NextAttempt
+				statuses = append(statuses, &DomainStatus{
+					Domains:     failure.Domains,
+					KeyType:     keyTypeName(failure.KeyType),
+					Status:      "failed",
+					Failures:    failure.Failures,
+					LastError:   failure.LastError,
+					LastFailure: &lastFailure,
+					NextAttempt: &nextAttempt,
+					RateLimited: failure.RateLimited,
+				})
+			}
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Status != statuses[j].Status {
+			return statuses[i].Status > statuses[j].Status
+		}
+		if domainsKey(statuses[i].Domains) != domainsKey(statuses[j].Domains) {
+			return domainsKey(statuses[i].Domains) < domainsKey(statuses[j].Domains)
+		}
+		return statuses[i].KeyType < statuses[j].KeyType
+	})
+	return statuses
+}
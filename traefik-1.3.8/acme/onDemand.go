@@ -0,0 +1,135 @@
+package acme
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/ryanuber/go-glob"
+)
+
+const (
+	// onDemandAskTimeout is the timeout of the requests to the OnDemandAsk URL
+	onDemandAskTimeout = 10 * time.Second
+	// onDemandAskCacheDuration is how long the answers of the OnDemandAsk URL
+	// are reused, for the handshakes following each other
+	onDemandAskCacheDuration = time.Minute
+)
+
+// onDemandAnswer is a cached answer of the OnDemandAsk URL
+type onDemandAnswer struct {
+	err     error
+	expires time.Time
+}
+
+// authorizeOnDemandDomain returns an error if a certificate can't be obtained
+// on demand for the domain: not matching the allowed domains, over the maximum
+// number of on demand certificates, or refused by the OnDemandAsk URL.
+// Otherwise a slot of the maximum is reserved for the domain, until released
+// with releaseOnDemandDomain.
+func (a *ACME) authorizeOnDemandDomain(domain string) error {
+	if len(a.OnDemandDomains) > 0 {
+		allowed := false
+		for _, pattern := range a.OnDemandDomains {
+			if glob.Glob(strings.ToLower(pattern), domain) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("domain %s not allowed for on demand certificates", domain)
+		}
+	}
+	if err := a.reserveOnDemandDomain(domain); err != nil {
+		return err
+	}
+	if len(a.OnDemandAsk) > 0 {
+		if err := a.askOnDemandDomain(domain); err != nil {
+			a.releaseOnDemandDomain(domain)
+			return err
+		}
+	}
+	return nil
+}
+
+// reserveOnDemandDomain reserves a slot of the maximum number of on demand
+// certificates for the domain, counting the certificates being obtained
+func (a *ACME) reserveOnDemandDomain(domain string) error {
+	a.domainsLock.Lock()
+	defer a.domainsLock.Unlock()
+	if a.OnDemandMax > 0 && a.reservedOnDemand[domain] == 0 {
+		account := a.store.Get().(*Account)
+		if count := account.DomainsCertificate.countOnDemandDomains() + len(a.reservedOnDemand); count >= a.OnDemandMax {
+			return fmt.Errorf("maximum number of on demand certificates reached (%d)", count)
+		}
+	}
+	if a.reservedOnDemand == nil {
+		a.reservedOnDemand = map[string]int{}
+	}
+	a.reservedOnDemand[domain]++
+	return nil
+}
+
+// releaseOnDemandDomain releases the slot reserved for the domain, once its
+// certificate is stored or couldn't be obtained
+func (a *ACME) releaseOnDemandDomain(domain string) {
+	a.domainsLock.Lock()
+	defer a.domainsLock.Unlock()
+	a.reservedOnDemand[domain]--
+	if a.reservedOnDemand[domain] <= 0 {
+		delete(a.reservedOnDemand, domain)
+	}
+}
+
+// askOnDemandDomain queries the OnDemandAsk URL with the domain, which must
+// answer with a 2xx status to allow the certificate. The answers are cached
+// for onDemandAskCacheDuration.
+func (a *ACME) askOnDemandDomain(domain string) error {
+	now := time.Now()
+	a.domainsLock.RLock()
+	answer, ok := a.onDemandAnswers[domain]
+	a.domainsLock.RUnlock()
+	if ok && now.Before(answer.expires) {
+		return answer.err
+	}
+
+	err := a.queryOnDemandAsk(domain)
+
+	a.domainsLock.Lock()
+	defer a.domainsLock.Unlock()
+	if a.onDemandAnswers == nil {
+		a.onDemandAnswers = map[string]*onDemandAnswer{}
+	}
+	for cached, answer := range a.onDemandAnswers {
+		if !now.Before(answer.expires) {
+			delete(a.onDemandAnswers, cached)
+		}
+	}
+	a.onDemandAnswers[domain] = &onDemandAnswer{err: err, expires: now.Add(onDemandAskCacheDuration)}
+	return err
+}
+
+func (a *ACME) queryOnDemandAsk(domain string) error {
+	askURL, err := url.Parse(a.OnDemandAsk)
+	if err != nil {
+		return fmt.Errorf("invalid on demand ask URL %s: %v", a.OnDemandAsk, err)
+	}
+	query := askURL.Query()
+	query.Set("domain", domain)
+	askURL.RawQuery = query.Encode()
+
+	client := &http.Client{Timeout: onDemandAskTimeout}
+	resp, err := client.Get(askURL.String())
+	if err != nil {
+		return fmt.Errorf("error asking %s for domain %s: %v", a.OnDemandAsk, domain, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("domain %s refused by %s with status %d", domain, a.OnDemandAsk, resp.StatusCode)
+	}
+	log.Debugf("Domain %s allowed by %s", domain, a.OnDemandAsk)
+	return nil
+}
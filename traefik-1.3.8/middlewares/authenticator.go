@@ -12,7 +12,13 @@ import (
 	"github.com/containous/traefik/types"
 )
 
-// Authenticator is a middleware that provides HTTP basic and digest authentication
+const (
+	defaultAuthRealm    = "traefik"
+	authorizationHeader = "Authorization"
+)
+
+// Authenticator is a middleware that provides HTTP basic, digest and JWT bearer
+// token authentication, or delegates the authentication to an external service
 type Authenticator struct {
 	handler negroni.Handler
 	users   map[string]string
@@ -30,7 +36,7 @@ func NewAuthenticator(authConfig *types.Auth) (*Authenticator, error) {
 		if err != nil {
 			return nil, err
 		}
-		basicAuth := auth.NewBasicAuthenticator("traefik", authenticator.secretBasic)
+		basicAuth := auth.NewBasicAuthenticator(authRealm(authConfig.Basic.Realm), authenticator.secretBasic)
 		authenticator.handler = negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 			if username := basicAuth.CheckAuth(r); username == "" {
 				log.Debugf("Basic auth failed...")
@@ -40,6 +46,9 @@ func NewAuthenticator(authConfig *types.Auth) (*Authenticator, error) {
 				if authConfig.HeaderField != "" {
 					r.Header[authConfig.HeaderField] = []string{username}
 				}
+				if authConfig.Basic.RemoveHeader {
+					r.Header.Del(authorizationHeader)
+				}
 				next.ServeHTTP(w, r)
 			}
 		})
@@ -48,7 +57,7 @@ func NewAuthenticator(authConfig *types.Auth) (*Authenticator, error) {
 		if err != nil {
 			return nil, err
 		}
-		digestAuth := auth.NewDigestAuthenticator("traefik", authenticator.secretDigest)
+		digestAuth := auth.NewDigestAuthenticator(authRealm(authConfig.Digest.Realm), authenticator.secretDigest)
 		authenticator.handler = negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 			if username, _ := digestAuth.CheckAuth(r); username == "" {
 				log.Debugf("Digest auth failed...")
@@ -58,13 +67,35 @@ func NewAuthenticator(authConfig *types.Auth) (*Authenticator, error) {
 				if authConfig.HeaderField != "" {
 					r.Header[authConfig.HeaderField] = []string{username}
 				}
+				if authConfig.Digest.RemoveHeader {
+					r.Header.Del(authorizationHeader)
+				}
 				next.ServeHTTP(w, r)
 			}
 		})
+	} else if authConfig.JWT != nil {
+		authenticator.handler, err = newJWTAuth(authConfig.JWT, authConfig.HeaderField)
+		if err != nil {
+			return nil, err
+		}
+	} else if authConfig.Forward != nil {
+		authenticator.handler, err = newForwardAuth(authConfig.Forward)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("Error creating Authenticator: no authentication method")
 	}
 	return &authenticator, nil
 }
 
+func authRealm(realm string) string {
+	if len(realm) == 0 {
+		return defaultAuthRealm
+	}
+	return realm
+}
+
 func parserBasicUsers(basic *types.Basic) (map[string]string, error) {
 	var userStrs []string
 	if basic.UsersFile != "" {
@@ -64,6 +64,13 @@ func TestAuthUsersFromFile(t *testing.T) {
 	}
 }
 
+func TestAuthWithoutMethod(t *testing.T) {
+	_, err := NewAuthenticator(&types.Auth{HeaderField: "X-WebAuth-User"})
+	assert.Error(t, err)
+	_, err = NewAuthenticator(nil)
+	assert.Error(t, err)
+}
+
 func TestBasicAuthFail(t *testing.T) {
 	authMiddleware, err := NewAuthenticator(&types.Auth{
 		Basic: &types.Basic{
@@ -184,3 +191,34 @@ func TestBasicAuthUserHeader(t *testing.T) {
 	assert.NoError(t, err, "there should be no error")
 	assert.Equal(t, "traefik\n", string(body), "they should be equal")
 }
+
+func TestBasicAuthRealmAndRemoveHeader(t *testing.T) {
+	authMiddleware, err := NewAuthenticator(&types.Auth{
+		Basic: &types.Basic{
+			Users:        []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
+			Realm:        "my-realm",
+			RemoveHeader: true,
+		},
+	})
+	assert.NoError(t, err, "there should be no error")
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Authorization"), "authorization header should be removed")
+		fmt.Fprintln(w, "traefik")
+	})
+	n := negroni.New(authMiddleware)
+	n.UseHandler(handler)
+	ts := httptest.NewServer(n)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	assert.NoError(t, err, "there should be no error")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode, "they should be equal")
+	assert.Equal(t, `Basic realm="my-realm"`, res.Header.Get("WWW-Authenticate"), "they should be equal")
+
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	req.SetBasicAuth("test", "test")
+	res, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err, "there should be no error")
+	assert.Equal(t, http.StatusOK, res.StatusCode, "they should be equal")
+}
@@ -0,0 +1,220 @@
+package middlewares
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	"github.com/vulcand/oxy/utils"
+)
+
+const (
+	// defaultMemRequestBodyBytes is the size of the request bodies kept in memory
+	defaultMemRequestBodyBytes = 1048576
+	// maxBufferingAttempts bounds the retries of the expressions which do not
+	// check the number of attempts
+	maxBufferingAttempts = 10
+)
+
+var errBodyTooLarge = errors.New("body too large")
+
+// Buffering is a middleware that reads the whole request body before
+// forwarding the request, so that its size can be limited and the request
+// replayed, and which buffers the response when its size is limited or when
+// the request is retried on an expression
+type Buffering struct {
+	next                 http.Handler
+	maxRequestBodyBytes  int64
+	memRequestBodyBytes  int64
+	maxResponseBodyBytes int64
+	retryPredicate       retryPredicate
+}
+
+// NewBuffering creates a Buffering middleware
+func NewBuffering(buffering *types.Buffering, next http.Handler) (*Buffering, error) {
+	b := &Buffering{
+		next:                 next,
+		maxRequestBodyBytes:  buffering.MaxRequestBodyBytes,
+		memRequestBodyBytes:  buffering.MemRequestBodyBytes,
+		maxResponseBodyBytes: buffering.MaxResponseBodyBytes,
+	}
+	if b.memRequestBodyBytes <= 0 {
+		b.memRequestBodyBytes = defaultMemRequestBodyBytes
+	}
+	if len(buffering.RetryExpression) > 0 {
+		p, err := parseRetryExpression(buffering.RetryExpression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry expression %q: %v", buffering.RetryExpression, err)
+		}
+		b.retryPredicate = p
+	}
+	return b, nil
+}
+
+func (b *Buffering) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if b.maxRequestBodyBytes > 0 && req.ContentLength > b.maxRequestBodyBytes {
+		http.Error(rw, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	body, err := b.readRequestBody(req.Body)
+	if err == errBodyTooLarge {
+		http.Error(rw, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		log.Errorf("Error reading the body of %s: %v", req.URL, err)
+		http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	defer body.remove()
+
+	outReq := new(http.Request)
+	*outReq = *req
+	outReq.Body = body
+	if body.size == 0 {
+		outReq.Body = http.NoBody
+	}
+	outReq.ContentLength = body.size
+	outReq.TransferEncoding = nil
+	outReq.Header = make(http.Header)
+	utils.CopyHeaders(outReq.Header, req.Header)
+	outReq.Header.Del("Transfer-Encoding")
+
+	// the responses of upgraded connections can't be buffered
+	if (b.maxResponseBodyBytes <= 0 && b.retryPredicate == nil) || len(req.Header.Get("Upgrade")) > 0 {
+		b.next.ServeHTTP(rw, outReq)
+		return
+	}
+
+	attempts := 1
+	for {
+		response := &bufferedResponse{header: make(http.Header), code: http.StatusOK, maxBodyBytes: b.maxResponseBodyBytes}
+		b.next.ServeHTTP(response, outReq)
+		if response.tooLarge {
+			log.Errorf("Response to %s exceeds %d bytes", req.URL, b.maxResponseBodyBytes)
+			http.Error(rw, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			return
+		}
+		if b.retryPredicate != nil && attempts < maxBufferingAttempts && b.retryPredicate(&retryContext{req: req, attempts: attempts, responseCode: response.code}) {
+			attempts++
+			log.Debugf("New attempt %d for request: %v", attempts, req.URL)
+			if _, err := body.Seek(0, io.SeekStart); err != nil {
+				log.Errorf("Error replaying the body of %s: %v", req.URL, err)
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			continue
+		}
+		utils.CopyHeaders(rw.Header(), response.header)
+		rw.WriteHeader(response.code)
+		rw.Write(response.body.Bytes())
+		return
+	}
+}
+
+// readRequestBody reads the body in memory, or in a temporary file once it
+// exceeds the memory threshold
+func (b *Buffering) readRequestBody(reader io.Reader) (*bufferedBody, error) {
+	if reader == nil {
+		return &bufferedBody{ReadSeeker: bytes.NewReader(nil)}, nil
+	}
+	if b.maxRequestBodyBytes > 0 {
+		// read one more byte to detect the bodies which are too large
+		reader = io.LimitReader(reader, b.maxRequestBodyBytes+1)
+	}
+
+	var buffer bytes.Buffer
+	size, err := io.CopyN(&buffer, reader, b.memRequestBodyBytes)
+	if err == io.EOF {
+		if b.maxRequestBodyBytes > 0 && size > b.maxRequestBodyBytes {
+			return nil, errBodyTooLarge
+		}
+		return &bufferedBody{ReadSeeker: bytes.NewReader(buffer.Bytes()), size: size}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := ioutil.TempFile("", "traefik-buffering-")
+	if err != nil {
+		return nil, err
+	}
+	body := &bufferedBody{ReadSeeker: file, file: file}
+	if _, err = file.Write(buffer.Bytes()); err == nil {
+		var n int64
+		n, err = io.Copy(file, reader)
+		size += n
+	}
+	if err == nil && b.maxRequestBodyBytes > 0 && size > b.maxRequestBodyBytes {
+		err = errBodyTooLarge
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		body.remove()
+		return nil, err
+	}
+	body.size = size
+	return body, nil
+}
+
+// bufferedBody is a request body which can be read again from its start
+type bufferedBody struct {
+	io.ReadSeeker
+	size int64
+	file *os.File
+}
+
+// Close does nothing, as the body is closed by the transport after each attempt
+func (b *bufferedBody) Close() error {
+	return nil
+}
+
+// remove releases the temporary file of the body
+func (b *bufferedBody) remove() {
+	if b.file == nil {
+		return
+	}
+	b.file.Close()
+	if err := os.Remove(b.file.Name()); err != nil {
+		log.Errorf("Error removing buffering file %s: %v", b.file.Name(), err)
+	}
+}
+
+// bufferedResponse buffers a response, and discards its body if it exceeds the
+// maximum size
+type bufferedResponse struct {
+	header       http.Header
+	code         int
+	body         bytes.Buffer
+	maxBodyBytes int64
+	tooLarge     bool
+}
+
+func (rw *bufferedResponse) Header() http.Header {
+	return rw.header
+}
+
+func (rw *bufferedResponse) WriteHeader(code int) {
+	rw.code = code
+}
+
+func (rw *bufferedResponse) Write(buf []byte) (int, error) {
+	if rw.tooLarge {
+		return 0, errBodyTooLarge
+	}
+	if rw.maxBodyBytes > 0 && int64(rw.body.Len()+len(buf)) > rw.maxBodyBytes {
+		// stops the copy of the response
+		rw.tooLarge = true
+		rw.body.Reset()
+		return 0, errBodyTooLarge
+	}
+	return rw.body.Write(buf)
+}
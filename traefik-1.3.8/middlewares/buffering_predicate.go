@@ -0,0 +1,142 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/vulcand/predicate"
+)
+
+// retryContext holds what a retry expression is evaluated against
+type retryContext struct {
+	req          *http.Request
+	attempts     int
+	responseCode int
+}
+
+type retryPredicate func(*retryContext) bool
+
+type retryString func(*retryContext) string
+
+type retryInt func(*retryContext) int
+
+// parseRetryExpression parses a retry expression, with the syntax and the
+// functions of the oxy buffering middleware, e.g.
+// `IsNetworkError() && Attempts() <= 2`:
+//
+// RequestMethod() - the method of the request
+// Attempts() - the number of attempts already made
+// ResponseCode() - the status of the last response
+// IsNetworkError() - whether the last response is a 502 or a 504
+func parseRetryExpression(expression string) (retryPredicate, error) {
+	parser, err := predicate.NewParser(predicate.Def{
+		Operators: predicate.Operators{
+			AND: retryAnd,
+			OR:  retryOr,
+			EQ:  retryEQ,
+			NEQ: retryNEQ,
+			LT:  retryLT,
+			GT:  retryGT,
+			LE:  retryLE,
+			GE:  retryGE,
+		},
+		Functions: map[string]interface{}{
+			"RequestMethod": func() retryString {
+				return func(c *retryContext) string { return c.req.Method }
+			},
+			"Attempts": func() retryInt {
+				return func(c *retryContext) int { return c.attempts }
+			},
+			"ResponseCode": func() retryInt {
+				return func(c *retryContext) int { return c.responseCode }
+			},
+			"IsNetworkError": func() retryPredicate {
+				return func(c *retryContext) bool { return isNetworkError(c.responseCode) }
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	out, err := parser.Parse(expression)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := out.(retryPredicate)
+	if !ok {
+		return nil, fmt.Errorf("expected predicate, got %T", out)
+	}
+	return p, nil
+}
+
+func retryAnd(fns ...retryPredicate) retryPredicate {
+	return func(c *retryContext) bool {
+		for _, fn := range fns {
+			if !fn(c) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func retryOr(fns ...retryPredicate) retryPredicate {
+	return func(c *retryContext) bool {
+		for _, fn := range fns {
+			if fn(c) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func retryEQ(m interface{}, value interface{}) (retryPredicate, error) {
+	switch mapper := m.(type) {
+	case retryString:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %T", value)
+		}
+		return func(c *retryContext) bool { return mapper(c) == s }, nil
+	case retryInt:
+		return retryCompare(mapper, value, func(a, b int) bool { return a == b })
+	}
+	return nil, fmt.Errorf("unsupported argument: %T", m)
+}
+
+func retryNEQ(m interface{}, value interface{}) (retryPredicate, error) {
+	p, err := retryEQ(m, value)
+	if err != nil {
+		return nil, err
+	}
+	return func(c *retryContext) bool { return !p(c) }, nil
+}
+
+func retryLT(m interface{}, value interface{}) (retryPredicate, error) {
+	return retryCompare(m, value, func(a, b int) bool { return a < b })
+}
+
+func retryGT(m interface{}, value interface{}) (retryPredicate, error) {
+	return retryCompare(m, value, func(a, b int) bool { return a > b })
+}
+
+func retryLE(m interface{}, value interface{}) (retryPredicate, error) {
+	return retryCompare(m, value, func(a, b int) bool { return a <= b })
+}
+
+func retryGE(m interface{}, value interface{}) (retryPredicate, error) {
+	return retryCompare(m, value, func(a, b int) bool { return a >= b })
+}
+
+func retryCompare(m interface{}, value interface{}, compare func(a, b int) bool) (retryPredicate, error) {
+	mapper, ok := m.(retryInt)
+	if !ok {
+		return nil, fmt.Errorf("unsupported argument: %T", m)
+	}
+	i, ok := value.(int)
+	if !ok {
+		return nil, fmt.Errorf("expected int, got %T", value)
+	}
+	return func(c *retryContext) bool { return compare(mapper(c), i) }, nil
+}
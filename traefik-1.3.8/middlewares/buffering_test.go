@@ -0,0 +1,139 @@
+package middlewares
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffering(t *testing.T) {
+	cases := []struct {
+		desc         string
+		buffering    *types.Buffering
+		body         string
+		chunked      bool
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			desc:         "body in memory",
+			buffering:    &types.Buffering{MaxRequestBodyBytes: 10},
+			body:         "0123456789",
+			expectedCode: http.StatusOK,
+			expectedBody: "10:0123456789",
+		},
+		{
+			desc:         "body in a file",
+			buffering:    &types.Buffering{MemRequestBodyBytes: 4},
+			body:         "0123456789",
+			chunked:      true,
+			expectedCode: http.StatusOK,
+			expectedBody: "10:0123456789",
+		},
+		{
+			desc:         "content length too large",
+			buffering:    &types.Buffering{MaxRequestBodyBytes: 9},
+			body:         "0123456789",
+			expectedCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			desc:         "chunked body too large",
+			buffering:    &types.Buffering{MaxRequestBodyBytes: 9, MemRequestBodyBytes: 4},
+			body:         "0123456789",
+			chunked:      true,
+			expectedCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			desc:         "response too large",
+			buffering:    &types.Buffering{MaxResponseBodyBytes: 12},
+			body:         "0123456789",
+			expectedCode: http.StatusBadGateway,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.desc, func(t *testing.T) {
+			buffering, err := NewBuffering(c.buffering, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.Empty(t, r.TransferEncoding)
+				w.Write([]byte(strconv.FormatInt(r.ContentLength, 10) + ":" + string(body)))
+			}))
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			req := testhelpers.MustNewRequest(http.MethodPost, "http://localhost/foo", strings.NewReader(c.body))
+			if c.chunked {
+				req.ContentLength = -1
+				req.TransferEncoding = []string{"chunked"}
+			}
+			buffering.ServeHTTP(recorder, req)
+
+			assert.Equal(t, c.expectedCode, recorder.Code)
+			if c.expectedCode == http.StatusOK {
+				assert.Equal(t, c.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestBufferingRetry(t *testing.T) {
+	attempts := 0
+	buffering, err := NewBuffering(&types.Buffering{
+		MemRequestBodyBytes: 4,
+		RetryExpression:     "IsNetworkError() && Attempts() <= 2",
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "0123456789", string(body))
+		if attempts < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	buffering.ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodPost, "http://localhost/foo", strings.NewReader("0123456789")))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "ok", recorder.Body.String())
+	assert.Equal(t, 3, attempts)
+}
+
+func TestBufferingReplayedByRetry(t *testing.T) {
+	attempts := 0
+	retry := NewRetry(2, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "0123456789", string(body))
+		if attempts < 2 {
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}
+	}))
+	buffering, err := NewBuffering(&types.Buffering{}, retry)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	buffering.ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodPost, "http://localhost/foo", strings.NewReader("0123456789")))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestNewBufferingInvalidRetryExpression(t *testing.T) {
+	for _, expression := range []string{"Attempts(", "Foo() < 2", `RequestMethod() < "GET"`, "ResponseCode() == \"502\""} {
+		_, err := NewBuffering(&types.Buffering{RetryExpression: expression}, http.NotFoundHandler())
+		assert.Error(t, err, "expression %s", expression)
+	}
+}
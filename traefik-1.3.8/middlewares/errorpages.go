@@ -0,0 +1,221 @@
+package middlewares
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	"github.com/vulcand/oxy/utils"
+)
+
+// ErrorPagesHandler is a middleware that replaces the responses whose status
+// is in one of its ranges with the page served by an error backend
+type ErrorPagesHandler struct {
+	httpCodeRanges [][2]int
+	backendHandler http.Handler
+	query          string
+}
+
+// NewErrorPagesHandler creates an ErrorPagesHandler middleware serving the
+// pages of the error backend handler
+func NewErrorPagesHandler(errorPage *types.ErrorPage, backendHandler http.Handler) (*ErrorPagesHandler, error) {
+	if len(errorPage.Status) == 0 {
+		return nil, errors.New("no status range")
+	}
+	var httpCodeRanges [][2]int
+	for _, status := range errorPage.Status {
+		httpCodeRange, err := parseHTTPCodeRange(status)
+		if err != nil {
+			return nil, err
+		}
+		httpCodeRanges = append(httpCodeRanges, httpCodeRange)
+	}
+	return &ErrorPagesHandler{
+		httpCodeRanges: httpCodeRanges,
+		backendHandler: backendHandler,
+		query:          errorPage.Query,
+	}, nil
+}
+
+// parseHTTPCodeRange parses a status code (404) or a range of status codes (500-599)
+func parseHTTPCodeRange(status string) ([2]int, error) {
+	bounds := strings.SplitN(strings.TrimSpace(status), "-", 2)
+	var httpCodeRange [2]int
+	for i, bound := range bounds {
+		code, err := strconv.Atoi(strings.TrimSpace(bound))
+		if err != nil {
+			return httpCodeRange, fmt.Errorf("invalid status range %q", status)
+		}
+		httpCodeRange[i] = code
+	}
+	if len(bounds) == 1 {
+		httpCodeRange[1] = httpCodeRange[0]
+	}
+	if httpCodeRange[0] > httpCodeRange[1] {
+		return httpCodeRange, fmt.Errorf("invalid status range %q", status)
+	}
+	return httpCodeRange, nil
+}
+
+func (ep *ErrorPagesHandler) intercepts(code int) bool {
+	for _, httpCodeRange := range ep.httpCodeRanges {
+		if code >= httpCodeRange[0] && code <= httpCodeRange[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func (ep *ErrorPagesHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	recorder := &errorPagesResponseWriter{
+		responseWriter: rw,
+		header:         make(http.Header),
+		handler:        ep,
+	}
+	next(recorder, req)
+	if !recorder.headerWritten {
+		recorder.WriteHeader(http.StatusOK)
+	}
+	if !recorder.intercepted {
+		return
+	}
+
+	pageReq, err := ep.pageRequest(req, recorder.code)
+	if err != nil {
+		log.Errorf("Error creating error page request for %s: %v", req.URL, err)
+		http.Error(rw, http.StatusText(recorder.code), recorder.code)
+		return
+	}
+	page := &pageResponseWriter{header: make(http.Header), code: http.StatusOK}
+	ep.backendHandler.ServeHTTP(page, pageReq)
+	if page.code < 200 || page.code >= 300 {
+		log.Errorf("Error backend answered %d for error page %s", page.code, pageReq.URL)
+		http.Error(rw, http.StatusText(recorder.code), recorder.code)
+		return
+	}
+	utils.CopyHeaders(rw.Header(), page.header)
+	rw.Header().Del("Content-Length")
+	rw.WriteHeader(recorder.code)
+	rw.Write(page.body.Bytes())
+}
+
+// pageRequest returns the request of the error page for the status code,
+// replacing {status} in the query.
+func (ep *ErrorPagesHandler) pageRequest(req *http.Request, code int) (*http.Request, error) {
+	query := strings.Replace(ep.query, "{status}", strconv.Itoa(code), -1)
+	pageURL, err := url.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	u := *req.URL
+	u.Path = pageURL.Path
+	u.RawPath = pageURL.RawPath
+	u.RawQuery = pageURL.RawQuery
+	if len(u.Path) == 0 {
+		u.Path = "/"
+	}
+
+	pageReq := new(http.Request)
+	*pageReq = *req
+	pageReq.Method = http.MethodGet
+	pageReq.URL = &u
+	pageReq.RequestURI = u.RequestURI()
+	pageReq.Body = http.NoBody
+	pageReq.ContentLength = 0
+	pageReq.Header = make(http.Header)
+	utils.CopyHeaders(pageReq.Header, req.Header)
+	pageReq.Header.Del("Accept-Encoding")
+	return pageReq, nil
+}
+
+// pageResponseWriter buffers the response of the error backend
+type pageResponseWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (rw *pageResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *pageResponseWriter) WriteHeader(code int) {
+	rw.code = code
+}
+
+func (rw *pageResponseWriter) Write(buf []byte) (int, error) {
+	return rw.body.Write(buf)
+}
+
+// errorPagesResponseWriter forwards the response of the next handler, unless
+// its status is intercepted by the error pages handler, in which case the
+// response is discarded.
+type errorPagesResponseWriter struct {
+	responseWriter http.ResponseWriter
+	header         http.Header
+	handler        *ErrorPagesHandler
+	code           int
+	headerWritten  bool
+	intercepted    bool
+}
+
+func (rw *errorPagesResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *errorPagesResponseWriter) WriteHeader(code int) {
+	if rw.headerWritten {
+		return
+	}
+	rw.headerWritten = true
+	rw.code = code
+	rw.intercepted = rw.handler.intercepts(code)
+	if rw.intercepted {
+		return
+	}
+	utils.CopyHeaders(rw.responseWriter.Header(), rw.header)
+	rw.responseWriter.WriteHeader(code)
+}
+
+func (rw *errorPagesResponseWriter) Write(buf []byte) (int, error) {
+	if !rw.headerWritten {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.intercepted {
+		return len(buf), nil
+	}
+	return rw.responseWriter.Write(buf)
+}
+
+// Hijack hijacks the connection
+func (rw *errorPagesResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return rw.responseWriter.(http.Hijacker).Hijack()
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (rw *errorPagesResponseWriter) CloseNotify() <-chan bool {
+	return rw.responseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// Flush sends any buffered data to the client.
+func (rw *errorPagesResponseWriter) Flush() {
+	if rw.intercepted {
+		return
+	}
+	if !rw.headerWritten {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := rw.responseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,99 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorPagesHandler(t *testing.T) {
+	errorBackend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.html" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("page " + r.URL.RequestURI()))
+	})
+
+	cases := []struct {
+		desc            string
+		status          []string
+		query           string
+		backendCode     int
+		expectedCode    int
+		expectedBody    string
+		expectedHeaders map[string]string
+	}{
+		{
+			desc:            "status not intercepted",
+			status:          []string{"500-599"},
+			query:           "/{status}.html",
+			backendCode:     http.StatusOK,
+			expectedCode:    http.StatusOK,
+			expectedBody:    "backend",
+			expectedHeaders: map[string]string{"X-Backend": "1"},
+		},
+		{
+			desc:            "status in range",
+			status:          []string{"500-599"},
+			query:           "/{status}.html",
+			backendCode:     http.StatusBadGateway,
+			expectedCode:    http.StatusBadGateway,
+			expectedBody:    "page /502.html",
+			expectedHeaders: map[string]string{"Content-Type": "text/html", "X-Backend": ""},
+		},
+		{
+			desc:         "single status with query",
+			status:       []string{"404", "410"},
+			query:        "/errors?code={status}",
+			backendCode:  http.StatusNotFound,
+			expectedCode: http.StatusNotFound,
+			expectedBody: "page /errors?code=404",
+		},
+		{
+			desc:         "error backend failing",
+			status:       []string{"500"},
+			query:        "/missing.html",
+			backendCode:  http.StatusInternalServerError,
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: http.StatusText(http.StatusInternalServerError) + "\n",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.desc, func(t *testing.T) {
+			errorPages, err := NewErrorPagesHandler(&types.ErrorPage{Status: c.status, Query: c.query}, errorBackend)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			req := testhelpers.MustNewRequest(http.MethodPost, "http://localhost/foo", nil)
+			errorPages.ServeHTTP(recorder, req, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Backend", "1")
+				w.WriteHeader(c.backendCode)
+				w.Write([]byte("backend"))
+			})
+
+			assert.Equal(t, c.expectedCode, recorder.Code)
+			assert.Equal(t, c.expectedBody, recorder.Body.String())
+			for name, value := range c.expectedHeaders {
+				assert.Equal(t, value, recorder.Header().Get(name), "header %s", name)
+			}
+		})
+	}
+}
+
+func TestNewErrorPagesHandlerInvalidStatus(t *testing.T) {
+	for _, status := range []string{"", "abc", "599-500", "500-abc"} {
+		_, err := NewErrorPagesHandler(&types.ErrorPage{Status: []string{status}}, http.NotFoundHandler())
+		assert.Error(t, err, "status %q", status)
+	}
+	_, err := NewErrorPagesHandler(&types.ErrorPage{}, http.NotFoundHandler())
+	assert.Error(t, err)
+}
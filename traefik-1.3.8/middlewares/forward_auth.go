@@ -0,0 +1,159 @@
+package middlewares
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	"github.com/vulcand/oxy/forward"
+	"github.com/vulcand/oxy/utils"
+)
+
+const (
+	xForwardedURI    = "X-Forwarded-Uri"
+	xForwardedMethod = "X-Forwarded-Method"
+
+	defaultForwardAuthTimeout = 30 * time.Second
+)
+
+// forwardAuth delegates the authentication of the requests to an external
+// service
+type forwardAuth struct {
+	config *types.Forward
+	client *http.Client
+}
+
+func newForwardAuth(config *types.Forward) (*forwardAuth, error) {
+	if len(config.Address) == 0 {
+		return nil, fmt.Errorf("Error creating Authenticator: forward auth address is empty")
+	}
+	timeout := defaultForwardAuthTimeout
+	if len(config.Timeout) > 0 {
+		var err error
+		if timeout, err = time.ParseDuration(config.Timeout); err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("Error creating Authenticator: invalid forward auth timeout %q", config.Timeout)
+		}
+	}
+	// the redirections of the auth service are returned to the client
+	client := &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	if config.TLS != nil {
+		tlsConfig, err := config.TLS.CreateTLSConfig()
+		if err != nil {
+			return nil, fmt.Errorf("Error creating Authenticator TLS config: %v", err)
+		}
+		client.Transport = &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: timeout,
+			TLSClientConfig:       tlsConfig,
+		}
+	}
+	return &forwardAuth{config: config, client: client}, nil
+}
+
+func (f *forwardAuth) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	forwardReq, err := http.NewRequest(http.MethodGet, f.config.Address, nil)
+	if err != nil {
+		log.Errorf("Error creating forward auth request to %s: %v", f.config.Address, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	f.writeHeaders(r, forwardReq)
+
+	forwardResponse, err := f.client.Do(forwardReq)
+	if err != nil {
+		log.Errorf("Error calling forward auth %s: %v", f.config.Address, err)
+		status := http.StatusBadGateway
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			status = http.StatusGatewayTimeout
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	defer forwardResponse.Body.Close()
+	body, err := ioutil.ReadAll(forwardResponse.Body)
+	if err != nil {
+		log.Errorf("Error reading forward auth response from %s: %v", f.config.Address, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// the responses of the auth service other than 2xx are returned as is
+	if forwardResponse.StatusCode < http.StatusOK || forwardResponse.StatusCode >= http.StatusMultipleChoices {
+		log.Debugf("Forward auth failed with status %d", forwardResponse.StatusCode)
+		utils.CopyHeaders(w.Header(), forwardResponse.Header)
+		utils.RemoveHeaders(w.Header(), forward.HopHeaders...)
+		w.WriteHeader(forwardResponse.StatusCode)
+		w.Write(body)
+		return
+	}
+
+	log.Debugf("Forward auth success...")
+	for _, headerName := range f.config.AuthResponseHeaders {
+		if values, ok := forwardResponse.Header[http.CanonicalHeaderKey(headerName)]; ok {
+			r.Header[http.CanonicalHeaderKey(headerName)] = values
+		} else {
+			r.Header.Del(headerName)
+		}
+	}
+	next(w, r)
+}
+
+// writeHeaders copies the headers of the request to the auth request, along
+// with the X-Forwarded-* headers describing the request, which are taken from
+// the client only when they are trusted
+func (f *forwardAuth) writeHeaders(req *http.Request, forwardReq *http.Request) {
+	utils.CopyHeaders(forwardReq.Header, req.Header)
+	utils.RemoveHeaders(forwardReq.Header, forward.HopHeaders...)
+	trust := f.config.TrustForwardHeader
+
+	if clientIP, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		if prior, ok := req.Header[forward.XForwardedFor]; ok && trust {
+			clientIP = strings.Join(prior, ", ") + ", " + clientIP
+		}
+		forwardReq.Header.Set(forward.XForwardedFor, clientIP)
+	} else if !trust {
+		forwardReq.Header.Del(forward.XForwardedFor)
+	}
+
+	method := req.Method
+	if xfm := req.Header.Get(xForwardedMethod); len(xfm) > 0 && trust {
+		method = xfm
+	}
+	forwardReq.Header.Set(xForwardedMethod, method)
+
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+	if xfp := req.Header.Get(forward.XForwardedProto); len(xfp) > 0 && trust {
+		proto = xfp
+	}
+	forwardReq.Header.Set(forward.XForwardedProto, proto)
+
+	host := req.Host
+	if xfh := req.Header.Get(forward.XForwardedHost); len(xfh) > 0 && trust {
+		host = xfh
+	}
+	forwardReq.Header.Set(forward.XForwardedHost, host)
+
+	uri := req.URL.RequestURI()
+	if xfu := req.Header.Get(xForwardedURI); len(xfu) > 0 && trust {
+		uri = xfu
+	}
+	forwardReq.Header.Set(xForwardedURI, uri)
+}
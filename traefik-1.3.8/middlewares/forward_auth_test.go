@@ -0,0 +1,257 @@
+package middlewares
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codegangsta/negroni"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwardAuthFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Www-Authenticate", "Bearer")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	authMiddleware, err := NewAuthenticator(&types.Auth{
+		Forward: &types.Forward{
+			Address: server.URL,
+		},
+	})
+	require.NoError(t, err)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request forwarded despite the failed authentication")
+	})
+	n := negroni.New(authMiddleware)
+	n.UseHandler(handler)
+	ts := httptest.NewServer(n)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	assert.Equal(t, "Bearer", res.Header.Get("Www-Authenticate"))
+
+	body, err := ioutil.ReadAll(res.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "Forbidden\n", string(body))
+}
+
+func TestForwardAuthTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	authMiddleware, err := NewAuthenticator(&types.Auth{
+		Forward: &types.Forward{
+			Address: server.URL,
+			Timeout: "100ms",
+		},
+	})
+	require.NoError(t, err)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request forwarded despite the auth server timeout")
+	})
+	n := negroni.New(authMiddleware)
+	n.UseHandler(handler)
+	ts := httptest.NewServer(n)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusGatewayTimeout, res.StatusCode)
+
+	close(release)
+	server.Close()
+	res, err = http.Get(ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, res.StatusCode)
+}
+
+func TestForwardAuthRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://example.com/login", http.StatusFound)
+	}))
+	defer server.Close()
+
+	authMiddleware, err := NewAuthenticator(&types.Auth{
+		Forward: &types.Forward{
+			Address: server.URL,
+		},
+	})
+	require.NoError(t, err)
+
+	n := negroni.New(authMiddleware)
+	n.UseHandler(http.NotFoundHandler())
+	ts := httptest.NewServer(n)
+	defer ts.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	res, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusFound, res.StatusCode)
+	assert.Equal(t, "http://example.com/login", res.Header.Get("Location"))
+}
+
+func TestForwardAuthSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, http.MethodPost, r.Header.Get("X-Forwarded-Method"))
+		assert.Equal(t, "/foo?bar=baz", r.Header.Get("X-Forwarded-Uri"))
+		w.Header().Set("X-Auth-User", "user@example.com")
+		w.Header().Set("X-Auth-Secret", "secret")
+		fmt.Fprintln(w, "Success")
+	}))
+	defer server.Close()
+
+	authMiddleware, err := NewAuthenticator(&types.Auth{
+		Forward: &types.Forward{
+			Address:             server.URL,
+			AuthResponseHeaders: []string{"X-Auth-User", "X-Auth-Group"},
+		},
+	})
+	require.NoError(t, err)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "user@example.com", r.Header.Get("X-Auth-User"))
+		// the response headers which are not listed are not copied, and the
+		// listed ones are removed from the request when they are missing
+		assert.Empty(t, r.Header.Get("X-Auth-Secret"))
+		assert.Empty(t, r.Header.Get("X-Auth-Group"))
+		fmt.Fprintln(w, "traefik")
+	})
+	n := negroni.New(authMiddleware)
+	n.UseHandler(handler)
+	ts := httptest.NewServer(n)
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/foo?bar=baz", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("X-Auth-Group", "admin")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	body, err := ioutil.ReadAll(res.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "traefik\n", string(body))
+}
+
+func TestForwardAuthHeaders(t *testing.T) {
+	tests := []struct {
+		desc               string
+		trustForwardHeader bool
+		expected           http.Header
+	}{
+		{
+			desc: "untrusted forward headers",
+			expected: http.Header{
+				"X-Forwarded-For":    []string{"10.0.0.1"},
+				"X-Forwarded-Method": []string{http.MethodGet},
+				"X-Forwarded-Proto":  []string{"http"},
+				"X-Forwarded-Host":   []string{"foo.bar"},
+				"X-Forwarded-Uri":    []string{"/path?q=1"},
+			},
+		},
+		{
+			desc:               "trusted forward headers",
+			trustForwardHeader: true,
+			expected: http.Header{
+				"X-Forwarded-For":    []string{"192.168.0.1, 10.0.0.1"},
+				"X-Forwarded-Method": []string{http.MethodPost},
+				"X-Forwarded-Proto":  []string{"https"},
+				"X-Forwarded-Host":   []string{"example.com"},
+				"X-Forwarded-Uri":    []string{"/original"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodGet, "http://foo.bar/path?q=1", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			req.Header.Set("X-Forwarded-For", "192.168.0.1")
+			req.Header.Set("X-Forwarded-Method", http.MethodPost)
+			req.Header.Set("X-Forwarded-Proto", "https")
+			req.Header.Set("X-Forwarded-Host", "example.com")
+			req.Header.Set("X-Forwarded-Uri", "/original")
+			req.Header.Set("Connection", "close")
+
+			forwardReq, err := http.NewRequest(http.MethodGet, "http://auth.server", nil)
+			require.NoError(t, err)
+			auth := &forwardAuth{config: &types.Forward{TrustForwardHeader: test.trustForwardHeader}}
+			auth.writeHeaders(req, forwardReq)
+
+			for name, values := range test.expected {
+				assert.Equal(t, values, forwardReq.Header[name], name)
+			}
+			assert.Empty(t, forwardReq.Header.Get("Connection"))
+		})
+	}
+}
+
+func TestForwardAuthInvalid(t *testing.T) {
+	_, err := NewAuthenticator(&types.Auth{Forward: &types.Forward{}})
+	assert.Error(t, err)
+
+	_, err = NewAuthenticator(&types.Auth{Forward: &types.Forward{Address: "http://auth.server", Timeout: "never"}})
+	assert.Error(t, err)
+
+	_, err = NewAuthenticator(&types.Auth{
+		Forward: &types.Forward{
+			Address: "http://auth.server",
+			TLS:     &types.ClientTLS{Cert: "foo", Key: "bar"},
+		},
+	})
+	assert.Error(t, err)
+}
+
+func TestForwardAuthTLSWithCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	authMiddleware, err := NewAuthenticator(&types.Auth{
+		Forward: &types.Forward{
+			Address: server.URL,
+			TLS:     &types.ClientTLS{CA: string(ca)},
+		},
+	})
+	require.NoError(t, err)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "traefik")
+	})
+	n := negroni.New(authMiddleware)
+	n.UseHandler(handler)
+	ts := httptest.NewServer(n)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, err := ioutil.ReadAll(res.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "traefik\n", string(body))
+}
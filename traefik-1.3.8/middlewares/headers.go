@@ -0,0 +1,194 @@
+package middlewares
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/containous/traefik/types"
+)
+
+// Headers is a middleware that sets or removes the headers of the requests
+// forwarded to the backend, and of the responses sent back to the client, and
+// which applies the security options of the frontend
+type Headers struct {
+	headers *types.Headers
+}
+
+// NewHeaders creates a Headers middleware
+func NewHeaders(headers *types.Headers) *Headers {
+	return &Headers{headers: headers}
+}
+
+func (h *Headers) ServeHTTP(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	if !h.headers.IsDevelopment {
+		if !h.isAllowedHost(req.Host) {
+			http.Error(rw, "Bad Host", http.StatusInternalServerError)
+			return
+		}
+		if h.headers.SSLRedirect && !h.isSSL(req) {
+			h.redirectToSSL(rw, req)
+			return
+		}
+	}
+
+	for name, value := range h.headers.CustomRequestHeaders {
+		if http.CanonicalHeaderKey(name) == "Host" {
+			// the host of the request is not in its headers
+			if len(value) > 0 {
+				req.Host = value
+			}
+			continue
+		}
+		if len(value) == 0 {
+			req.Header.Del(name)
+		} else {
+			req.Header.Set(name, value)
+		}
+	}
+
+	responseHeaders := h.responseHeaders(req)
+	if len(responseHeaders) == 0 && len(h.headers.CustomResponseHeaders) == 0 {
+		next(rw, req)
+		return
+	}
+	next(&headersResponseWriter{responseWriter: rw, headers: h, responseHeaders: responseHeaders}, req)
+}
+
+func (h *Headers) isAllowedHost(host string) bool {
+	if len(h.headers.AllowedHosts) == 0 {
+		return true
+	}
+	for _, allowedHost := range h.headers.AllowedHosts {
+		if strings.EqualFold(allowedHost, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSSL tells whether the request was received over TLS, by this entrypoint or
+// by a proxy setting one of the SSL proxy headers
+func (h *Headers) isSSL(req *http.Request) bool {
+	if req.TLS != nil {
+		return true
+	}
+	for name, value := range h.headers.SSLProxyHeaders {
+		if req.Header.Get(name) == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Headers) redirectToSSL(rw http.ResponseWriter, req *http.Request) {
+	u := *req.URL
+	u.Scheme = "https"
+	u.Host = req.Host
+	if len(h.headers.SSLHost) > 0 {
+		u.Host = h.headers.SSLHost
+	}
+	code := http.StatusMovedPermanently
+	if h.headers.SSLTemporaryRedirect {
+		code = http.StatusTemporaryRedirect
+	}
+	http.Redirect(rw, req, u.String(), code)
+}
+
+// responseHeaders returns the security headers of the response to the request
+func (h *Headers) responseHeaders(req *http.Request) map[string]string {
+	responseHeaders := map[string]string{}
+	if h.headers.STSSeconds > 0 && !h.headers.IsDevelopment && (h.headers.ForceSTSHeader || h.isSSL(req)) {
+		sts := fmt.Sprintf("max-age=%d", h.headers.STSSeconds)
+		if h.headers.STSIncludeSubdomains {
+			sts += "; includeSubdomains"
+		}
+		if h.headers.STSPreload {
+			sts += "; preload"
+		}
+		responseHeaders["Strict-Transport-Security"] = sts
+	}
+	if len(h.headers.CustomFrameOptionsValue) > 0 {
+		responseHeaders["X-Frame-Options"] = h.headers.CustomFrameOptionsValue
+	} else if h.headers.FrameDeny {
+		responseHeaders["X-Frame-Options"] = "DENY"
+	}
+	if h.headers.ContentTypeNosniff {
+		responseHeaders["X-Content-Type-Options"] = "nosniff"
+	}
+	if h.headers.BrowserXSSFilter {
+		responseHeaders["X-XSS-Protection"] = "1; mode=block"
+	}
+	if len(h.headers.ContentSecurityPolicy) > 0 {
+		responseHeaders["Content-Security-Policy"] = h.headers.ContentSecurityPolicy
+	}
+	if len(h.headers.ReferrerPolicy) > 0 {
+		responseHeaders["Referrer-Policy"] = h.headers.ReferrerPolicy
+	}
+	return responseHeaders
+}
+
+// headersResponseWriter modifies the headers of the response right before
+// they are written
+type headersResponseWriter struct {
+	responseWriter  http.ResponseWriter
+	headers         *Headers
+	responseHeaders map[string]string
+	headerWritten   bool
+}
+
+func (rw *headersResponseWriter) Header() http.Header {
+	return rw.responseWriter.Header()
+}
+
+func (rw *headersResponseWriter) WriteHeader(code int) {
+	if rw.headerWritten {
+		return
+	}
+	rw.headerWritten = true
+	header := rw.responseWriter.Header()
+	// the security headers override the ones of the backend, the custom
+	// response headers override both
+	for name, value := range rw.responseHeaders {
+		header.Set(name, value)
+	}
+	for name, value := range rw.headers.headers.CustomResponseHeaders {
+		if len(value) == 0 {
+			header.Del(name)
+		} else {
+			header.Set(name, value)
+		}
+	}
+	rw.responseWriter.WriteHeader(code)
+}
+
+func (rw *headersResponseWriter) Write(buf []byte) (int, error) {
+	if !rw.headerWritten {
+		rw.WriteHeader(http.StatusOK)
+	}
+	return rw.responseWriter.Write(buf)
+}
+
+// Hijack hijacks the connection
+func (rw *headersResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return rw.responseWriter.(http.Hijacker).Hijack()
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (rw *headersResponseWriter) CloseNotify() <-chan bool {
+	return rw.responseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// Flush sends any buffered data to the client.
+func (rw *headersResponseWriter) Flush() {
+	if !rw.headerWritten {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := rw.responseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,171 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaders(t *testing.T) {
+	headers := NewHeaders(&types.Headers{
+		CustomRequestHeaders: map[string]string{
+			"X-Custom-Request": "foo",
+			"X-Removed":        "",
+			"Host":             "backend.localhost",
+		},
+		CustomResponseHeaders: map[string]string{
+			"X-Custom-Response": "bar",
+			"server":            "",
+		},
+	})
+
+	recorder := httptest.NewRecorder()
+	req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost/foo", nil)
+	req.Header.Set("X-Removed", "1")
+	req.Header.Set("X-Kept", "1")
+	headers.ServeHTTP(recorder, req, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "foo", r.Header.Get("X-Custom-Request"))
+		assert.Equal(t, "", r.Header.Get("X-Removed"))
+		assert.Equal(t, "1", r.Header.Get("X-Kept"))
+		assert.Equal(t, "backend.localhost", r.Host)
+
+		w.Header().Set("Server", "backend")
+		w.Header().Set("X-Custom-Response", "backend")
+		w.Write([]byte("backend"))
+	})
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "backend", recorder.Body.String())
+	assert.Equal(t, "bar", recorder.Header().Get("X-Custom-Response"))
+	assert.Equal(t, "", recorder.Header().Get("Server"))
+}
+
+func TestHeadersSecurity(t *testing.T) {
+	cases := []struct {
+		desc             string
+		headers          *types.Headers
+		url              string
+		requestHeaders   map[string]string
+		expectedCode     int
+		expectedLocation string
+		expectedHeaders  map[string]string
+	}{
+		{
+			desc:         "allowed host",
+			headers:      &types.Headers{AllowedHosts: []string{"foo.com", "Bar.com"}},
+			url:          "http://bar.com/foo",
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc:         "bad host",
+			headers:      &types.Headers{AllowedHosts: []string{"foo.com"}},
+			url:          "http://bar.com/foo",
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			desc:         "bad host in development",
+			headers:      &types.Headers{AllowedHosts: []string{"foo.com"}, IsDevelopment: true},
+			url:          "http://bar.com/foo",
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc:             "ssl redirect",
+			headers:          &types.Headers{SSLRedirect: true},
+			url:              "http://foo.com/foo?bar=1",
+			expectedCode:     http.StatusMovedPermanently,
+			expectedLocation: "https://foo.com/foo?bar=1",
+		},
+		{
+			desc:             "temporary ssl redirect to ssl host",
+			headers:          &types.Headers{SSLRedirect: true, SSLTemporaryRedirect: true, SSLHost: "secure.foo.com"},
+			url:              "http://foo.com/foo",
+			expectedCode:     http.StatusTemporaryRedirect,
+			expectedLocation: "https://secure.foo.com/foo",
+		},
+		{
+			desc:           "ssl proxy header",
+			headers:        &types.Headers{SSLRedirect: true, SSLProxyHeaders: map[string]string{"X-Forwarded-Proto": "https"}, STSSeconds: 315360000, STSIncludeSubdomains: true, STSPreload: true},
+			url:            "http://foo.com/foo",
+			requestHeaders: map[string]string{"X-Forwarded-Proto": "https"},
+			expectedCode:   http.StatusOK,
+			expectedHeaders: map[string]string{
+				"Strict-Transport-Security": "max-age=315360000; includeSubdomains; preload",
+			},
+		},
+		{
+			desc:            "no sts header without ssl",
+			headers:         &types.Headers{STSSeconds: 315360000},
+			url:             "http://foo.com/foo",
+			expectedCode:    http.StatusOK,
+			expectedHeaders: map[string]string{"Strict-Transport-Security": ""},
+		},
+		{
+			desc:            "forced sts header",
+			headers:         &types.Headers{STSSeconds: 315360000, ForceSTSHeader: true},
+			url:             "http://foo.com/foo",
+			expectedCode:    http.StatusOK,
+			expectedHeaders: map[string]string{"Strict-Transport-Security": "max-age=315360000"},
+		},
+		{
+			desc: "response headers",
+			headers: &types.Headers{
+				FrameDeny:             true,
+				ContentTypeNosniff:    true,
+				BrowserXSSFilter:      true,
+				ContentSecurityPolicy: "default-src 'self'",
+				ReferrerPolicy:        "same-origin",
+			},
+			url:          "http://foo.com/foo",
+			expectedCode: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"X-Frame-Options":         "DENY",
+				"X-Content-Type-Options":  "nosniff",
+				"X-Xss-Protection":        "1; mode=block",
+				"Content-Security-Policy": "default-src 'self'",
+				"Referrer-Policy":         "same-origin",
+			},
+		},
+		{
+			desc: "custom frame options overridden by custom response header",
+			headers: &types.Headers{
+				FrameDeny:               true,
+				CustomFrameOptionsValue: "SAMEORIGIN",
+				ContentTypeNosniff:      true,
+				CustomResponseHeaders:   map[string]string{"X-Content-Type-Options": ""},
+			},
+			url:          "http://foo.com/foo",
+			expectedCode: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"X-Frame-Options":        "SAMEORIGIN",
+				"X-Content-Type-Options": "",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.desc, func(t *testing.T) {
+			headers := NewHeaders(c.headers)
+
+			recorder := httptest.NewRecorder()
+			req := testhelpers.MustNewRequest(http.MethodGet, c.url, nil)
+			for name, value := range c.requestHeaders {
+				req.Header.Set(name, value)
+			}
+			headers.ServeHTTP(recorder, req, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Frame-Options", "ALLOW")
+				w.Write([]byte("backend"))
+			})
+
+			assert.Equal(t, c.expectedCode, recorder.Code)
+			assert.Equal(t, c.expectedLocation, recorder.Header().Get("Location"))
+			for name, value := range c.expectedHeaders {
+				assert.Equal(t, value, recorder.Header().Get(name), "header %s", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package middlewares
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	"github.com/dgrijalva/jwt-go"
+	"gopkg.in/square/go-jose.v1"
+)
+
+const (
+	defaultJWKSRefreshInterval = 15 * time.Minute
+	// jwksMinRefreshInterval bounds the refreshes of the JWKS document caused
+	// by tokens signed with an unknown key
+	jwksMinRefreshInterval = 30 * time.Second
+	jwksFetchTimeout       = 10 * time.Second
+)
+
+var errJWTUnknownKey = errors.New("no key matching the token")
+
+// jwtAuth authenticates the requests with the bearer tokens they carry
+type jwtAuth struct {
+	config      *types.JWT
+	headerField string
+	keys        []interface{}
+	jwks        *jwks
+	parser      *jwt.Parser
+}
+
+func newJWTAuth(config *types.JWT, headerField string) (*jwtAuth, error) {
+	j := &jwtAuth{
+		config:      config,
+		headerField: headerField,
+		// the HMAC methods are not accepted, so that public keys can't be used
+		// as shared secrets
+		parser: &jwt.Parser{ValidMethods: []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}, UseJSONNumber: true},
+	}
+	for _, key := range config.Keys {
+		publicKey, err := parsePublicKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating Authenticator: invalid JWT key: %v", err)
+		}
+		j.keys = append(j.keys, publicKey)
+	}
+	if len(config.JWKSURL) > 0 || len(config.JWKSFile) > 0 {
+		refreshInterval := defaultJWKSRefreshInterval
+		if len(config.JWKSRefreshInterval) > 0 {
+			var err error
+			if refreshInterval, err = time.ParseDuration(config.JWKSRefreshInterval); err != nil || refreshInterval <= 0 {
+				return nil, fmt.Errorf("Error creating Authenticator: invalid JWKS refresh interval %q", config.JWKSRefreshInterval)
+			}
+		}
+		j.jwks = &jwks{
+			url:             config.JWKSURL,
+			file:            config.JWKSFile,
+			refreshInterval: refreshInterval,
+			client:          &http.Client{Timeout: jwksFetchTimeout},
+		}
+		// the JWKS files are checked upfront, the services may not be up yet
+		if len(config.JWKSFile) > 0 {
+			if err := j.jwks.refresh(); err != nil {
+				return nil, fmt.Errorf("Error creating Authenticator: %v", err)
+			}
+		}
+	}
+	if len(j.keys) == 0 && j.jwks == nil {
+		return nil, errors.New("Error creating Authenticator: no JWT key nor JWKS")
+	}
+	return j, nil
+}
+
+func (j *jwtAuth) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	tokenString := bearerToken(r)
+	if len(tokenString) == 0 {
+		log.Debugf("JWT auth failed: no bearer token")
+		w.Header().Set("WWW-Authenticate", `Bearer realm="traefik"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	claims, err := j.validate(tokenString)
+	if err != nil {
+		log.Debugf("JWT auth failed: %v", err)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="traefik", error="invalid_token"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	log.Debugf("JWT auth success...")
+	if len(j.headerField) > 0 {
+		r.Header[j.headerField] = []string{claimString(claims["sub"])}
+	}
+	// the headers of the client are replaced, so that they can't be spoofed
+	for claim, header := range j.config.ClaimHeaders {
+		r.Header.Del(header)
+		if value, ok := claims[claim]; ok && value != nil {
+			r.Header.Set(header, claimString(value))
+		}
+	}
+	next(w, r)
+}
+
+// validate checks the signature, the dates and the claims of the token
+func (j *jwtAuth) validate(tokenString string) (jwt.MapClaims, error) {
+	var token *jwt.Token
+	var err error
+	for _, key := range j.candidateKeys(tokenString) {
+		token, err = j.parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			return key, nil
+		})
+		if err == nil {
+			break
+		}
+		// the other keys are only tried when the signature doesn't match
+		if validationErr, ok := err.(*jwt.ValidationError); !ok || validationErr.Errors != jwt.ValidationErrorSignatureInvalid {
+			return nil, err
+		}
+	}
+	if token == nil {
+		return nil, errJWTUnknownKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if len(j.config.Issuers) > 0 {
+		issuer, _ := claims["iss"].(string)
+		if !containsString(j.config.Issuers, issuer) {
+			return nil, fmt.Errorf("issuer %q not accepted", issuer)
+		}
+	}
+	if len(j.config.Audiences) > 0 && !j.audienceAccepted(claims["aud"]) {
+		return nil, fmt.Errorf("audience %v not accepted", claims["aud"])
+	}
+	for _, claim := range j.config.RequiredClaims {
+		if _, ok := claims[claim]; !ok {
+			return nil, fmt.Errorf("missing claim %s", claim)
+		}
+	}
+	return claims, nil
+}
+
+// candidateKeys returns the keys which may have signed the token: the keys of
+// the JWKS with the ID of the token, or all the keys if it has none
+func (j *jwtAuth) candidateKeys(tokenString string) []interface{} {
+	keys := append([]interface{}{}, j.keys...)
+	if j.jwks != nil {
+		kid := ""
+		if parts := strings.Split(tokenString, "."); len(parts) == 3 {
+			var header struct {
+				Kid string `json:"kid"`
+			}
+			if segment, err := jwt.DecodeSegment(parts[0]); err == nil && json.Unmarshal(segment, &header) == nil {
+				kid = header.Kid
+			}
+		}
+		keys = append(keys, j.jwks.keys(kid)...)
+	}
+	return keys
+}
+
+func (j *jwtAuth) audienceAccepted(audience interface{}) bool {
+	switch aud := audience.(type) {
+	case string:
+		return containsString(j.config.Audiences, aud)
+	case []interface{}:
+		for _, value := range aud {
+			if s, ok := value.(string); ok && containsString(j.config.Audiences, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jwks caches the keys of a JWKS document
+type jwks struct {
+	url             string
+	file            string
+	refreshInterval time.Duration
+	client          *http.Client
+
+	mutex       sync.Mutex
+	jsonKeys    []jose.JsonWebKey
+	loaded      time.Time
+	lastAttempt time.Time
+}
+
+// keys returns the public keys with the ID, or all the public keys when the ID
+// is empty. The document is reloaded when it is older than the refresh
+// interval, or when no key has the ID. A single request reloads it at a time,
+// the others keep using the cached keys meanwhile.
+func (k *jwks) keys(kid string) []interface{} {
+	k.mutex.Lock()
+	now := time.Now()
+	keys := k.matchingKeys(kid)
+	refresh := now.Sub(k.lastAttempt) > jwksMinRefreshInterval && (now.Sub(k.loaded) > k.refreshInterval || (len(keys) == 0 && len(kid) > 0))
+	if refresh {
+		k.lastAttempt = now
+	}
+	k.mutex.Unlock()
+
+	if !refresh || k.load(now) != nil {
+		return keys
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	return k.matchingKeys(kid)
+}
+
+func (k *jwks) matchingKeys(kid string) []interface{} {
+	var keys []interface{}
+	for _, key := range k.jsonKeys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if len(kid) == 0 || key.KeyID == kid {
+			keys = append(keys, key.Key)
+		}
+	}
+	return keys
+}
+
+func (k *jwks) refresh() error {
+	now := time.Now()
+	k.mutex.Lock()
+	k.lastAttempt = now
+	k.mutex.Unlock()
+	return k.load(now)
+}
+
+// load reads the document without holding the lock, which can take up to
+// jwksFetchTimeout, then replaces the keys. The previous keys are kept on
+// errors.
+func (k *jwks) load(attempt time.Time) error {
+	data, err := k.read()
+	if err != nil {
+		log.Errorf("Error loading JWKS: %v", err)
+		return err
+	}
+	var keySet jose.JsonWebKeySet
+	if err := json.Unmarshal(data, &keySet); err != nil {
+		log.Errorf("Error parsing JWKS: %v", err)
+		return fmt.Errorf("invalid JWKS: %v", err)
+	}
+	var keys []jose.JsonWebKey
+	for _, key := range keySet.Keys {
+		switch key.Key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+			keys = append(keys, key)
+		default:
+			log.Debugf("Ignoring JWKS key %s which is not a public key", key.KeyID)
+		}
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	// a more recent attempt may have completed meanwhile
+	if k.loaded.After(attempt) {
+		return nil
+	}
+	k.jsonKeys = keys
+	k.loaded = attempt
+	return nil
+}
+
+func (k *jwks) read() ([]byte, error) {
+	if len(k.file) > 0 {
+		return ioutil.ReadFile(k.file)
+	}
+	resp, err := k.client.Get(k.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, k.url)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// parsePublicKey parses a PEM public key or certificate, which is either a
+// path or the content itself
+func parsePublicKey(key string) (interface{}, error) {
+	data := []byte(key)
+	if _, err := os.Stat(key); err == nil {
+		if data, err = ioutil.ReadFile(key); err != nil {
+			return nil, err
+		}
+	}
+	if rsaKey, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return rsaKey, nil
+	}
+	if ecKey, err := jwt.ParseECPublicKeyFromPEM(data); err == nil {
+		return ecKey, nil
+	}
+	return nil, errors.New("not a PEM RSA or ECDSA public key")
+}
+
+func bearerToken(r *http.Request) string {
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+		return strings.TrimSpace(authorization[7:])
+	}
+	return ""
+}
+
+// claimString formats a claim for a header, the lists are comma separated
+func claimString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			values = append(values, claimString(item))
+		}
+		return strings.Join(values, ",")
+	case map[string]interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,260 @@
+package middlewares
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codegangsta/negroni"
+	"github.com/containous/traefik/types"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v1"
+)
+
+func TestJWTAuth(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPEM := publicKeyPEM(t, &rsaKey.PublicKey)
+
+	authMiddleware, err := NewAuthenticator(&types.Auth{
+		JWT: &types.JWT{
+			Keys:           []string{rsaPEM, publicKeyPEM(t, &ecKey.PublicKey)},
+			Issuers:        []string{"https://issuer.example.com"},
+			Audiences:      []string{"api"},
+			RequiredClaims: []string{"scope"},
+			ClaimHeaders:   map[string]string{"email": "X-Auth-Email", "groups": "X-Auth-Groups", "iat": "X-Auth-Issued"},
+		},
+		HeaderField: "X-WebAuth-User",
+	})
+	require.NoError(t, err)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user := r.Header["X-WebAuth-User"]; len(user) > 0 {
+			w.Header().Set("X-User", user[0])
+		}
+		w.Header().Set("X-Email", r.Header.Get("X-Auth-Email"))
+		w.Header().Set("X-Groups", r.Header.Get("X-Auth-Groups"))
+		w.Header().Set("X-Issued", r.Header.Get("X-Auth-Issued"))
+	})
+	n := negroni.New(authMiddleware)
+	n.UseHandler(handler)
+
+	now := time.Now().Unix()
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub":    "user1",
+			"iss":    "https://issuer.example.com",
+			"aud":    []string{"other", "api"},
+			"exp":    now + 60,
+			"iat":    1500000000,
+			"scope":  "read",
+			"email":  "user1@example.com",
+			"groups": []string{"admin", "dev"},
+		}
+	}
+	withClaim := func(name string, value interface{}) jwt.MapClaims {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		desc          string
+		authorization string
+		expected      int
+	}{
+		{desc: "valid RSA token", authorization: "Bearer " + signToken(t, jwt.SigningMethodRS256, rsaKey, validClaims()), expected: http.StatusOK},
+		{desc: "valid ECDSA token", authorization: "bearer " + signToken(t, jwt.SigningMethodES256, ecKey, validClaims()), expected: http.StatusOK},
+		{desc: "no token", expected: http.StatusUnauthorized},
+		{desc: "basic credentials", authorization: "Basic dGVzdDp0ZXN0", expected: http.StatusUnauthorized},
+		{desc: "malformed token", authorization: "Bearer foo.bar", expected: http.StatusUnauthorized},
+		{desc: "unknown key", authorization: "Bearer " + signToken(t, jwt.SigningMethodRS256, otherKey, validClaims()), expected: http.StatusUnauthorized},
+		{desc: "public key used as HMAC secret", authorization: "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte(rsaPEM), validClaims()), expected: http.StatusUnauthorized},
+		{desc: "expired token", authorization: "Bearer " + signToken(t, jwt.SigningMethodRS256, rsaKey, withClaim("exp", now-60)), expected: http.StatusUnauthorized},
+		{desc: "token not valid yet", authorization: "Bearer " + signToken(t, jwt.SigningMethodRS256, rsaKey, withClaim("nbf", now+60)), expected: http.StatusUnauthorized},
+		{desc: "unknown issuer", authorization: "Bearer " + signToken(t, jwt.SigningMethodRS256, rsaKey, withClaim("iss", "https://evil.example.com")), expected: http.StatusUnauthorized},
+		{desc: "unknown audience", authorization: "Bearer " + signToken(t, jwt.SigningMethodRS256, rsaKey, withClaim("aud", "other")), expected: http.StatusUnauthorized},
+		{desc: "missing required claim", authorization: "Bearer " + signToken(t, jwt.SigningMethodRS256, rsaKey, withClaim("scope", nil)), expected: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+			if len(test.authorization) > 0 {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			req.Header.Set("X-Auth-Email", "spoofed@example.com")
+			recorder := httptest.NewRecorder()
+			n.ServeHTTP(recorder, req)
+
+			assert.Equal(t, test.expected, recorder.Code)
+			if test.expected == http.StatusOK {
+				assert.Equal(t, "user1", recorder.Header().Get("X-User"))
+				assert.Equal(t, "user1@example.com", recorder.Header().Get("X-Email"))
+				assert.Equal(t, "admin,dev", recorder.Header().Get("X-Groups"))
+				assert.Equal(t, "1500000000", recorder.Header().Get("X-Issued"))
+			} else {
+				assert.Contains(t, recorder.Header().Get("WWW-Authenticate"), "Bearer")
+			}
+		})
+	}
+}
+
+func TestJWTAuthJWKS(t *testing.T) {
+	key1, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	key2, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	var mutex sync.Mutex
+	keySet := jose.JsonWebKeySet{Keys: []jose.JsonWebKey{{Key: &key1.PublicKey, KeyID: "key1", Use: "sig"}}}
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		fetches++
+		json.NewEncoder(w).Encode(keySet)
+	}))
+	defer server.Close()
+
+	auth, err := newJWTAuth(&types.JWT{JWKSURL: server.URL}, "")
+	require.NoError(t, err)
+	// the document is fetched on the first request
+	assert.Equal(t, 0, fetches)
+
+	claims := jwt.MapClaims{"sub": "user1"}
+	token1 := signTokenWithID(t, jwt.SigningMethodRS256, key1, "key1", claims)
+	token2 := signTokenWithID(t, jwt.SigningMethodES256, key2, "key2", claims)
+
+	_, err = auth.validate(token1)
+	assert.NoError(t, err)
+	_, err = auth.validate(token1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fetches)
+
+	// the key rotation is only fetched once the refreshes are allowed again
+	mutex.Lock()
+	keySet.Keys = append(keySet.Keys, jose.JsonWebKey{Key: &key2.PublicKey, KeyID: "key2"})
+	mutex.Unlock()
+	_, err = auth.validate(token2)
+	assert.Equal(t, errJWTUnknownKey, err)
+	assert.Equal(t, 1, fetches)
+
+	auth.jwks.lastAttempt = time.Now().Add(-jwksMinRefreshInterval - time.Second)
+	_, err = auth.validate(token2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fetches)
+
+	// the keys are kept when the document can't be fetched
+	server.Close()
+	auth.jwks.loaded = time.Time{}
+	auth.jwks.lastAttempt = time.Time{}
+	_, err = auth.validate(token1)
+	assert.NoError(t, err)
+}
+
+func TestJWTAuthJWKSSlowRefresh(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keySet := jose.JsonWebKeySet{Keys: []jose.JsonWebKey{{Key: &key.PublicKey, KeyID: "key", Use: "sig"}}}
+
+	var once sync.Once
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("slow") == "true" {
+			once.Do(func() { close(fetching) })
+			<-release
+		}
+		json.NewEncoder(w).Encode(keySet)
+	}))
+	defer server.Close()
+
+	auth, err := newJWTAuth(&types.JWT{JWKSURL: server.URL}, "")
+	require.NoError(t, err)
+	token := signTokenWithID(t, jwt.SigningMethodRS256, key, "key", jwt.MapClaims{"sub": "user1"})
+	_, err = auth.validate(token)
+	require.NoError(t, err)
+
+	// the request refreshing the expired document waits for the JWKS URL,
+	// the other ones are authenticated with the cached keys
+	auth.jwks.url = server.URL + "?slow=true"
+	auth.jwks.mutex.Lock()
+	auth.jwks.loaded = time.Time{}
+	auth.jwks.lastAttempt = time.Time{}
+	auth.jwks.mutex.Unlock()
+	refreshed := make(chan error)
+	go func() {
+		_, err := auth.validate(token)
+		refreshed <- err
+	}()
+	<-fetching
+
+	validated := make(chan error)
+	go func() {
+		_, err := auth.validate(token)
+		validated <- err
+	}()
+	select {
+	case err := <-validated:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Error("expected the request to be authenticated while the JWKS is refreshed")
+	}
+
+	close(release)
+	assert.NoError(t, <-refreshed)
+}
+
+func TestNewJWTAuthInvalid(t *testing.T) {
+	for _, config := range []*types.JWT{
+		{},
+		{Keys: []string{"not a key"}},
+		{JWKSURL: "http://localhost/jwks", JWKSRefreshInterval: "never"},
+		{JWKSFile: "/does/not/exist.json"},
+	} {
+		_, err := NewAuthenticator(&types.Auth{JWT: config})
+		assert.Error(t, err, "config %+v", config)
+	}
+}
+
+func publicKeyPEM(t *testing.T, key interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	return signTokenWithID(t, method, key, "", claims)
+}
+
+func signTokenWithID(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if len(kid) > 0 {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
@@ -0,0 +1,192 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	"github.com/go-kit/kit/metrics"
+)
+
+const (
+	// defaultMirrorMaxBodySize is the size of the largest request body mirrored
+	defaultMirrorMaxBodySize = 1048576
+	// maxMirrorsInFlight bounds the mirrored requests in progress, beyond which
+	// the requests are dropped instead of mirrored
+	maxMirrorsInFlight = 100
+)
+
+// Mirror is a middleware that sends a copy of a percentage of the requests to
+// other backends, asynchronously, and discards their responses
+type Mirror struct {
+	backends    []*mirrorBackend
+	maxBodySize int64
+	// mirrored and dropped count the requests by mirror backend, they may be nil
+	mirrored metrics.Counter
+	dropped  metrics.Counter
+	inFlight chan struct{}
+}
+
+type mirrorBackend struct {
+	name    string
+	handler http.Handler
+	percent uint64
+
+	mutex sync.Mutex
+	total uint64
+	count uint64
+}
+
+// NewMirror creates a Mirror middleware sending the requests to the handlers
+// of the mirror backends, by mirror backend name. It counts the mirrored and
+// dropped requests with the counters if they are not nil.
+func NewMirror(mirror *types.Mirror, handlers map[string]http.Handler, mirrored, dropped metrics.Counter) (*Mirror, error) {
+	var names []string
+	for name := range mirror.Backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	m := &Mirror{
+		maxBodySize: mirror.MaxBodySize,
+		mirrored:    mirrored,
+		dropped:     dropped,
+		inFlight:    make(chan struct{}, maxMirrorsInFlight),
+	}
+	if m.maxBodySize <= 0 {
+		m.maxBodySize = defaultMirrorMaxBodySize
+	}
+	for _, name := range names {
+		backend := mirror.Backends[name]
+		if backend.Percent < 0 || backend.Percent > 100 {
+			return nil, fmt.Errorf("percent of mirror %s must be between 0 and 100", name)
+		}
+		handler, ok := handlers[name]
+		if !ok {
+			return nil, fmt.Errorf("no handler for mirror %s", name)
+		}
+		m.backends = append(m.backends, &mirrorBackend{name: name, handler: handler, percent: uint64(backend.Percent)})
+	}
+	return m, nil
+}
+
+func (m *Mirror) ServeHTTP(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	var sampled []*mirrorBackend
+	for _, backend := range m.backends {
+		if backend.sample() {
+			sampled = append(sampled, backend)
+		}
+	}
+	if len(sampled) == 0 {
+		next(rw, req)
+		return
+	}
+
+	body, err := m.readBody(req)
+	if err != nil {
+		log.Debugf("Not mirroring %s: %v", req.URL, err)
+		for _, backend := range sampled {
+			m.count(m.dropped, backend)
+		}
+		next(rw, req)
+		return
+	}
+	for _, backend := range sampled {
+		select {
+		case m.inFlight <- struct{}{}:
+			m.count(m.mirrored, backend)
+			go m.mirror(backend, copyMirrorRequest(req, body))
+		default:
+			log.Debugf("Too many mirrored requests in progress, dropping %s for mirror %s", req.URL, backend.name)
+			m.count(m.dropped, backend)
+		}
+	}
+	next(rw, req)
+}
+
+// readBody reads the body of the request up to the maximum size, and puts back
+// what it read in front of the rest of the body
+func (m *Mirror) readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, m.maxBodySize+1))
+	req.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > m.maxBodySize {
+		return nil, fmt.Errorf("body larger than %d bytes", m.maxBodySize)
+	}
+	return body, nil
+}
+
+func (m *Mirror) mirror(backend *mirrorBackend, req *http.Request) {
+	defer func() { <-m.inFlight }()
+	backend.handler.ServeHTTP(&discardResponseWriter{header: make(http.Header)}, req)
+}
+
+func (m *Mirror) count(counter metrics.Counter, backend *mirrorBackend) {
+	if counter != nil {
+		counter.With("backend", backend.name).Add(1)
+	}
+}
+
+// sample tells whether the next request is mirrored, so that the share of the
+// mirrored requests stays at the percentage
+func (b *mirrorBackend) sample() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.total++
+	if b.count*100 < b.total*b.percent {
+		b.count++
+		return true
+	}
+	return false
+}
+
+// copyMirrorRequest copies the request to send it to a mirror, independently
+// of the original request which may be done before the mirrored one
+func copyMirrorRequest(req *http.Request, body []byte) *http.Request {
+	outReq := req.WithContext(context.Background())
+	outURL := *req.URL
+	outReq.URL = &outURL
+	outReq.Header = make(http.Header)
+	for name, values := range req.Header {
+		outReq.Header[name] = append([]string(nil), values...)
+	}
+	outReq.Body = http.NoBody
+	if len(body) > 0 {
+		outReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	outReq.ContentLength = int64(len(body))
+	outReq.TransferEncoding = nil
+	return outReq
+}
+
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// discardResponseWriter discards the responses of the mirrors
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (rw *discardResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *discardResponseWriter) Write(buf []byte) (int, error) {
+	return len(buf), nil
+}
+
+func (rw *discardResponseWriter) WriteHeader(code int) {}
@@ -0,0 +1,121 @@
+package middlewares
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirror(t *testing.T) {
+	mirrored := make(chan string, 10)
+	mirrorHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mirrored <- r.Header.Get("X-Id") + ":" + string(body)
+		w.Write([]byte("mirror"))
+	})
+	counters := &countersByLabels{counts: map[string]float64{}}
+	mirror, err := NewMirror(&types.Mirror{
+		Backends: map[string]*types.MirrorBackend{
+			"half": {Backend: "shadow", Percent: 50},
+			"none": {Backend: "shadow"},
+		},
+		MaxBodySize: 4,
+	}, map[string]http.Handler{"half": mirrorHandler, "none": mirrorHandler}, counters.With("counter", "mirrored"), counters.With("counter", "dropped"))
+	require.NoError(t, err)
+
+	for i, body := range []string{"a", "b", "toolong", "d", "e", "f"} {
+		recorder := httptest.NewRecorder()
+		req := testhelpers.MustNewRequest(http.MethodPost, "http://localhost/foo", strings.NewReader(body))
+		req.Header.Set("X-Id", string(rune('0'+i)))
+		mirror.ServeHTTP(recorder, req, func(w http.ResponseWriter, r *http.Request) {
+			received, _ := ioutil.ReadAll(r.Body)
+			w.Write(received)
+		})
+		// the backend receives the whole body, even when it is not mirrored
+		assert.Equal(t, body, recorder.Body.String())
+	}
+
+	var actual []string
+	for i := 0; i < 2; i++ {
+		actual = append(actual, <-mirrored)
+	}
+	sort.Strings(actual)
+	assert.Equal(t, []string{"0:a", "4:e"}, actual)
+	assert.Equal(t, map[string]float64{
+		"counter=mirrored=backend=half=": 2,
+		"counter=dropped=backend=half=":  1,
+	}, counters.counts)
+	assert.Empty(t, mirrored)
+}
+
+func TestMirrorWithoutBody(t *testing.T) {
+	mirrored := make(chan *http.Request, 1)
+	mirror, err := NewMirror(&types.Mirror{
+		Backends: map[string]*types.MirrorBackend{"all": {Backend: "shadow", Percent: 100}},
+	}, map[string]http.Handler{"all": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrored <- r
+	})}, nil, nil)
+	require.NoError(t, err)
+
+	req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost/foo?bar=baz", nil)
+	req.Header.Set("X-Foo", "bar")
+	mirror.ServeHTTP(httptest.NewRecorder(), req, func(w http.ResponseWriter, r *http.Request) {
+		// changes of the original request after the copy are not mirrored
+		r.Header.Set("X-Foo", "changed")
+	})
+
+	mirroredReq := <-mirrored
+	assert.Equal(t, "bar", mirroredReq.Header.Get("X-Foo"))
+	assert.Equal(t, "/foo?bar=baz", mirroredReq.URL.RequestURI())
+	assert.Equal(t, http.NoBody, mirroredReq.Body)
+}
+
+func TestNewMirrorInvalid(t *testing.T) {
+	handlers := map[string]http.Handler{"foo": http.NotFoundHandler()}
+	for _, mirror := range []*types.Mirror{
+		{Backends: map[string]*types.MirrorBackend{"foo": {Backend: "shadow", Percent: 101}}},
+		{Backends: map[string]*types.MirrorBackend{"bar": {Backend: "shadow", Percent: 10}}},
+	} {
+		_, err := NewMirror(mirror, handlers, nil, nil)
+		assert.Error(t, err, "mirror %+v", mirror)
+	}
+}
+
+func TestMirrorBodyReadError(t *testing.T) {
+	mirror, err := NewMirror(&types.Mirror{
+		Backends: map[string]*types.MirrorBackend{"all": {Backend: "shadow", Percent: 100}},
+	}, map[string]http.Handler{"all": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request mirrored despite the read error")
+	})}, nil, nil)
+	require.NoError(t, err)
+
+	req := testhelpers.MustNewRequest(http.MethodPost, "http://localhost/foo", ioutil.NopCloser(&errorReader{bytes.NewBufferString("abc")}))
+	var received []byte
+	var readErr error
+	mirror.ServeHTTP(httptest.NewRecorder(), req, func(w http.ResponseWriter, r *http.Request) {
+		received, readErr = ioutil.ReadAll(r.Body)
+	})
+	assert.Equal(t, "abc", string(received))
+	assert.Error(t, readErr)
+}
+
+// errorReader fails once its content is read
+type errorReader struct {
+	buf *bytes.Buffer
+}
+
+func (r *errorReader) Read(p []byte) (int, error) {
+	if r.buf.Len() == 0 {
+		return 0, assert.AnError
+	}
+	return r.buf.Read(p)
+}
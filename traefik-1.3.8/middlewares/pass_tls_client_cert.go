@@ -0,0 +1,78 @@
+package middlewares
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/containous/traefik/types"
+)
+
+// PassTLSClientCert is a middleware that sets the TLS client certificate of the
+// requests, or some of its fields, in the headers forwarded to the backend
+type PassTLSClientCert struct {
+	config *types.PassTLSClientCert
+}
+
+// NewPassTLSClientCert creates a PassTLSClientCert middleware
+func NewPassTLSClientCert(config *types.PassTLSClientCert) *PassTLSClientCert {
+	return &PassTLSClientCert{config: config}
+}
+
+func (p *PassTLSClientCert) ServeHTTP(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	// the headers of the client are removed, so that they can't be spoofed
+	for _, header := range p.headers() {
+		req.Header.Del(header)
+	}
+	if req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
+		p.setHeaders(req, req.TLS.PeerCertificates[0])
+	}
+	next(rw, req)
+}
+
+func (p *PassTLSClientCert) headers() []string {
+	var headers []string
+	for _, header := range []string{p.config.PEM, p.config.SubjectCN, p.config.SANs, p.config.SerialNumber, p.config.Issuer, p.config.NotBefore, p.config.NotAfter} {
+		if len(header) > 0 {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
+
+func (p *PassTLSClientCert) setHeaders(req *http.Request, cert *x509.Certificate) {
+	setHeader := func(header string, value string) {
+		if len(header) > 0 && len(value) > 0 {
+			req.Header.Set(header, value)
+		}
+	}
+	if len(p.config.PEM) > 0 {
+		setHeader(p.config.PEM, url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))))
+	}
+	setHeader(p.config.SubjectCN, cert.Subject.CommonName)
+	if len(p.config.SANs) > 0 {
+		setHeader(p.config.SANs, strings.Join(subjectAltNames(cert), ","))
+	}
+	setHeader(p.config.SerialNumber, strings.ToUpper(cert.SerialNumber.Text(16)))
+	setHeader(p.config.Issuer, cert.Issuer.String())
+	setHeader(p.config.NotBefore, cert.NotBefore.UTC().Format(time.RFC3339))
+	setHeader(p.config.NotAfter, cert.NotAfter.UTC().Format(time.RFC3339))
+}
+
+// subjectAltNames returns the DNS names, the email addresses, the IP addresses
+// and the URIs of the certificate
+func subjectAltNames(cert *x509.Certificate) []string {
+	var names []string
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
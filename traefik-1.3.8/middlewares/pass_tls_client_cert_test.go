@@ -0,0 +1,122 @@
+package middlewares
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassTLSClientCert(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	notBefore := time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(0xABCDEF),
+		Subject:        pkix.Name{CommonName: "client.example.com", Organization: []string{"Example"}},
+		Issuer:         pkix.Name{CommonName: "client.example.com", Organization: []string{"Example"}},
+		NotBefore:      notBefore,
+		NotAfter:       notBefore.Add(365 * 24 * time.Hour),
+		DNSNames:       []string{"client.example.com", "www.example.com"},
+		EmailAddresses: []string{"client@example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc     string
+		config   *types.PassTLSClientCert
+		tls      *tls.ConnectionState
+		expected map[string]string
+	}{
+		{
+			desc: "all fields",
+			config: &types.PassTLSClientCert{
+				PEM:          "X-Client-Cert",
+				SubjectCN:    "X-Client-CN",
+				SANs:         "X-Client-SANs",
+				SerialNumber: "X-Client-Serial",
+				Issuer:       "X-Client-Issuer",
+				NotBefore:    "X-Client-Not-Before",
+				NotAfter:     "X-Client-Not-After",
+			},
+			tls: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}},
+			expected: map[string]string{
+				"X-Client-Cert":       url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))),
+				"X-Client-CN":         "client.example.com",
+				"X-Client-SANs":       "client.example.com,www.example.com,client@example.com,10.0.0.1",
+				"X-Client-Serial":     "ABCDEF",
+				"X-Client-Issuer":     "CN=client.example.com,O=Example",
+				"X-Client-Not-Before": "2017-01-01T00:00:00Z",
+				"X-Client-Not-After":  "2018-01-01T00:00:00Z",
+			},
+		},
+		{
+			desc:   "selected fields",
+			config: &types.PassTLSClientCert{SubjectCN: "X-Client-CN"},
+			tls:    &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}},
+			expected: map[string]string{
+				"X-Client-CN":   "client.example.com",
+				"X-Client-Cert": "spoofed",
+			},
+		},
+		{
+			desc: "no client certificate",
+			config: &types.PassTLSClientCert{
+				PEM:       "X-Client-Cert",
+				SubjectCN: "X-Client-CN",
+			},
+			tls: &tls.ConnectionState{},
+			expected: map[string]string{
+				"X-Client-Cert": "",
+				"X-Client-CN":   "",
+			},
+		},
+		{
+			desc:   "no TLS",
+			config: &types.PassTLSClientCert{SubjectCN: "X-Client-CN"},
+			expected: map[string]string{
+				"X-Client-CN":   "",
+				"X-Client-Cert": "spoofed",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodGet, "https://localhost/", nil)
+			req.TLS = test.tls
+			req.Header.Set("X-Client-Cert", "spoofed")
+			req.Header.Set("X-Client-CN", "spoofed")
+
+			var forwarded http.Header
+			NewPassTLSClientCert(test.config).ServeHTTP(httptest.NewRecorder(), req, func(rw http.ResponseWriter, r *http.Request) {
+				forwarded = r.Header
+			})
+
+			require.NotNil(t, forwarded)
+			for header, value := range test.expected {
+				assert.Equal(t, value, forwarded.Get(header), header)
+			}
+		})
+	}
+}
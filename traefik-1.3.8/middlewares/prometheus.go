@@ -10,8 +10,13 @@ import (
 )
 
 const (
-	reqsName    = "traefik_requests_total"
-	latencyName = "traefik_request_duration_seconds"
+	reqsName              = "traefik_requests_total"
+	latencyName           = "traefik_request_duration_seconds"
+	rateLimitRejectedName = "traefik_ratelimit_rejected_total"
+	mirroredName          = "traefik_mirror_requests_total"
+	mirrorDroppedName     = "traefik_mirror_dropped_total"
+	tlsRejectedName       = "traefik_tls_handshakes_rejected_total"
+	ocspStaplesName       = "traefik_tls_ocsp_staples"
 )
 
 // Prometheus is an Implementation for Metrics that exposes prometheus metrics for the latency
@@ -84,6 +89,69 @@ func NewPrometheus(name string, config *types.Prometheus) *Prometheus {
 	return &m
 }
 
+// NewPrometheusRateLimitCounter returns a prometheus counter of the requests
+// rejected by the rate limits, partitioned by frontend and rate set.
+func NewPrometheusRateLimitCounter() metrics.Counter {
+	return newPrometheusCounter(rateLimitRejectedName, "How many HTTP requests rejected by the rate limits, partitioned by frontend and rate set.", "frontend", "rateset")
+}
+
+// NewPrometheusMirrorCounters returns the prometheus counters of the mirrored
+// requests and of the requests dropped instead of mirrored, partitioned by
+// frontend and mirror backend.
+func NewPrometheusMirrorCounters() (mirrored metrics.Counter, dropped metrics.Counter) {
+	return newPrometheusCounter(mirroredName, "How many HTTP requests mirrored, partitioned by frontend and mirror backend.", "frontend", "backend"),
+		newPrometheusCounter(mirrorDroppedName, "How many HTTP requests not mirrored because of their size or of the mirrored requests in progress, partitioned by frontend and mirror backend.", "frontend", "backend")
+}
+
+// NewPrometheusTLSRejectedCounter returns a prometheus counter of the TLS
+// handshakes rejected by the strict SNI mode, partitioned by entrypoint and
+// reason.
+func NewPrometheusTLSRejectedCounter() metrics.Counter {
+	return newPrometheusCounter(tlsRejectedName, "How many TLS handshakes rejected because no certificate matches their server name, partitioned by entrypoint and reason.", "entrypoint", "reason")
+}
+
+// NewPrometheusOCSPGauge returns a prometheus gauge of the served certificates,
+// partitioned by the status of their OCSP staple.
+func NewPrometheusOCSPGauge() metrics.Gauge {
+	gv := stdprometheus.NewGaugeVec(
+		stdprometheus.GaugeOpts{
+			Name: ocspStaplesName,
+			Help: "How many served TLS certificates, partitioned by the status of their OCSP staple.",
+		},
+		[]string{"status"},
+	)
+
+	err := stdprometheus.Register(gv)
+	if err != nil {
+		e, ok := err.(stdprometheus.AlreadyRegisteredError)
+		if !ok {
+			panic(err)
+		}
+		return prometheus.NewGauge(e.ExistingCollector.(*stdprometheus.GaugeVec))
+	}
+	return prometheus.NewGauge(gv)
+}
+
+func newPrometheusCounter(name string, help string, labelNames ...string) metrics.Counter {
+	cv := stdprometheus.NewCounterVec(
+		stdprometheus.CounterOpts{
+			Name: name,
+			Help: help,
+		},
+		labelNames,
+	)
+
+	err := stdprometheus.Register(cv)
+	if err != nil {
+		e, ok := err.(stdprometheus.AlreadyRegisteredError)
+		if !ok {
+			panic(err)
+		}
+		return prometheus.NewCounter(e.ExistingCollector.(*stdprometheus.CounterVec))
+	}
+	return prometheus.NewCounter(cv)
+}
+
 func (p *Prometheus) handler() http.Handler {
 	return promhttp.Handler()
 }
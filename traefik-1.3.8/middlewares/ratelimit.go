@@ -0,0 +1,180 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	"github.com/go-kit/kit/metrics"
+	"github.com/vulcand/oxy/utils"
+)
+
+// RateLimit is a middleware that limits the rate of the requests of each
+// source with a token bucket per rate set
+type RateLimit struct {
+	extractor utils.SourceExtractor
+	rates     []*rate
+	// rejected counts the rejected requests by rate set, it may be nil
+	rejected metrics.Counter
+	clock    func() time.Time
+
+	mutex     sync.Mutex
+	buckets   map[string][]*tokenBucket
+	lastSweep time.Time
+	// refillDuration is the longest time it takes to refill an empty bucket,
+	// after which an unused bucket is full again
+	refillDuration time.Duration
+}
+
+type rate struct {
+	name string
+	// tokens per second
+	average float64
+	burst   float64
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimit creates a RateLimit middleware, which counts the requests it
+// rejects with the rejected counter if it is not nil
+func NewRateLimit(rateLimit *types.RateLimit, rejected metrics.Counter) (*RateLimit, error) {
+	if len(rateLimit.RateSet) == 0 {
+		return nil, errors.New("no rate set")
+	}
+	extractorFunc := rateLimit.ExtractorFunc
+	if len(extractorFunc) == 0 {
+		extractorFunc = "client.ip"
+	}
+	extractor, err := utils.NewExtractor(extractorFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range rateLimit.RateSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rl := &RateLimit{
+		extractor: extractor,
+		rejected:  rejected,
+		clock:     time.Now,
+		buckets:   map[string][]*tokenBucket{},
+	}
+	for _, name := range names {
+		rateSet := rateLimit.RateSet[name]
+		period, err := time.ParseDuration(rateSet.Period)
+		if err != nil {
+			return nil, fmt.Errorf("invalid period for rate set %s: %v", name, err)
+		}
+		if period <= 0 || rateSet.Average <= 0 {
+			return nil, fmt.Errorf("period and average of rate set %s must be positive", name)
+		}
+		burst := rateSet.Burst
+		if burst <= 0 {
+			burst = rateSet.Average
+		}
+		r := &rate{
+			name:    name,
+			average: float64(rateSet.Average) / period.Seconds(),
+			burst:   float64(burst),
+		}
+		rl.rates = append(rl.rates, r)
+		if refillDuration := time.Duration(r.burst / r.average * float64(time.Second)); refillDuration > rl.refillDuration {
+			rl.refillDuration = refillDuration
+		}
+	}
+	return rl, nil
+}
+
+func (rl *RateLimit) ServeHTTP(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	source, amount, err := rl.extractor.Extract(req)
+	if err != nil {
+		log.Errorf("Error extracting the rate limiting source of %s: %v", req.URL, err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	delay, rateName := rl.consume(source, float64(amount))
+	if delay > 0 {
+		if rl.rejected != nil {
+			rl.rejected.With("rateset", rateName).Add(1)
+		}
+		rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+		http.Error(rw, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+	next(rw, req)
+}
+
+// consume takes the amount of tokens from every bucket of the source, unless
+// one of them does not hold enough tokens, in which case it returns how long
+// to wait before retrying and the name of the rate set of the bucket.
+func (rl *RateLimit) consume(source string, amount float64) (time.Duration, string) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	now := rl.clock()
+	rl.sweep(now)
+
+	buckets, ok := rl.buckets[source]
+	if !ok {
+		for _, r := range rl.rates {
+			buckets = append(buckets, &tokenBucket{tokens: r.burst, updated: now})
+		}
+		rl.buckets[source] = buckets
+	}
+
+	var delay time.Duration
+	var rateName string
+	for i, r := range rl.rates {
+		bucket := buckets[i]
+		bucket.tokens = math.Min(r.burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*r.average)
+		bucket.updated = now
+		if bucket.tokens < amount {
+			missing := time.Duration((amount - bucket.tokens) / r.average * float64(time.Second))
+			if missing > delay {
+				delay, rateName = missing, r.name
+			}
+		}
+	}
+	if delay > 0 {
+		return delay, rateName
+	}
+	for _, bucket := range buckets {
+		bucket.tokens -= amount
+	}
+	return 0, ""
+}
+
+// sweep removes the buckets which have not been used for long enough to be
+// full again
+func (rl *RateLimit) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < rl.refillDuration {
+		return
+	}
+	rl.lastSweep = now
+	for source, buckets := range rl.buckets {
+		unused := true
+		for _, bucket := range buckets {
+			if now.Sub(bucket.updated) < rl.refillDuration {
+				unused = false
+				break
+			}
+		}
+		if unused {
+			delete(rl.buckets, source)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/containous/traefik/types"
+	"github.com/go-kit/kit/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countersByLabels counts the values added to a counter by label values
+type countersByLabels struct {
+	labels []string
+	counts map[string]float64
+}
+
+func (c *countersByLabels) With(labelValues ...string) metrics.Counter {
+	return &countersByLabels{labels: append(append([]string{}, c.labels...), labelValues...), counts: c.counts}
+}
+
+func (c *countersByLabels) Add(delta float64) {
+	key := ""
+	for _, label := range c.labels {
+		key += label + "="
+	}
+	c.counts[key] += delta
+}
+
+func TestRateLimit(t *testing.T) {
+	rejected := &countersByLabels{counts: map[string]float64{}}
+	rateLimit, err := NewRateLimit(&types.RateLimit{
+		ExtractorFunc: "request.header.X-Api-Key",
+		RateSet: map[string]*types.Rate{
+			"second": {Period: "1s", Average: 1, Burst: 2},
+			"minute": {Period: "1m", Average: 3},
+		},
+	}, rejected)
+	require.NoError(t, err)
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	rateLimit.clock = func() time.Time { return now }
+
+	serve := func(apiKey string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost/foo", nil)
+		req.Header.Set("X-Api-Key", apiKey)
+		rateLimit.ServeHTTP(recorder, req, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("backend"))
+		})
+		return recorder
+	}
+
+	// burst of the second rate set
+	assert.Equal(t, http.StatusOK, serve("foo").Code)
+	assert.Equal(t, http.StatusOK, serve("foo").Code)
+	recorder := serve("foo")
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
+
+	// other sources have their own buckets
+	assert.Equal(t, http.StatusOK, serve("bar").Code)
+
+	// the minute rate set is exhausted after 3 requests
+	now = now.Add(time.Second)
+	assert.Equal(t, http.StatusOK, serve("foo").Code)
+	now = now.Add(time.Second)
+	recorder = serve("foo")
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "18", recorder.Header().Get("Retry-After"))
+
+	now = now.Add(18 * time.Second)
+	assert.Equal(t, http.StatusOK, serve("foo").Code)
+
+	assert.Equal(t, map[string]float64{"rateset=second=": 1, "rateset=minute=": 1}, rejected.counts)
+
+	// unused buckets are removed once they are full again
+	now = now.Add(time.Hour)
+	assert.Equal(t, http.StatusOK, serve("foo").Code)
+	assert.Len(t, rateLimit.buckets, 1)
+}
+
+func TestNewRateLimitInvalid(t *testing.T) {
+	for _, rateLimit := range []*types.RateLimit{
+		{},
+		{ExtractorFunc: "client.port", RateSet: map[string]*types.Rate{"foo": {Period: "1s", Average: 1}}},
+		{RateSet: map[string]*types.Rate{"foo": {Period: "1 second", Average: 1}}},
+		{RateSet: map[string]*types.Rate{"foo": {Period: "1s"}}},
+	} {
+		_, err := NewRateLimit(rateLimit, nil)
+		assert.Error(t, err, "rate limit %+v", rateLimit)
+	}
+}
@@ -0,0 +1,79 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/whitelist"
+)
+
+// Redirect is a middleware that redirects the requests whose URL matches a regex
+type Redirect struct {
+	regex       *regexp.Regexp
+	replacement string
+	permanent   bool
+	// trustedIPs are the peers whose X-Forwarded-Proto header is trusted, nil
+	// trusting no one
+	trustedIPs *whitelist.IP
+}
+
+// NewRedirect creates a Redirect middleware
+func NewRedirect(regex, replacement string, permanent bool) (*Redirect, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+	return &Redirect{regex: re, replacement: replacement, permanent: permanent}, nil
+}
+
+// WithTrustedIPs returns a copy of the redirect which takes the scheme of the
+// requests sent by the trusted IPs from their X-Forwarded-Proto header
+func (r *Redirect) WithTrustedIPs(trustedIPs *whitelist.IP) *Redirect {
+	redirect := *r
+	redirect.trustedIPs = trustedIPs
+	return &redirect
+}
+
+func (r *Redirect) ServeHTTP(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	oldURL := r.rawURL(req)
+	if !r.regex.MatchString(oldURL) {
+		next(rw, req)
+		return
+	}
+	newURL := r.regex.ReplaceAllString(oldURL, r.replacement)
+	if newURL == oldURL {
+		// redirecting to the same URL would loop
+		next(rw, req)
+		return
+	}
+	parsedURL, err := url.Parse(newURL)
+	if err != nil {
+		log.Errorf("Error redirecting %s to %s: %v", oldURL, newURL, err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	status := http.StatusFound
+	if r.permanent {
+		status = http.StatusMovedPermanently
+	}
+	http.Redirect(rw, req, parsedURL.String(), status)
+}
+
+// rawURL returns the URL requested by the client
+func (r *Redirect) rawURL(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	} else if r.trustedIPs != nil && req.Header.Get("X-Forwarded-Proto") == "https" {
+		if trusted, err := r.trustedIPs.Contains(req.RemoteAddr); err == nil && trusted {
+			scheme = "https"
+		}
+	}
+	requestURI := req.RequestURI
+	if len(requestURI) == 0 {
+		requestURI = req.URL.RequestURI()
+	}
+	return scheme + "://" + req.Host + requestURI
+}
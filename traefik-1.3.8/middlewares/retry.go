@@ -3,6 +3,7 @@ package middlewares
 import (
 	"bufio"
 	"bytes"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -32,13 +33,21 @@ func NewRetry(attempts int, next http.Handler) *Retry {
 func (retry *Retry) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	// if we might make multiple attempts, swap the body for an ioutil.NopCloser
 	// cf https://github.com/containous/traefik/issues/1008
+	var replayableBody io.Seeker
 	if retry.attempts > 1 {
 		body := r.Body
 		defer body.Close()
 		r.Body = ioutil.NopCloser(body)
+		// bodies read by the buffering middleware can be replayed
+		replayableBody, _ = body.(io.Seeker)
 	}
 	attempts := 1
 	for {
+		if attempts > 1 && replayableBody != nil {
+			if _, err := replayableBody.Seek(0, io.SeekStart); err != nil {
+				log.Errorf("Error replaying the body of %s: %v", r.URL, err)
+			}
+		}
 		recorder := NewRecorder()
 		recorder.responseWriter = rw
 		retry.next.ServeHTTP(recorder, r)
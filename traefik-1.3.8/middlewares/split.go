@@ -0,0 +1,166 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+)
+
+// WeightedSplit splits the requests of a frontend between several backends, in
+// proportion of their weights, which can be changed at runtime. When it is
+// sticky, the clients keep being sent to the backend of their first request,
+// which is stored in a cookie.
+type WeightedSplit struct {
+	cookieName string
+
+	mutex    sync.Mutex
+	backends []*splitBackend
+}
+
+type splitBackend struct {
+	name   string
+	weight int
+	// current is the smooth weighted round robin state of the backend
+	current int
+}
+
+// NewWeightedSplit creates a WeightedSplit with the weights of the split, and
+// which stores the backend of the clients in the cookie if it is sticky.
+func NewWeightedSplit(split *types.Split, cookieName string) (*WeightedSplit, error) {
+	var names []string
+	for name := range split.Backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s := &WeightedSplit{}
+	if split.Sticky {
+		s.cookieName = cookieName
+	}
+	for _, name := range names {
+		s.backends = append(s.backends, &splitBackend{name: name})
+	}
+	weights := map[string]int{}
+	for name, backend := range split.Backends {
+		weights[name] = backend.Weight
+	}
+	if err := s.SetWeights(weights); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Weights returns the current weights, by backend name
+func (s *WeightedSplit) Weights() map[string]int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	weights := map[string]int{}
+	for _, backend := range s.backends {
+		weights[backend.name] = backend.weight
+	}
+	return weights
+}
+
+// SetWeights changes the weights of some of the backends, by backend name. At
+// least one backend must keep a positive weight.
+func (s *WeightedSplit) SetWeights(weights map[string]int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for name, weight := range weights {
+		if s.backend(name) == nil {
+			return fmt.Errorf("unknown backend %s", name)
+		}
+		if weight < 0 {
+			return fmt.Errorf("negative weight for backend %s", name)
+		}
+	}
+	total := 0
+	for _, backend := range s.backends {
+		if weight, ok := weights[backend.name]; ok {
+			total += weight
+		} else {
+			total += backend.weight
+		}
+	}
+	if total <= 0 {
+		return errors.New("no backend with a positive weight")
+	}
+	for _, backend := range s.backends {
+		if weight, ok := weights[backend.name]; ok {
+			backend.weight = weight
+		}
+		backend.current = 0
+	}
+	return nil
+}
+
+// Handler returns the handler sending the requests to the handlers of the
+// backends, by backend name
+func (s *WeightedSplit) Handler(handlers map[string]http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		name := s.stickyBackend(req)
+		if len(name) == 0 {
+			name = s.next()
+			if len(s.cookieName) > 0 {
+				http.SetCookie(rw, &http.Cookie{Name: s.cookieName, Value: name, Path: "/"})
+			}
+		}
+		handler, ok := handlers[name]
+		if !ok {
+			log.Errorf("No handler for the split backend %s", name)
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+// stickyBackend returns the backend stored in the cookie of the request, if it
+// still has a positive weight
+func (s *WeightedSplit) stickyBackend(req *http.Request) string {
+	if len(s.cookieName) == 0 {
+		return ""
+	}
+	cookie, err := req.Cookie(s.cookieName)
+	if err != nil {
+		return ""
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if backend := s.backend(cookie.Value); backend != nil && backend.weight > 0 {
+		return backend.name
+	}
+	return ""
+}
+
+// next picks the next backend with the smooth weighted round robin, which
+// interleaves the backends instead of sending bursts of requests to each one
+func (s *WeightedSplit) next() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	total := 0
+	var best *splitBackend
+	for _, backend := range s.backends {
+		backend.current += backend.weight
+		total += backend.weight
+		if best == nil || backend.current > best.current {
+			best = backend
+		}
+	}
+	best.current -= total
+	return best.name
+}
+
+func (s *WeightedSplit) backend(name string) *splitBackend {
+	for _, backend := range s.backends {
+		if backend.name == name {
+			return backend
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func splitHandlers(names ...string) map[string]http.Handler {
+	handlers := map[string]http.Handler{}
+	for _, name := range names {
+		name := name
+		handlers[name] = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		})
+	}
+	return handlers
+}
+
+func TestWeightedSplit(t *testing.T) {
+	split, err := NewWeightedSplit(&types.Split{
+		Backends: map[string]*types.WeightedBackend{
+			"stable": {Backend: "v1", Weight: 3},
+			"canary": {Backend: "v2", Weight: 1},
+		},
+	}, "_split")
+	require.NoError(t, err)
+	handler := split.Handler(splitHandlers("stable", "canary"))
+
+	var served []string
+	for i := 0; i < 8; i++ {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodGet, "http://localhost/", nil))
+		served = append(served, recorder.Body.String())
+		assert.Empty(t, recorder.Header().Get("Set-Cookie"))
+	}
+	// the backends are interleaved
+	assert.Equal(t, []string{"stable", "canary", "stable", "stable", "stable", "canary", "stable", "stable"}, served)
+
+	require.NoError(t, split.SetWeights(map[string]int{"stable": 0}))
+	assert.Equal(t, map[string]int{"stable": 0, "canary": 1}, split.Weights())
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodGet, "http://localhost/", nil))
+	assert.Equal(t, "canary", recorder.Body.String())
+}
+
+func TestWeightedSplitSticky(t *testing.T) {
+	split, err := NewWeightedSplit(&types.Split{
+		Backends: map[string]*types.WeightedBackend{
+			"stable": {Backend: "v1", Weight: 1},
+			"canary": {Backend: "v2", Weight: 1},
+		},
+		Sticky: true,
+	}, "_split")
+	require.NoError(t, err)
+	handler := split.Handler(splitHandlers("stable", "canary"))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodGet, "http://localhost/", nil))
+	assert.Equal(t, "canary", recorder.Body.String())
+	assert.Equal(t, "_split=canary; Path=/", recorder.Header().Get("Set-Cookie"))
+
+	for i := 0; i < 3; i++ {
+		recorder = httptest.NewRecorder()
+		req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost/", nil)
+		req.AddCookie(&http.Cookie{Name: "_split", Value: "canary"})
+		handler.ServeHTTP(recorder, req)
+		assert.Equal(t, "canary", recorder.Body.String())
+		assert.Empty(t, recorder.Header().Get("Set-Cookie"))
+	}
+
+	// the clients of a backend without weight are sent to another one
+	require.NoError(t, split.SetWeights(map[string]int{"canary": 0}))
+	recorder = httptest.NewRecorder()
+	req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost/", nil)
+	req.AddCookie(&http.Cookie{Name: "_split", Value: "canary"})
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, "stable", recorder.Body.String())
+	assert.Equal(t, "_split=stable; Path=/", recorder.Header().Get("Set-Cookie"))
+}
+
+func TestWeightedSplitInvalidWeights(t *testing.T) {
+	_, err := NewWeightedSplit(&types.Split{
+		Backends: map[string]*types.WeightedBackend{"stable": {Backend: "v1"}},
+	}, "_split")
+	assert.Error(t, err)
+
+	split, err := NewWeightedSplit(&types.Split{
+		Backends: map[string]*types.WeightedBackend{
+			"stable": {Backend: "v1", Weight: 95},
+			"canary": {Backend: "v2", Weight: 5},
+		},
+	}, "_split")
+	require.NoError(t, err)
+	for _, weights := range []map[string]int{
+		{"unknown": 1},
+		{"canary": -1},
+		{"stable": 0, "canary": 0},
+	} {
+		assert.Error(t, split.SetWeights(weights), "weights %v", weights)
+	}
+	assert.Equal(t, map[string]int{"stable": 95, "canary": 5}, split.Weights())
+}
@@ -44,12 +44,12 @@ var _ provider.Provider = (*Provider)(nil)
 // Provider holds configurations of the provider.
 type Provider struct {
 	provider.BaseProvider `mapstructure:",squash"`
-	Endpoint              string              `description:"Docker server endpoint. Can be a tcp or a unix socket endpoint"`
-	Domain                string              `description:"Default domain used"`
-	TLS                   *provider.ClientTLS `description:"Enable Docker TLS support"`
-	ExposedByDefault      bool                `description:"Expose containers by default"`
-	UseBindPortIP         bool                `description:"Use the ip address from the bound port, rather than from the inner network"`
-	SwarmMode             bool                `description:"Use Docker on Swarm Mode"`
+	Endpoint              string           `description:"Docker server endpoint. Can be a tcp or a unix socket endpoint"`
+	Domain                string           `description:"Default domain used"`
+	TLS                   *types.ClientTLS `description:"Enable Docker TLS support"`
+	ExposedByDefault      bool             `description:"Expose containers by default"`
+	UseBindPortIP         bool             `description:"Use the ip address from the bound port, rather than from the inner network"`
+	SwarmMode             bool             `description:"Use Docker on Swarm Mode"`
 }
 
 // dockerData holds the need data to the Provider p
@@ -250,7 +250,15 @@ func (p *Provider) loadDockerConfig(containersInspected []dockerData) *types.Con
 		"getPassHostHeader":           p.getPassHostHeader,
 		"getPriority":                 p.getPriority,
 		"getEntryPoints":              p.getEntryPoints,
-		"getBasicAuth":                p.getBasicAuth,
+		"getRedirect":                 p.getRedirect,
+		"getErrorPages":               p.getErrorPages,
+		"getHeaders":                  p.getHeaders,
+		"getRateLimit":                p.getRateLimit,
+		"getMirror":                   p.getMirror,
+		"getSplit":                    p.getSplit,
+		"getBuffering":                p.getBuffering,
+		"getAuth":                     p.getAuth,
+		"getPassTLSClientCert":        p.getPassTLSClientCert,
 		"getFrontendRule":             p.getFrontendRule,
 		"hasCircuitBreakerLabel":      p.hasCircuitBreakerLabel,
 		"getCircuitBreakerExpression": p.getCircuitBreakerExpression,
@@ -267,7 +275,14 @@ func (p *Provider) loadDockerConfig(containersInspected []dockerData) *types.Con
 		"getServiceWeight":            p.getServiceWeight,
 		"getServiceProtocol":          p.getServiceProtocol,
 		"getServiceEntryPoints":       p.getServiceEntryPoints,
-		"getServiceBasicAuth":         p.getServiceBasicAuth,
+		"getServiceRedirect":          p.getServiceRedirect,
+		"getServiceErrorPages":        p.getServiceErrorPages,
+		"getServiceHeaders":           p.getServiceHeaders,
+		"getServiceRateLimit":         p.getServiceRateLimit,
+		"getServiceMirror":            p.getServiceMirror,
+		"getServiceSplit":             p.getServiceSplit,
+		"getServiceAuth":              p.getServiceAuth,
+		"getServicePassTLSClientCert": p.getServicePassTLSClientCert,
 		"getServiceFrontendRule":      p.getServiceFrontendRule,
 		"getServicePassHostHeader":    p.getServicePassHostHeader,
 		"getServicePriority":          p.getServicePriority,
@@ -319,7 +334,7 @@ func (p *Provider) hasCircuitBreakerLabel(container dockerData) bool {
 
 // Regexp used to extract the name of the service and the name of the property for this service
 // All properties are under the format traefik.<servicename>.frontent.*= except the port/weight/protocol directly after traefik.<servicename>.
-var servicesPropertiesRegexp = regexp.MustCompile(`^traefik\.(?P<service_name>.*?)\.(?P<property_name>port|weight|protocol|frontend\.(.*))$`)
+var servicesPropertiesRegexp = regexp.MustCompile(`^traefik\.(?P<service_name>[^.]+?)\.(?P<property_name>port|weight|protocol|frontend\.(.*))$`)
 
 // Map of services properties
 // we can get it with label[serviceName][propertyName] and we got the propertyValue
@@ -379,13 +394,61 @@ func (p *Provider) getServiceEntryPoints(container dockerData, serviceName strin
 
 }
 
-// Extract basic auth from labels for a given service and a given docker container
-func (p *Provider) getServiceBasicAuth(container dockerData, serviceName string) []string {
-	if basicAuth, ok := getContainerServiceLabel(container, serviceName, "frontend.auth.basic"); ok {
-		return strings.Split(basicAuth, ",")
-	}
-	return p.getBasicAuth(container)
+// Extract auth from labels for a given service and a given docker container,
+// the auth labels of the service override the ones of the container
+func (p *Provider) getServiceAuth(container dockerData, serviceName string) *types.Auth {
+	return provider.GetAuth(getServiceFrontendLabels(container, serviceName, "frontend.auth."))
+}
+
+// Extract the TLS client certificate headers from labels for a given service
+// and a given docker container
+func (p *Provider) getServicePassTLSClientCert(container dockerData, serviceName string) *types.PassTLSClientCert {
+	return provider.GetPassTLSClientCert(getServiceFrontendLabels(container, serviceName, "frontend.passTLSClientCert."))
+}
+
+// Extract the redirect from labels for a given service and a given docker container
+func (p *Provider) getServiceRedirect(container dockerData, serviceName string) *types.Redirect {
+	return provider.GetRedirect(getServiceFrontendLabels(container, serviceName, "frontend.redirect."))
+}
+
+// Extract the error pages from labels for a given service and a given docker container
+func (p *Provider) getServiceErrorPages(container dockerData, serviceName string) map[string]*types.ErrorPage {
+	return getErrorPages(getServiceFrontendLabels(container, serviceName, "frontend.errors."))
+}
+
+// Extract the headers from labels for a given service and a given docker container
+func (p *Provider) getServiceHeaders(container dockerData, serviceName string) *types.Headers {
+	return provider.GetHeaders(getServiceFrontendLabels(container, serviceName, "frontend.headers."))
+}
+
+// Extract the rate limit from labels for a given service and a given docker container
+func (p *Provider) getServiceRateLimit(container dockerData, serviceName string) *types.RateLimit {
+	return provider.GetRateLimit(getServiceFrontendLabels(container, serviceName, "frontend.rateLimit."))
+}
 
+// Extract the mirror from labels for a given service and a given docker container
+func (p *Provider) getServiceMirror(container dockerData, serviceName string) *types.Mirror {
+	return getMirror(getServiceFrontendLabels(container, serviceName, "frontend.mirror."))
+}
+
+// Extract the split from labels for a given service and a given docker container
+func (p *Provider) getServiceSplit(container dockerData, serviceName string) *types.Split {
+	return getSplit(getServiceFrontendLabels(container, serviceName, "frontend.split."))
+}
+
+// getServiceFrontendLabels returns the labels of the container, overridden by
+// the labels of the service whose property starts with the prefix
+func getServiceFrontendLabels(container dockerData, serviceName string, prefix string) map[string]string {
+	labels := map[string]string{}
+	for label, value := range container.Labels {
+		labels[label] = value
+	}
+	for property, value := range extractServicesLabels(container.Labels)[serviceName] {
+		if strings.HasPrefix(property, prefix) {
+			labels["traefik."+property] = value
+		}
+	}
+	return labels
 }
 
 // Extract passHostHeader from labels for a given service and a given docker container
@@ -677,12 +740,68 @@ func (p *Provider) getEntryPoints(container dockerData) []string {
 	return []string{}
 }
 
-func (p *Provider) getBasicAuth(container dockerData) []string {
-	if basicAuth, err := getLabel(container, "traefik.frontend.auth.basic"); err == nil {
-		return strings.Split(basicAuth, ",")
+func (p *Provider) getRedirect(container dockerData) *types.Redirect {
+	return provider.GetRedirect(container.Labels)
+}
+
+func (p *Provider) getErrorPages(container dockerData) map[string]*types.ErrorPage {
+	return getErrorPages(container.Labels)
+}
+
+func (p *Provider) getHeaders(container dockerData) *types.Headers {
+	return provider.GetHeaders(container.Labels)
+}
+
+func (p *Provider) getRateLimit(container dockerData) *types.RateLimit {
+	return provider.GetRateLimit(container.Labels)
+}
+
+func (p *Provider) getMirror(container dockerData) *types.Mirror {
+	return getMirror(container.Labels)
+}
+
+func (p *Provider) getSplit(container dockerData) *types.Split {
+	return getSplit(container.Labels)
+}
+
+func getErrorPages(labels map[string]string) map[string]*types.ErrorPage {
+	errorPages := provider.GetErrorPages(labels)
+	for _, errorPage := range errorPages {
+		errorPage.Backend = provider.Normalize(errorPage.Backend)
 	}
+	return errorPages
+}
 
-	return []string{}
+func getMirror(labels map[string]string) *types.Mirror {
+	mirror := provider.GetMirror(labels)
+	if mirror != nil {
+		for _, backend := range mirror.Backends {
+			backend.Backend = provider.Normalize(backend.Backend)
+		}
+	}
+	return mirror
+}
+
+func getSplit(labels map[string]string) *types.Split {
+	split := provider.GetSplit(labels)
+	if split != nil {
+		for _, backend := range split.Backends {
+			backend.Backend = provider.Normalize(backend.Backend)
+		}
+	}
+	return split
+}
+
+func (p *Provider) getBuffering(container dockerData) *types.Buffering {
+	return provider.GetBuffering(container.Labels)
+}
+
+func (p *Provider) getAuth(container dockerData) *types.Auth {
+	return provider.GetAuth(container.Labels)
+}
+
+func (p *Provider) getPassTLSClientCert(container dockerData) *types.PassTLSClientCert {
+	return provider.GetPassTLSClientCert(container.Labels)
 }
 
 func isContainerEnabled(container dockerData, exposedByDefault bool) bool {
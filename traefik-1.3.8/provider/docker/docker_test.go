@@ -825,7 +825,6 @@ func TestDockerLoadDockerConfig(t *testing.T) {
 					Backend:        "backend-test",
 					PassHostHeader: true,
 					EntryPoints:    []string{},
-					BasicAuth:      []string{},
 					Routes: map[string]types.Route{
 						"route-frontend-Host-test-docker-localhost": {
 							Rule: "Host:test.docker.localhost",
@@ -875,7 +874,11 @@ func TestDockerLoadDockerConfig(t *testing.T) {
 					Backend:        "backend-foobar",
 					PassHostHeader: true,
 					EntryPoints:    []string{"http", "https"},
-					BasicAuth:      []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/", "test2:$apr1$d9hr9HBB$4HxwgUir3HP4EsggP/QNo0"},
+					Auth: &types.Auth{
+						Basic: &types.Basic{
+							Users: []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/", "test2:$apr1$d9hr9HBB$4HxwgUir3HP4EsggP/QNo0"},
+						},
+					},
 					Routes: map[string]types.Route{
 						"route-frontend-Host-test1-docker-localhost": {
 							Rule: "Host:test1.docker.localhost",
@@ -886,7 +889,6 @@ func TestDockerLoadDockerConfig(t *testing.T) {
 					Backend:        "backend-foobar",
 					PassHostHeader: true,
 					EntryPoints:    []string{},
-					BasicAuth:      []string{},
 					Routes: map[string]types.Route{
 						"route-frontend-Host-test2-docker-localhost": {
 							Rule: "Host:test2.docker.localhost",
@@ -910,6 +912,167 @@ func TestDockerLoadDockerConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			containers: []docker.ContainerJSON{
+				containerJSON(
+					name("test1"),
+					labels(map[string]string{
+						"traefik.frontend.redirect.entryPoint":                  "https",
+						"traefik.frontend.redirect.permanent":                   "true",
+						"traefik.frontend.errors.server.status":                 "500-599",
+						"traefik.frontend.errors.server.backend":                "errors.com",
+						"traefik.frontend.errors.server.query":                  "/{status}.html",
+						"traefik.frontend.headers.customRequestHeaders.X-Env":   `prod "eu"`,
+						"traefik.frontend.headers.customResponseHeaders.Server": "",
+						"traefik.frontend.headers.allowedHosts":                 "test1.docker.localhost",
+						"traefik.frontend.headers.stsSeconds":                   "31536000",
+						"traefik.frontend.headers.contentSecurityPolicy":        `default-src 'self'`,
+						"traefik.frontend.rateLimit.extractorFunc":              "client.ip",
+						"traefik.frontend.rateLimit.rateSet.api.period":         "10s",
+						"traefik.frontend.rateLimit.rateSet.api.average":        "100",
+						"traefik.frontend.rateLimit.rateSet.api.burst":          "200",
+						"traefik.frontend.mirror.backends.v2.backend":           "test2",
+						"traefik.frontend.mirror.backends.v2.percent":           "10",
+						"traefik.frontend.auth.jwt.keys":                        "/etc/traefik/jwt.pem",
+						"traefik.frontend.auth.jwt.audiences":                   "test1",
+						"traefik.frontend.auth.jwt.claimHeaders.email":          "X-Auth-Email",
+						"traefik.frontend.auth.headerField":                     "X-WebAuth-User",
+					}),
+					ports(nat.PortMap{
+						"80/tcp": {},
+					}),
+					withNetwork("bridge", ipv4("127.0.0.1")),
+				),
+				containerJSON(
+					name("test2"),
+					labels(map[string]string{
+						"traefik.frontend.redirect.regex":                      `^https?://www\.test2\.docker\.localhost/(.*)`,
+						"traefik.frontend.redirect.replacement":                "https://test2.docker.localhost/o'clock/$1",
+						"traefik.backend.buffering.maxrequestbodybytes":        "1048576",
+						"traefik.backend.buffering.retryexpression":            `IsNetworkError() && RequestMethod() == "GET"`,
+						"traefik.frontend.split.sticky":                        "true",
+						"traefik.frontend.split.backends.stable.backend":       "test2",
+						"traefik.frontend.split.backends.stable.weight":        "9",
+						"traefik.frontend.split.backends.canary.backend":       "test1",
+						"traefik.frontend.split.backends.canary.weight":        "1",
+						"traefik.frontend.auth.forward.address":                "http://auth.docker.localhost/verify",
+						"traefik.frontend.auth.forward.authResponseHeaders":    "X-Auth-User",
+						"traefik.frontend.auth.forward.tls.insecureSkipVerify": "true",
+						"traefik.frontend.passTLSClientCert.pem":               "X-Forwarded-Tls-Client-Cert",
+						"traefik.frontend.passTLSClientCert.sans":              "X-Client-SANs",
+					}),
+					ports(nat.PortMap{
+						"80/tcp": {},
+					}),
+					withNetwork("bridge", ipv4("127.0.0.1")),
+				),
+			},
+			expectedFrontends: map[string]*types.Frontend{
+				"frontend-Host-test1-docker-localhost": {
+					Backend:        "backend-test1",
+					PassHostHeader: true,
+					EntryPoints:    []string{},
+					Redirect: &types.Redirect{
+						EntryPoint: "https",
+						Permanent:  true,
+					},
+					Errors: map[string]*types.ErrorPage{
+						"server": {
+							Status:  []string{"500-599"},
+							Backend: "backend-errors-com",
+							Query:   "/{status}.html",
+						},
+					},
+					Headers: &types.Headers{
+						CustomRequestHeaders:  map[string]string{"X-Env": `prod "eu"`},
+						CustomResponseHeaders: map[string]string{"Server": ""},
+						AllowedHosts:          []string{"test1.docker.localhost"},
+						STSSeconds:            31536000,
+						ContentSecurityPolicy: `default-src 'self'`,
+					},
+					RateLimit: &types.RateLimit{
+						ExtractorFunc: "client.ip",
+						RateSet: map[string]*types.Rate{
+							"api": {Period: "10s", Average: 100, Burst: 200},
+						},
+					},
+					Mirror: &types.Mirror{
+						Backends: map[string]*types.MirrorBackend{
+							"v2": {Backend: "backend-test2", Percent: 10},
+						},
+					},
+					Auth: &types.Auth{
+						JWT: &types.JWT{
+							Keys:         []string{"/etc/traefik/jwt.pem"},
+							Audiences:    []string{"test1"},
+							ClaimHeaders: map[string]string{"email": "X-Auth-Email"},
+						},
+						HeaderField: "X-WebAuth-User",
+					},
+					Routes: map[string]types.Route{
+						"route-frontend-Host-test1-docker-localhost": {
+							Rule: "Host:test1.docker.localhost",
+						},
+					},
+				},
+				"frontend-Host-test2-docker-localhost": {
+					Backend:        "backend-test2",
+					PassHostHeader: true,
+					EntryPoints:    []string{},
+					Redirect: &types.Redirect{
+						Regex:       `^https?://www\.test2\.docker\.localhost/(.*)`,
+						Replacement: "https://test2.docker.localhost/o'clock/$1",
+					},
+					Split: &types.Split{
+						Backends: map[string]*types.WeightedBackend{
+							"stable": {Backend: "backend-test2", Weight: 9},
+							"canary": {Backend: "backend-test1", Weight: 1},
+						},
+						Sticky: true,
+					},
+					Auth: &types.Auth{
+						Forward: &types.Forward{
+							Address:             "http://auth.docker.localhost/verify",
+							AuthResponseHeaders: []string{"X-Auth-User"},
+							TLS:                 &types.ClientTLS{InsecureSkipVerify: true},
+						},
+					},
+					PassTLSClientCert: &types.PassTLSClientCert{
+						PEM:  "X-Forwarded-Tls-Client-Cert",
+						SANs: "X-Client-SANs",
+					},
+					Routes: map[string]types.Route{
+						"route-frontend-Host-test2-docker-localhost": {
+							Rule: "Host:test2.docker.localhost",
+						},
+					},
+				},
+			},
+			expectedBackends: map[string]*types.Backend{
+				"backend-test1": {
+					Servers: map[string]types.Server{
+						"server-test1": {
+							URL:    "http://127.0.0.1:80",
+							Weight: 0,
+						},
+					},
+					CircuitBreaker: nil,
+				},
+				"backend-test2": {
+					Servers: map[string]types.Server{
+						"server-test2": {
+							URL:    "http://127.0.0.1:80",
+							Weight: 0,
+						},
+					},
+					CircuitBreaker: nil,
+					Buffering: &types.Buffering{
+						MaxRequestBodyBytes: 1048576,
+						RetryExpression:     `IsNetworkError() && RequestMethod() == "GET"`,
+					},
+				},
+			},
+		},
 		{
 			containers: []docker.ContainerJSON{
 				containerJSON(
@@ -933,7 +1096,6 @@ func TestDockerLoadDockerConfig(t *testing.T) {
 					Backend:        "backend-foobar",
 					PassHostHeader: true,
 					EntryPoints:    []string{"http", "https"},
-					BasicAuth:      []string{},
 					Routes: map[string]types.Route{
 						"route-frontend-Host-test1-docker-localhost": {
 							Rule: "Host:test1.docker.localhost",
@@ -330,9 +330,23 @@ func TestDockerLoadDockerServiceConfig(t *testing.T) {
 				containerJSON(
 					name("foo"),
 					labels(map[string]string{
-						"traefik.service.port":                 "2503",
-						"traefik.service.frontend.entryPoints": "http,https",
-						"traefik.service.frontend.auth.basic":  "test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/,test2:$apr1$d9hr9HBB$4HxwgUir3HP4EsggP/QNo0",
+						"traefik.service.port":                                   "2503",
+						"traefik.service.frontend.entryPoints":                   "http,https",
+						"traefik.service.frontend.auth.basic":                    "test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/,test2:$apr1$d9hr9HBB$4HxwgUir3HP4EsggP/QNo0",
+						"traefik.frontend.passTLSClientCert.pem":                 "X-Forwarded-Tls-Client-Cert",
+						"traefik.service.frontend.passTLSClientCert.pem":         "X-Client-Cert",
+						"traefik.frontend.redirect.entryPoint":                   "https",
+						"traefik.service.frontend.redirect.regex":                `^http://foo\.docker\.localhost/(.*)`,
+						"traefik.service.frontend.redirect.replacement":          "https://foo.docker.localhost/it's/$1",
+						"traefik.service.frontend.errors.server.status":          "500-599",
+						"traefik.service.frontend.errors.server.backend":         "errors.com",
+						"traefik.service.frontend.headers.sslRedirect":           "true",
+						"traefik.service.frontend.rateLimit.rateSet.api.period":  "10s",
+						"traefik.service.frontend.rateLimit.rateSet.api.average": "100",
+						"traefik.service.frontend.mirror.backends.v2.backend":    "bar",
+						"traefik.service.frontend.mirror.backends.v2.percent":    "10",
+						"traefik.service.frontend.split.backends.canary.backend": "baz",
+						"traefik.service.frontend.split.backends.canary.weight":  "1",
 					}),
 					ports(nat.PortMap{
 						"80/tcp": {},
@@ -345,7 +359,40 @@ func TestDockerLoadDockerServiceConfig(t *testing.T) {
 					Backend:        "backend-foo-service",
 					PassHostHeader: true,
 					EntryPoints:    []string{"http", "https"},
-					BasicAuth:      []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/", "test2:$apr1$d9hr9HBB$4HxwgUir3HP4EsggP/QNo0"},
+					Auth: &types.Auth{
+						Basic: &types.Basic{
+							Users: []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/", "test2:$apr1$d9hr9HBB$4HxwgUir3HP4EsggP/QNo0"},
+						},
+					},
+					PassTLSClientCert: &types.PassTLSClientCert{
+						PEM: "X-Client-Cert",
+					},
+					Redirect: &types.Redirect{
+						EntryPoint:  "https",
+						Regex:       `^http://foo\.docker\.localhost/(.*)`,
+						Replacement: "https://foo.docker.localhost/it's/$1",
+					},
+					Errors: map[string]*types.ErrorPage{
+						"server": {Status: []string{"500-599"}, Backend: "backend-errors-com"},
+					},
+					Headers: &types.Headers{
+						SSLRedirect: true,
+					},
+					RateLimit: &types.RateLimit{
+						RateSet: map[string]*types.Rate{
+							"api": {Period: "10s", Average: 100},
+						},
+					},
+					Mirror: &types.Mirror{
+						Backends: map[string]*types.MirrorBackend{
+							"v2": {Backend: "backend-bar", Percent: 10},
+						},
+					},
+					Split: &types.Split{
+						Backends: map[string]*types.WeightedBackend{
+							"canary": {Backend: "backend-baz", Weight: 1},
+						},
+					},
 					Routes: map[string]types.Route{
 						"service-service": {
 							Rule: "Host:foo.docker.localhost",
@@ -404,7 +451,11 @@ func TestDockerLoadDockerServiceConfig(t *testing.T) {
 					PassHostHeader: false,
 					Priority:       5000,
 					EntryPoints:    []string{"http", "https", "ws"},
-					BasicAuth:      []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/", "test2:$apr1$d9hr9HBB$4HxwgUir3HP4EsggP/QNo0"},
+					Auth: &types.Auth{
+						Basic: &types.Basic{
+							Users: []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/", "test2:$apr1$d9hr9HBB$4HxwgUir3HP4EsggP/QNo0"},
+						},
+					},
 					Routes: map[string]types.Route{
 						"service-service": {
 							Rule: "Path:/mypath",
@@ -415,7 +466,6 @@ func TestDockerLoadDockerServiceConfig(t *testing.T) {
 					Backend:        "backend-test2-anotherservice",
 					PassHostHeader: true,
 					EntryPoints:    []string{},
-					BasicAuth:      []string{},
 					Routes: map[string]types.Route{
 						"service-anotherservice": {
 							Rule: "Path:/anotherpath",
@@ -28,11 +28,10 @@ var _ provider.Provider = (*Provider)(nil)
 
 const (
 	annotationFrontendRuleType = "traefik.frontend.rule.type"
+	annotationAuthRealm        = "ingress.kubernetes.io/auth-realm"
 	ruleTypePathPrefix         = "PathPrefix"
 )
 
-const traefikDefaultRealm = "traefik"
-
 // Provider holds configurations of the provider.
 type Provider struct {
 	provider.BaseProvider  `mapstructure:",squash"`
@@ -132,8 +131,8 @@ func (p *Provider) loadIngresses(k8sClient Client) (*types.Configuration, error)
 	ingresses := k8sClient.GetIngresses(p.Namespaces)
 
 	templateObjects := types.Configuration{
-		map[string]*types.Backend{},
-		map[string]*types.Frontend{},
+		Backends:  map[string]*types.Backend{},
+		Frontends: map[string]*types.Frontend{},
 	}
 	for _, i := range ingresses {
 		ingressClass := i.Annotations["kubernetes.io/ingress.class"]
@@ -171,22 +170,35 @@ func (p *Provider) loadIngresses(k8sClient Client) (*types.Configuration, error)
 				default:
 					log.Warnf("Unknown value '%s' for traefik.frontend.passHostHeader, falling back to %s", passHostHeaderAnnotation, PassHostHeader)
 				}
-				if realm := i.Annotations["ingress.kubernetes.io/auth-realm"]; realm != "" && realm != traefikDefaultRealm {
-					return nil, errors.New("no realm customization supported")
-				}
-
 				if _, exists := templateObjects.Frontends[r.Host+pa.Path]; !exists {
 					basicAuthCreds, err := handleBasicAuthConfig(i, k8sClient)
 					if err != nil {
 						log.Errorf("Failed to retrieve basic auth configuration for ingress %s/%s: %s", i.ObjectMeta.Namespace, i.ObjectMeta.Name, err)
 						continue
 					}
+					auth := provider.GetAuth(i.Annotations)
+					if len(basicAuthCreds) > 0 {
+						if auth == nil {
+							auth = &types.Auth{}
+						}
+						auth.Basic = &types.Basic{
+							Users: basicAuthCreds,
+							Realm: i.Annotations[annotationAuthRealm],
+						}
+					}
 					templateObjects.Frontends[r.Host+pa.Path] = &types.Frontend{
-						Backend:        r.Host + pa.Path,
-						PassHostHeader: PassHostHeader,
-						Routes:         make(map[string]types.Route),
-						Priority:       len(pa.Path),
-						BasicAuth:      basicAuthCreds,
+						Backend:           r.Host + pa.Path,
+						PassHostHeader:    PassHostHeader,
+						Routes:            make(map[string]types.Route),
+						Priority:          len(pa.Path),
+						Redirect:          provider.GetRedirect(i.Annotations),
+						Errors:            provider.GetErrorPages(i.Annotations),
+						Headers:           provider.GetHeaders(i.Annotations),
+						RateLimit:         provider.GetRateLimit(i.Annotations),
+						Mirror:            provider.GetMirror(i.Annotations),
+						Split:             provider.GetSplit(i.Annotations),
+						Auth:              auth,
+						PassTLSClientCert: provider.GetPassTLSClientCert(i.Annotations),
 					}
 				}
 				if len(r.Host) > 0 {
@@ -237,6 +249,9 @@ func (p *Provider) loadIngresses(k8sClient Client) (*types.Configuration, error)
 				if service.Annotations["traefik.backend.loadbalancer.sticky"] == "true" {
 					templateObjects.Backends[r.Host+pa.Path].LoadBalancer.Sticky = true
 				}
+				if buffering := provider.GetBuffering(service.Annotations); buffering != nil {
+					templateObjects.Backends[r.Host+pa.Path].Buffering = buffering
+				}
 
 				protocol := "http"
 				for _, port := range service.Spec.Ports {
@@ -288,10 +303,40 @@ func (p *Provider) loadIngresses(k8sClient Client) (*types.Configuration, error)
 				}
 			}
 		}
+
+		for _, t := range i.Spec.TLS {
+			if len(t.SecretName) == 0 {
+				continue
+			}
+			tlsConfiguration, err := loadTLSConfiguration(i.Namespace, t.SecretName, k8sClient)
+			if err != nil {
+				log.Errorf("Failed to load TLS configuration for ingress %s/%s: %s", i.ObjectMeta.Namespace, i.ObjectMeta.Name, err)
+				continue
+			}
+			templateObjects.TLS = append(templateObjects.TLS, tlsConfiguration)
+		}
 	}
 	return &templateObjects, nil
 }
 
+func loadTLSConfiguration(namespace, secretName string, k8sClient Client) (*types.TLSConfiguration, error) {
+	secret, ok, err := k8sClient.GetSecret(namespace, secretName)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("failed to fetch secret %q/%q: %s", namespace, secretName, err)
+	case !ok || secret == nil:
+		return nil, fmt.Errorf("secret %q/%q not found", namespace, secretName)
+	}
+	cert, key := secret.Data[v1.TLSCertKey], secret.Data[v1.TLSPrivateKeyKey]
+	if len(cert) == 0 || len(key) == 0 {
+		return nil, fmt.Errorf("secret %q/%q must contain %s and %s", namespace, secretName, v1.TLSCertKey, v1.TLSPrivateKeyKey)
+	}
+	return &types.TLSConfiguration{
+		CertFile: string(cert),
+		KeyFile:  string(key),
+	}, nil
+}
+
 func handleBasicAuthConfig(i *v1beta1.Ingress, k8sClient Client) ([]string, error) {
 	authType, exists := i.Annotations["ingress.kubernetes.io/auth-type"]
 	if !exists {
@@ -388,6 +433,10 @@ func (p *Provider) loadConfig(templateObjects types.Configuration) *types.Config
 	configuration, err := p.GetConfiguration("templates/kubernetes.tmpl", FuncMap, templateObjects)
 	if err != nil {
 		log.Error(err)
+		return configuration
 	}
+	// the certificates are not rendered by the template, their contents are
+	// copied as is
+	configuration.TLS = templateObjects.TLS
 	return configuration
 }
@@ -3,6 +3,7 @@ package kv
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
@@ -21,11 +22,11 @@ import (
 // Provider holds common configurations of key-value providers.
 type Provider struct {
 	provider.BaseProvider `mapstructure:",squash"`
-	Endpoint              string              `description:"Comma separated server endpoints"`
-	Prefix                string              `description:"Prefix used for KV store"`
-	TLS                   *provider.ClientTLS `description:"Enable TLS support"`
-	Username              string              `description:"KV Username"`
-	Password              string              `description:"KV Password"`
+	Endpoint              string           `description:"Comma separated server endpoints"`
+	Prefix                string           `description:"Prefix used for KV store"`
+	TLS                   *types.ClientTLS `description:"Enable TLS support"`
+	Username              string           `description:"KV Username"`
+	Password              string           `description:"KV Password"`
 	storeType             store.Backend
 	kvclient              store.Store
 }
@@ -172,7 +173,9 @@ func (p *Provider) list(keys ...string) []string {
 		directory := strings.Split(strings.TrimPrefix(key.Key, joinedKeys), "/")[0]
 		directoryKeys[directory] = joinedKeys + directory
 	}
-	return fun.Values(directoryKeys).([]string)
+	directories := fun.Values(directoryKeys).([]string)
+	sort.Strings(directories)
+	return directories
 }
 
 func (p *Provider) listServers(backend string) []string {
@@ -0,0 +1,491 @@
+package provider
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+)
+
+// Frontend labels shared by the providers configured with labels or annotations
+const (
+	LabelFrontendRedirectEntryPoint  = "traefik.frontend.redirect.entryPoint"
+	LabelFrontendRedirectRegex       = "traefik.frontend.redirect.regex"
+	LabelFrontendRedirectReplacement = "traefik.frontend.redirect.replacement"
+	LabelFrontendRedirectPermanent   = "traefik.frontend.redirect.permanent"
+	LabelFrontendRedirectDropPath    = "traefik.frontend.redirect.dropPath"
+	LabelFrontendRedirectDropQuery   = "traefik.frontend.redirect.dropQuery"
+	// LabelFrontendErrorsPrefix is followed by the name of the error page and
+	// by one of status, backend or query.
+	LabelFrontendErrorsPrefix = "traefik.frontend.errors."
+	// LabelFrontendRequestHeadersPrefix and LabelFrontendResponseHeadersPrefix
+	// are followed by the name of the header, an empty value removes it.
+	LabelFrontendRequestHeadersPrefix  = "traefik.frontend.headers.customRequestHeaders."
+	LabelFrontendResponseHeadersPrefix = "traefik.frontend.headers.customResponseHeaders."
+	// LabelFrontendSSLProxyHeadersPrefix is followed by the name of the header
+	LabelFrontendSSLProxyHeadersPrefix          = "traefik.frontend.headers.sslProxyHeaders."
+	LabelFrontendHeadersAllowedHosts            = "traefik.frontend.headers.allowedHosts"
+	LabelFrontendHeadersSSLRedirect             = "traefik.frontend.headers.sslRedirect"
+	LabelFrontendHeadersSSLTemporaryRedirect    = "traefik.frontend.headers.sslTemporaryRedirect"
+	LabelFrontendHeadersSSLHost                 = "traefik.frontend.headers.sslHost"
+	LabelFrontendHeadersSTSSeconds              = "traefik.frontend.headers.stsSeconds"
+	LabelFrontendHeadersSTSIncludeSubdomains    = "traefik.frontend.headers.stsIncludeSubdomains"
+	LabelFrontendHeadersSTSPreload              = "traefik.frontend.headers.stsPreload"
+	LabelFrontendHeadersForceSTSHeader          = "traefik.frontend.headers.forceSTSHeader"
+	LabelFrontendHeadersFrameDeny               = "traefik.frontend.headers.frameDeny"
+	LabelFrontendHeadersCustomFrameOptionsValue = "traefik.frontend.headers.customFrameOptionsValue"
+	LabelFrontendHeadersContentTypeNosniff      = "traefik.frontend.headers.contentTypeNosniff"
+	LabelFrontendHeadersBrowserXSSFilter        = "traefik.frontend.headers.browserXSSFilter"
+	LabelFrontendHeadersContentSecurityPolicy   = "traefik.frontend.headers.contentSecurityPolicy"
+	LabelFrontendHeadersReferrerPolicy          = "traefik.frontend.headers.referrerPolicy"
+	LabelFrontendHeadersIsDevelopment           = "traefik.frontend.headers.isDevelopment"
+	LabelFrontendRateLimitExtractorFunc         = "traefik.frontend.rateLimit.extractorFunc"
+	// LabelFrontendRateSetPrefix is followed by the name of the rate set and
+	// by one of period, average or burst.
+	LabelFrontendRateSetPrefix     = "traefik.frontend.rateLimit.rateSet."
+	LabelFrontendMirrorMaxBodySize = "traefik.frontend.mirror.maxBodySize"
+	// LabelFrontendMirrorBackendsPrefix is followed by the name of the mirror
+	// and by backend or percent.
+	LabelFrontendMirrorBackendsPrefix = "traefik.frontend.mirror.backends."
+	LabelFrontendSplitSticky          = "traefik.frontend.split.sticky"
+	// LabelFrontendSplitBackendsPrefix is followed by the name of the split
+	// backend and by backend or weight.
+	LabelFrontendSplitBackendsPrefix = "traefik.frontend.split.backends."
+	LabelFrontendAuthForwardAddress  = "traefik.frontend.auth.forward.address"
+	// LabelFrontendAuthForwardAuthResponseHeaders is a comma separated list
+	LabelFrontendAuthForwardAuthResponseHeaders   = "traefik.frontend.auth.forward.authResponseHeaders"
+	LabelFrontendAuthForwardTrustForwardHeader    = "traefik.frontend.auth.forward.trustForwardHeader"
+	LabelFrontendAuthForwardTimeout               = "traefik.frontend.auth.forward.timeout"
+	LabelFrontendAuthForwardTLSCA                 = "traefik.frontend.auth.forward.tls.ca"
+	LabelFrontendAuthForwardTLSCert               = "traefik.frontend.auth.forward.tls.cert"
+	LabelFrontendAuthForwardTLSKey                = "traefik.frontend.auth.forward.tls.key"
+	LabelFrontendAuthForwardTLSInsecureSkipVerify = "traefik.frontend.auth.forward.tls.insecureSkipVerify"
+	// LabelFrontendAuthJWTKeys, LabelFrontendAuthJWTIssuers,
+	// LabelFrontendAuthJWTAudiences and LabelFrontendAuthJWTRequiredClaims are
+	// comma separated lists
+	LabelFrontendAuthJWTKeys                = "traefik.frontend.auth.jwt.keys"
+	LabelFrontendAuthJWTJWKSURL             = "traefik.frontend.auth.jwt.jwksURL"
+	LabelFrontendAuthJWTJWKSFile            = "traefik.frontend.auth.jwt.jwksFile"
+	LabelFrontendAuthJWTJWKSRefreshInterval = "traefik.frontend.auth.jwt.jwksRefreshInterval"
+	LabelFrontendAuthJWTIssuers             = "traefik.frontend.auth.jwt.issuers"
+	LabelFrontendAuthJWTAudiences           = "traefik.frontend.auth.jwt.audiences"
+	LabelFrontendAuthJWTRequiredClaims      = "traefik.frontend.auth.jwt.requiredClaims"
+	// LabelFrontendAuthJWTClaimHeadersPrefix is followed by the name of the
+	// claim, and its value is the name of the header.
+	LabelFrontendAuthJWTClaimHeadersPrefix = "traefik.frontend.auth.jwt.claimHeaders."
+	LabelFrontendAuthHeaderField           = "traefik.frontend.auth.headerField"
+	// LabelFrontendAuthBasic is deprecated, it is an alias of
+	// LabelFrontendAuthBasicUsers
+	LabelFrontendAuthBasic = "traefik.frontend.auth.basic"
+	// LabelFrontendAuthBasicUsers and LabelFrontendAuthDigestUsers are comma
+	// separated lists
+	LabelFrontendAuthBasicUsers         = "traefik.frontend.auth.basic.users"
+	LabelFrontendAuthBasicUsersFile     = "traefik.frontend.auth.basic.usersFile"
+	LabelFrontendAuthBasicRealm         = "traefik.frontend.auth.basic.realm"
+	LabelFrontendAuthBasicRemoveHeader  = "traefik.frontend.auth.basic.removeHeader"
+	LabelFrontendAuthDigestUsers        = "traefik.frontend.auth.digest.users"
+	LabelFrontendAuthDigestUsersFile    = "traefik.frontend.auth.digest.usersFile"
+	LabelFrontendAuthDigestRealm        = "traefik.frontend.auth.digest.realm"
+	LabelFrontendAuthDigestRemoveHeader = "traefik.frontend.auth.digest.removeHeader"
+	// The values of the LabelFrontendPassTLSClientCert labels are the names of
+	// the headers
+	LabelFrontendPassTLSClientCertPEM          = "traefik.frontend.passTLSClientCert.pem"
+	LabelFrontendPassTLSClientCertSubjectCN    = "traefik.frontend.passTLSClientCert.subjectCN"
+	LabelFrontendPassTLSClientCertSANs         = "traefik.frontend.passTLSClientCert.sans"
+	LabelFrontendPassTLSClientCertSerialNumber = "traefik.frontend.passTLSClientCert.serialNumber"
+	LabelFrontendPassTLSClientCertIssuer       = "traefik.frontend.passTLSClientCert.issuer"
+	LabelFrontendPassTLSClientCertNotBefore    = "traefik.frontend.passTLSClientCert.notBefore"
+	LabelFrontendPassTLSClientCertNotAfter     = "traefik.frontend.passTLSClientCert.notAfter"
+)
+
+// Backend labels shared by the providers configured with labels or annotations
+const (
+	LabelBackendBufferingMaxRequestBodyBytes  = "traefik.backend.buffering.maxrequestbodybytes"
+	LabelBackendBufferingMemRequestBodyBytes  = "traefik.backend.buffering.memrequestbodybytes"
+	LabelBackendBufferingMaxResponseBodyBytes = "traefik.backend.buffering.maxresponsebodybytes"
+	LabelBackendBufferingRetryExpression      = "traefik.backend.buffering.retryexpression"
+)
+
+// GetRedirect returns the frontend redirect configured by the labels, or nil
+// if neither a target entrypoint nor a regex is set.
+func GetRedirect(labels map[string]string) *types.Redirect {
+	redirect := &types.Redirect{
+		EntryPoint:  labels[LabelFrontendRedirectEntryPoint],
+		Regex:       labels[LabelFrontendRedirectRegex],
+		Replacement: labels[LabelFrontendRedirectReplacement],
+		Permanent:   getBoolLabel(labels, LabelFrontendRedirectPermanent),
+		DropPath:    getBoolLabel(labels, LabelFrontendRedirectDropPath),
+		DropQuery:   getBoolLabel(labels, LabelFrontendRedirectDropQuery),
+	}
+	if len(redirect.EntryPoint) == 0 && len(redirect.Regex) == 0 {
+		return nil
+	}
+	return redirect
+}
+
+// GetErrorPages returns the frontend error pages configured by the labels,
+// which have a backend, or nil if there is none.
+func GetErrorPages(labels map[string]string) map[string]*types.ErrorPage {
+	errorPages := map[string]*types.ErrorPage{}
+	for label, value := range labels {
+		if !strings.HasPrefix(label, LabelFrontendErrorsPrefix) {
+			continue
+		}
+		property := strings.TrimPrefix(label, LabelFrontendErrorsPrefix)
+		dot := strings.LastIndex(property, ".")
+		if dot <= 0 {
+			continue
+		}
+		name := property[:dot]
+		if errorPages[name] == nil {
+			errorPages[name] = &types.ErrorPage{}
+		}
+		switch property[dot+1:] {
+		case "status":
+			errorPages[name].Status = strings.Split(value, ",")
+		case "backend":
+			errorPages[name].Backend = value
+		case "query":
+			errorPages[name].Query = value
+		default:
+			log.Warnf("Unknown label %s", label)
+		}
+	}
+	for name, errorPage := range errorPages {
+		if len(errorPage.Backend) == 0 {
+			log.Warnf("No backend for error page %s, ignoring it", name)
+			delete(errorPages, name)
+		}
+	}
+	if len(errorPages) == 0 {
+		return nil
+	}
+	return errorPages
+}
+
+// GetHeaders returns the frontend headers and security options configured by
+// the labels, or nil if there is none.
+func GetHeaders(labels map[string]string) *types.Headers {
+	headers := &types.Headers{
+		CustomRequestHeaders:    getPrefixedLabels(labels, LabelFrontendRequestHeadersPrefix),
+		CustomResponseHeaders:   getPrefixedLabels(labels, LabelFrontendResponseHeadersPrefix),
+		SSLRedirect:             getBoolLabel(labels, LabelFrontendHeadersSSLRedirect),
+		SSLTemporaryRedirect:    getBoolLabel(labels, LabelFrontendHeadersSSLTemporaryRedirect),
+		SSLHost:                 labels[LabelFrontendHeadersSSLHost],
+		SSLProxyHeaders:         getPrefixedLabels(labels, LabelFrontendSSLProxyHeadersPrefix),
+		STSSeconds:              getInt64Label(labels, LabelFrontendHeadersSTSSeconds),
+		STSIncludeSubdomains:    getBoolLabel(labels, LabelFrontendHeadersSTSIncludeSubdomains),
+		STSPreload:              getBoolLabel(labels, LabelFrontendHeadersSTSPreload),
+		ForceSTSHeader:          getBoolLabel(labels, LabelFrontendHeadersForceSTSHeader),
+		FrameDeny:               getBoolLabel(labels, LabelFrontendHeadersFrameDeny),
+		CustomFrameOptionsValue: labels[LabelFrontendHeadersCustomFrameOptionsValue],
+		ContentTypeNosniff:      getBoolLabel(labels, LabelFrontendHeadersContentTypeNosniff),
+		BrowserXSSFilter:        getBoolLabel(labels, LabelFrontendHeadersBrowserXSSFilter),
+		ContentSecurityPolicy:   labels[LabelFrontendHeadersContentSecurityPolicy],
+		ReferrerPolicy:          labels[LabelFrontendHeadersReferrerPolicy],
+		IsDevelopment:           getBoolLabel(labels, LabelFrontendHeadersIsDevelopment),
+	}
+	if allowedHosts, ok := labels[LabelFrontendHeadersAllowedHosts]; ok {
+		headers.AllowedHosts = strings.Split(allowedHosts, ",")
+	}
+	if !headers.HasCustomHeadersDefined() && !headers.HasSecureHeadersDefined() {
+		return nil
+	}
+	return headers
+}
+
+// GetRateLimit returns the frontend rate limit configured by the labels, or nil
+// if there is no rate set.
+func GetRateLimit(labels map[string]string) *types.RateLimit {
+	rateSets := map[string]*types.Rate{}
+	for label, value := range labels {
+		if !strings.HasPrefix(label, LabelFrontendRateSetPrefix) {
+			continue
+		}
+		property := strings.TrimPrefix(label, LabelFrontendRateSetPrefix)
+		dot := strings.LastIndex(property, ".")
+		if dot <= 0 {
+			continue
+		}
+		name := property[:dot]
+		if rateSets[name] == nil {
+			rateSets[name] = &types.Rate{}
+		}
+		switch property[dot+1:] {
+		case "period":
+			rateSets[name].Period = value
+		case "average":
+			rateSets[name].Average = getInt64Label(labels, label)
+		case "burst":
+			rateSets[name].Burst = getInt64Label(labels, label)
+		default:
+			log.Warnf("Unknown label %s", label)
+		}
+	}
+	if len(rateSets) == 0 {
+		return nil
+	}
+	return &types.RateLimit{
+		RateSet:       rateSets,
+		ExtractorFunc: labels[LabelFrontendRateLimitExtractorFunc],
+	}
+}
+
+// GetMirror returns the frontend mirror configured by the labels, or nil if
+// there is no mirror backend.
+func GetMirror(labels map[string]string) *types.Mirror {
+	backends := map[string]*types.MirrorBackend{}
+	for label, value := range labels {
+		if !strings.HasPrefix(label, LabelFrontendMirrorBackendsPrefix) {
+			continue
+		}
+		property := strings.TrimPrefix(label, LabelFrontendMirrorBackendsPrefix)
+		dot := strings.LastIndex(property, ".")
+		if dot <= 0 {
+			continue
+		}
+		name := property[:dot]
+		if backends[name] == nil {
+			backends[name] = &types.MirrorBackend{}
+		}
+		switch property[dot+1:] {
+		case "backend":
+			backends[name].Backend = value
+		case "percent":
+			backends[name].Percent = int(getInt64Label(labels, label))
+		default:
+			log.Warnf("Unknown label %s", label)
+		}
+	}
+	for name, backend := range backends {
+		if len(backend.Backend) == 0 {
+			log.Warnf("No backend for mirror %s, ignoring it", name)
+			delete(backends, name)
+		}
+	}
+	if len(backends) == 0 {
+		return nil
+	}
+	return &types.Mirror{
+		Backends:    backends,
+		MaxBodySize: getInt64Label(labels, LabelFrontendMirrorMaxBodySize),
+	}
+}
+
+// GetSplit returns the frontend split configured by the labels, or nil if there
+// is no split backend.
+func GetSplit(labels map[string]string) *types.Split {
+	backends := map[string]*types.WeightedBackend{}
+	for label, value := range labels {
+		if !strings.HasPrefix(label, LabelFrontendSplitBackendsPrefix) {
+			continue
+		}
+		property := strings.TrimPrefix(label, LabelFrontendSplitBackendsPrefix)
+		dot := strings.LastIndex(property, ".")
+		if dot <= 0 {
+			continue
+		}
+		name := property[:dot]
+		if backends[name] == nil {
+			backends[name] = &types.WeightedBackend{}
+		}
+		switch property[dot+1:] {
+		case "backend":
+			backends[name].Backend = value
+		case "weight":
+			backends[name].Weight = int(getInt64Label(labels, label))
+		default:
+			log.Warnf("Unknown label %s", label)
+		}
+	}
+	for name, backend := range backends {
+		if len(backend.Backend) == 0 {
+			log.Warnf("No backend for split backend %s, ignoring it", name)
+			delete(backends, name)
+		}
+	}
+	if len(backends) == 0 {
+		return nil
+	}
+	return &types.Split{
+		Backends: backends,
+		Sticky:   getBoolLabel(labels, LabelFrontendSplitSticky),
+	}
+}
+
+// GetAuth returns the frontend authentication configured by the labels, or nil
+// if there are neither users, a forward authentication address nor JWT keys.
+func GetAuth(labels map[string]string) *types.Auth {
+	auth := &types.Auth{
+		Basic:       getBasicAuth(labels),
+		Digest:      getDigestAuth(labels),
+		Forward:     getForwardAuth(labels),
+		JWT:         getJWTAuth(labels),
+		HeaderField: labels[LabelFrontendAuthHeaderField],
+	}
+	if auth.Basic == nil && auth.Digest == nil && auth.Forward == nil && auth.JWT == nil {
+		return nil
+	}
+	return auth
+}
+
+func getBasicAuth(labels map[string]string) *types.Basic {
+	users := getListLabel(labels, LabelFrontendAuthBasicUsers)
+	if deprecatedUsers := getListLabel(labels, LabelFrontendAuthBasic); len(deprecatedUsers) > 0 {
+		log.Warnf("Label %s is deprecated, use %s instead", LabelFrontendAuthBasic, LabelFrontendAuthBasicUsers)
+		users = append(users, deprecatedUsers...)
+	}
+	usersFile := labels[LabelFrontendAuthBasicUsersFile]
+	if len(users) == 0 && len(usersFile) == 0 {
+		return nil
+	}
+	return &types.Basic{
+		Users:        users,
+		UsersFile:    usersFile,
+		Realm:        labels[LabelFrontendAuthBasicRealm],
+		RemoveHeader: getBoolLabel(labels, LabelFrontendAuthBasicRemoveHeader),
+	}
+}
+
+func getDigestAuth(labels map[string]string) *types.Digest {
+	users := getListLabel(labels, LabelFrontendAuthDigestUsers)
+	usersFile := labels[LabelFrontendAuthDigestUsersFile]
+	if len(users) == 0 && len(usersFile) == 0 {
+		return nil
+	}
+	return &types.Digest{
+		Users:        users,
+		UsersFile:    usersFile,
+		Realm:        labels[LabelFrontendAuthDigestRealm],
+		RemoveHeader: getBoolLabel(labels, LabelFrontendAuthDigestRemoveHeader),
+	}
+}
+
+func getForwardAuth(labels map[string]string) *types.Forward {
+	address := labels[LabelFrontendAuthForwardAddress]
+	if len(address) == 0 {
+		return nil
+	}
+	forward := &types.Forward{
+		Address:             address,
+		TrustForwardHeader:  getBoolLabel(labels, LabelFrontendAuthForwardTrustForwardHeader),
+		AuthResponseHeaders: getListLabel(labels, LabelFrontendAuthForwardAuthResponseHeaders),
+		Timeout:             labels[LabelFrontendAuthForwardTimeout],
+	}
+	for _, label := range []string{LabelFrontendAuthForwardTLSCA, LabelFrontendAuthForwardTLSCert, LabelFrontendAuthForwardTLSKey, LabelFrontendAuthForwardTLSInsecureSkipVerify} {
+		if _, ok := labels[label]; ok {
+			forward.TLS = &types.ClientTLS{
+				CA:                 labels[LabelFrontendAuthForwardTLSCA],
+				Cert:               labels[LabelFrontendAuthForwardTLSCert],
+				Key:                labels[LabelFrontendAuthForwardTLSKey],
+				InsecureSkipVerify: getBoolLabel(labels, LabelFrontendAuthForwardTLSInsecureSkipVerify),
+			}
+			break
+		}
+	}
+	return forward
+}
+
+func getJWTAuth(labels map[string]string) *types.JWT {
+	jwt := &types.JWT{
+		Keys:                getListLabel(labels, LabelFrontendAuthJWTKeys),
+		JWKSURL:             labels[LabelFrontendAuthJWTJWKSURL],
+		JWKSFile:            labels[LabelFrontendAuthJWTJWKSFile],
+		JWKSRefreshInterval: labels[LabelFrontendAuthJWTJWKSRefreshInterval],
+		Issuers:             getListLabel(labels, LabelFrontendAuthJWTIssuers),
+		Audiences:           getListLabel(labels, LabelFrontendAuthJWTAudiences),
+		RequiredClaims:      getListLabel(labels, LabelFrontendAuthJWTRequiredClaims),
+		ClaimHeaders:        getPrefixedLabels(labels, LabelFrontendAuthJWTClaimHeadersPrefix),
+	}
+	if len(jwt.Keys) == 0 && len(jwt.JWKSURL) == 0 && len(jwt.JWKSFile) == 0 {
+		return nil
+	}
+	return jwt
+}
+
+// GetPassTLSClientCert returns the headers in which the TLS client certificate
+// is passed to the backend, or nil if there is none.
+func GetPassTLSClientCert(labels map[string]string) *types.PassTLSClientCert {
+	passTLSClientCert := &types.PassTLSClientCert{
+		PEM:          labels[LabelFrontendPassTLSClientCertPEM],
+		SubjectCN:    labels[LabelFrontendPassTLSClientCertSubjectCN],
+		SANs:         labels[LabelFrontendPassTLSClientCertSANs],
+		SerialNumber: labels[LabelFrontendPassTLSClientCertSerialNumber],
+		Issuer:       labels[LabelFrontendPassTLSClientCertIssuer],
+		NotBefore:    labels[LabelFrontendPassTLSClientCertNotBefore],
+		NotAfter:     labels[LabelFrontendPassTLSClientCertNotAfter],
+	}
+	if *passTLSClientCert == (types.PassTLSClientCert{}) {
+		return nil
+	}
+	return passTLSClientCert
+}
+
+// GetBuffering returns the backend buffering configured by the labels, or nil
+// if there is none.
+func GetBuffering(labels map[string]string) *types.Buffering {
+	for _, label := range []string{LabelBackendBufferingMaxRequestBodyBytes, LabelBackendBufferingMemRequestBodyBytes, LabelBackendBufferingMaxResponseBodyBytes, LabelBackendBufferingRetryExpression} {
+		if _, ok := labels[label]; !ok {
+			continue
+		}
+		return &types.Buffering{
+			MaxRequestBodyBytes:  getInt64Label(labels, LabelBackendBufferingMaxRequestBodyBytes),
+			MemRequestBodyBytes:  getInt64Label(labels, LabelBackendBufferingMemRequestBodyBytes),
+			MaxResponseBodyBytes: getInt64Label(labels, LabelBackendBufferingMaxResponseBodyBytes),
+			RetryExpression:      labels[LabelBackendBufferingRetryExpression],
+		}
+	}
+	return nil
+}
+
+// getPrefixedLabels returns the values of the labels starting with the prefix,
+// by label name without the prefix, or nil if there is none.
+func getPrefixedLabels(labels map[string]string, prefix string) map[string]string {
+	var values map[string]string
+	for label, value := range labels {
+		if !strings.HasPrefix(label, prefix) || len(label) == len(prefix) {
+			continue
+		}
+		if values == nil {
+			values = map[string]string{}
+		}
+		values[strings.TrimPrefix(label, prefix)] = value
+	}
+	return values
+}
+
+// getListLabel returns the comma separated values of the label, or nil if it is
+// not set.
+func getListLabel(labels map[string]string, label string) []string {
+	if value, ok := labels[label]; ok && len(value) > 0 {
+		return strings.Split(value, ",")
+	}
+	return nil
+}
+
+func getBoolLabel(labels map[string]string, label string) bool {
+	value, ok := labels[label]
+	if !ok {
+		return false
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Warnf("Unknown value '%s' for %s, falling back to false", value, label)
+		return false
+	}
+	return b
+}
+
+func getInt64Label(labels map[string]string, label string) int64 {
+	value, ok := labels[label]
+	if !ok {
+		return 0
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Warnf("Unknown value '%s' for %s, falling back to 0", value, label)
+		return 0
+	}
+	return i
+}
@@ -0,0 +1,335 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/containous/traefik/types"
+)
+
+func TestGetRedirect(t *testing.T) {
+	cases := []struct {
+		desc     string
+		labels   map[string]string
+		expected *types.Redirect
+	}{
+		{
+			desc:     "no redirect",
+			labels:   map[string]string{"traefik.frontend.rule": "Host:foo"},
+			expected: nil,
+		},
+		{
+			desc: "replacement without regex",
+			labels: map[string]string{
+				LabelFrontendRedirectReplacement: "https://foo",
+			},
+			expected: nil,
+		},
+		{
+			desc: "entrypoint",
+			labels: map[string]string{
+				LabelFrontendRedirectEntryPoint: "https",
+				LabelFrontendRedirectPermanent:  "true",
+				LabelFrontendRedirectDropQuery:  "true",
+			},
+			expected: &types.Redirect{EntryPoint: "https", Permanent: true, DropQuery: true},
+		},
+		{
+			desc: "regex",
+			labels: map[string]string{
+				LabelFrontendRedirectRegex:       `^http://www\.foo\.com/(.*)`,
+				LabelFrontendRedirectReplacement: "http://foo.com/$1",
+				LabelFrontendRedirectPermanent:   "invalid",
+			},
+			expected: &types.Redirect{Regex: `^http://www\.foo\.com/(.*)`, Replacement: "http://foo.com/$1"},
+		},
+	}
+
+	for _, c := range cases {
+		actual := GetRedirect(c.labels)
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%s: expected %+v, got %+v", c.desc, c.expected, actual)
+		}
+	}
+}
+
+func TestGetErrorPages(t *testing.T) {
+	labels := map[string]string{
+		"traefik.frontend.rule":                   "Host:foo",
+		"traefik.frontend.errors.server.status":   "500-599,404",
+		"traefik.frontend.errors.server.backend":  "errors",
+		"traefik.frontend.errors.server.query":    "/{status}.html",
+		"traefik.frontend.errors.nobackend.query": "/{status}.html",
+	}
+	expected := map[string]*types.ErrorPage{
+		"server": {
+			Status:  []string{"500-599", "404"},
+			Backend: "errors",
+			Query:   "/{status}.html",
+		},
+	}
+
+	actual := GetErrorPages(labels)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestGetHeaders(t *testing.T) {
+	labels := map[string]string{
+		"traefik.frontend.rule":                                   "Host:foo",
+		"traefik.frontend.headers.customRequestHeaders.X-Env":     "prod",
+		"traefik.frontend.headers.customResponseHeaders.Server":   "",
+		"traefik.frontend.headers.customResponseHeaders.X-Served": "traefik",
+		"traefik.frontend.headers.customResponseHeaders.":         "ignored",
+	}
+	expected := &types.Headers{
+		CustomRequestHeaders:  map[string]string{"X-Env": "prod"},
+		CustomResponseHeaders: map[string]string{"Server": "", "X-Served": "traefik"},
+	}
+
+	actual := GetHeaders(labels)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+	if headers := GetHeaders(map[string]string{"traefik.frontend.rule": "Host:foo"}); headers != nil {
+		t.Errorf("expected no headers, got %+v", headers)
+	}
+}
+
+func TestGetHeadersSecurity(t *testing.T) {
+	labels := map[string]string{
+		"traefik.frontend.headers.allowedHosts":                      "foo.com,www.foo.com",
+		"traefik.frontend.headers.sslRedirect":                       "true",
+		"traefik.frontend.headers.sslProxyHeaders.X-Forwarded-Proto": "https",
+		"traefik.frontend.headers.stsSeconds":                        "315360000",
+		"traefik.frontend.headers.stsIncludeSubdomains":              "true",
+		"traefik.frontend.headers.frameDeny":                         "true",
+		"traefik.frontend.headers.contentSecurityPolicy":             "default-src 'self'",
+		"traefik.frontend.headers.isDevelopment":                     "invalid",
+	}
+	expected := &types.Headers{
+		AllowedHosts:          []string{"foo.com", "www.foo.com"},
+		SSLRedirect:           true,
+		SSLProxyHeaders:       map[string]string{"X-Forwarded-Proto": "https"},
+		STSSeconds:            315360000,
+		STSIncludeSubdomains:  true,
+		FrameDeny:             true,
+		ContentSecurityPolicy: "default-src 'self'",
+	}
+
+	actual := GetHeaders(labels)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+	if headers := GetHeaders(map[string]string{"traefik.frontend.headers.stsSeconds": "invalid"}); headers != nil {
+		t.Errorf("expected no headers, got %+v", headers)
+	}
+}
+
+func TestGetRateLimit(t *testing.T) {
+	labels := map[string]string{
+		"traefik.frontend.rule":                          "Host:foo",
+		"traefik.frontend.rateLimit.extractorFunc":       "request.header.X-Api-Key",
+		"traefik.frontend.rateLimit.rateSet.api.period":  "10s",
+		"traefik.frontend.rateLimit.rateSet.api.average": "100",
+		"traefik.frontend.rateLimit.rateSet.api.burst":   "200",
+		"traefik.frontend.rateLimit.rateSet.day.period":  "24h",
+		"traefik.frontend.rateLimit.rateSet.day.average": "invalid",
+	}
+	expected := &types.RateLimit{
+		ExtractorFunc: "request.header.X-Api-Key",
+		RateSet: map[string]*types.Rate{
+			"api": {Period: "10s", Average: 100, Burst: 200},
+			"day": {Period: "24h"},
+		},
+	}
+
+	actual := GetRateLimit(labels)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+	if rateLimit := GetRateLimit(map[string]string{"traefik.frontend.rateLimit.extractorFunc": "client.ip"}); rateLimit != nil {
+		t.Errorf("expected no rate limit, got %+v", rateLimit)
+	}
+}
+
+func TestGetAuth(t *testing.T) {
+	labels := map[string]string{
+		"traefik.frontend.auth.forward.address":                "https://auth.server/verify",
+		"traefik.frontend.auth.forward.trustForwardHeader":     "true",
+		"traefik.frontend.auth.forward.authResponseHeaders":    "X-Auth-User,X-Auth-Group",
+		"traefik.frontend.auth.forward.timeout":                "5s",
+		"traefik.frontend.auth.forward.tls.ca":                 "ca.crt",
+		"traefik.frontend.auth.forward.tls.insecureSkipVerify": "true",
+	}
+	expected := &types.Auth{
+		Forward: &types.Forward{
+			Address:             "https://auth.server/verify",
+			TrustForwardHeader:  true,
+			AuthResponseHeaders: []string{"X-Auth-User", "X-Auth-Group"},
+			Timeout:             "5s",
+			TLS: &types.ClientTLS{
+				CA:                 "ca.crt",
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+
+	actual := GetAuth(labels)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+	if auth := GetAuth(map[string]string{"traefik.frontend.auth.forward.trustForwardHeader": "true"}); auth != nil {
+		t.Errorf("expected no auth, got %+v", auth)
+	}
+}
+
+func TestGetAuthJWT(t *testing.T) {
+	labels := map[string]string{
+		"traefik.frontend.auth.jwt.jwksURL":             "https://issuer.example.com/.well-known/jwks.json",
+		"traefik.frontend.auth.jwt.jwksRefreshInterval": "1h",
+		"traefik.frontend.auth.jwt.issuers":             "https://issuer.example.com",
+		"traefik.frontend.auth.jwt.audiences":           "api,admin",
+		"traefik.frontend.auth.jwt.requiredClaims":      "scope",
+		"traefik.frontend.auth.jwt.claimHeaders.email":  "X-Auth-Email",
+		"traefik.frontend.auth.headerField":             "X-WebAuth-User",
+	}
+	expected := &types.Auth{
+		JWT: &types.JWT{
+			JWKSURL:             "https://issuer.example.com/.well-known/jwks.json",
+			JWKSRefreshInterval: "1h",
+			Issuers:             []string{"https://issuer.example.com"},
+			Audiences:           []string{"api", "admin"},
+			RequiredClaims:      []string{"scope"},
+			ClaimHeaders:        map[string]string{"email": "X-Auth-Email"},
+		},
+		HeaderField: "X-WebAuth-User",
+	}
+
+	actual := GetAuth(labels)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+	if auth := GetAuth(map[string]string{"traefik.frontend.auth.jwt.issuers": "https://issuer.example.com"}); auth != nil {
+		t.Errorf("expected no auth, got %+v", auth)
+	}
+}
+
+func TestGetAuthBasicDigest(t *testing.T) {
+	labels := map[string]string{
+		"traefik.frontend.auth.basic":               "test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/",
+		"traefik.frontend.auth.basic.users":         "test2:$apr1$d9hr9HBB$4HxwgUir3HP4EsggP/QNo0",
+		"traefik.frontend.auth.basic.realm":         "my-realm",
+		"traefik.frontend.auth.basic.removeHeader":  "true",
+		"traefik.frontend.auth.digest.usersFile":    "/etc/traefik/.htdigest",
+		"traefik.frontend.auth.digest.removeHeader": "false",
+	}
+	expected := &types.Auth{
+		Basic: &types.Basic{
+			Users:        []string{"test2:$apr1$d9hr9HBB$4HxwgUir3HP4EsggP/QNo0", "test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
+			Realm:        "my-realm",
+			RemoveHeader: true,
+		},
+		Digest: &types.Digest{
+			UsersFile: "/etc/traefik/.htdigest",
+		},
+	}
+
+	actual := GetAuth(labels)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+	if auth := GetAuth(map[string]string{"traefik.frontend.auth.basic.realm": "my-realm"}); auth != nil {
+		t.Errorf("expected no auth, got %+v", auth)
+	}
+}
+
+func TestGetPassTLSClientCert(t *testing.T) {
+	labels := map[string]string{
+		"traefik.frontend.passTLSClientCert.pem":       "X-Forwarded-Tls-Client-Cert",
+		"traefik.frontend.passTLSClientCert.subjectCN": "X-Client-CN",
+		"traefik.frontend.passTLSClientCert.notAfter":  "X-Client-Not-After",
+	}
+	expected := &types.PassTLSClientCert{
+		PEM:       "X-Forwarded-Tls-Client-Cert",
+		SubjectCN: "X-Client-CN",
+		NotAfter:  "X-Client-Not-After",
+	}
+
+	actual := GetPassTLSClientCert(labels)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+	if passTLSClientCert := GetPassTLSClientCert(map[string]string{"traefik.frontend.rule": "Host:foo"}); passTLSClientCert != nil {
+		t.Errorf("expected no passTLSClientCert, got %+v", passTLSClientCert)
+	}
+}
+
+func TestGetBuffering(t *testing.T) {
+	labels := map[string]string{
+		"traefik.backend.buffering.maxrequestbodybytes": "10485760",
+		"traefik.backend.buffering.retryexpression":     "IsNetworkError() && Attempts() < 2",
+	}
+	expected := &types.Buffering{
+		MaxRequestBodyBytes: 10485760,
+		RetryExpression:     "IsNetworkError() && Attempts() < 2",
+	}
+
+	actual := GetBuffering(labels)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+	if buffering := GetBuffering(map[string]string{"traefik.frontend.rule": "Host:foo"}); buffering != nil {
+		t.Errorf("expected no buffering, got %+v", buffering)
+	}
+}
+
+func TestGetMirror(t *testing.T) {
+	labels := map[string]string{
+		"traefik.frontend.mirror.maxBodySize":                "2048",
+		"traefik.frontend.mirror.backends.shadow.backend":    "v2",
+		"traefik.frontend.mirror.backends.shadow.percent":    "10",
+		"traefik.frontend.mirror.backends.nobackend.percent": "50",
+	}
+	expected := &types.Mirror{
+		Backends: map[string]*types.MirrorBackend{
+			"shadow": {Backend: "v2", Percent: 10},
+		},
+		MaxBodySize: 2048,
+	}
+
+	actual := GetMirror(labels)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+	if mirror := GetMirror(map[string]string{"traefik.frontend.mirror.maxBodySize": "2048"}); mirror != nil {
+		t.Errorf("expected no mirror, got %+v", mirror)
+	}
+}
+
+func TestGetSplit(t *testing.T) {
+	labels := map[string]string{
+		"traefik.frontend.split.sticky":                  "true",
+		"traefik.frontend.split.backends.stable.backend": "v1",
+		"traefik.frontend.split.backends.stable.weight":  "95",
+		"traefik.frontend.split.backends.canary.backend": "v2",
+		"traefik.frontend.split.backends.canary.weight":  "5",
+		"traefik.frontend.split.backends.other.weight":   "10",
+	}
+	expected := &types.Split{
+		Backends: map[string]*types.WeightedBackend{
+			"stable": {Backend: "v1", Weight: 95},
+			"canary": {Backend: "v2", Weight: 5},
+		},
+		Sticky: true,
+	}
+
+	actual := GetSplit(labels)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+	if split := GetSplit(map[string]string{"traefik.frontend.split.sticky": "true"}); split != nil {
+		t.Errorf("expected no split, got %+v", split)
+	}
+}
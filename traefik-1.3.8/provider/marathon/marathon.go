@@ -35,17 +35,17 @@ var _ provider.Provider = (*Provider)(nil)
 // Provider holds configuration of the provider.
 type Provider struct {
 	provider.BaseProvider
-	Endpoint                string              `description:"Marathon server endpoint. You can also specify multiple endpoint for Marathon"`
-	Domain                  string              `description:"Default domain used"`
-	ExposedByDefault        bool                `description:"Expose Marathon apps by default"`
-	GroupsAsSubDomains      bool                `description:"Convert Marathon groups to subdomains"`
-	DCOSToken               string              `description:"DCOSToken for DCOS environment, This will override the Authorization header"`
-	MarathonLBCompatibility bool                `description:"Add compatibility with marathon-lb labels"`
-	TLS                     *provider.ClientTLS `description:"Enable Docker TLS support"`
-	DialerTimeout           flaeg.Duration      `description:"Set a non-default connection timeout for Marathon"`
-	KeepAlive               flaeg.Duration      `description:"Set a non-default TCP Keep Alive time in seconds"`
-	ForceTaskHostname       bool                `description:"Force to use the task's hostname."`
-	Basic                   *Basic              `description:"Enable basic authentication"`
+	Endpoint                string           `description:"Marathon server endpoint. You can also specify multiple endpoint for Marathon"`
+	Domain                  string           `description:"Default domain used"`
+	ExposedByDefault        bool             `description:"Expose Marathon apps by default"`
+	GroupsAsSubDomains      bool             `description:"Convert Marathon groups to subdomains"`
+	DCOSToken               string           `description:"DCOSToken for DCOS environment, This will override the Authorization header"`
+	MarathonLBCompatibility bool             `description:"Add compatibility with marathon-lb labels"`
+	TLS                     *types.ClientTLS `description:"Enable Docker TLS support"`
+	DialerTimeout           flaeg.Duration   `description:"Set a non-default connection timeout for Marathon"`
+	KeepAlive               flaeg.Duration   `description:"Set a non-default TCP Keep Alive time in seconds"`
+	ForceTaskHostname       bool             `description:"Force to use the task's hostname."`
+	Basic                   *Basic           `description:"Enable basic authentication"`
 	marathonClient          marathon.Marathon
 }
 
@@ -149,6 +149,15 @@ func (p *Provider) loadMarathonConfig() *types.Configuration {
 		"getPassHostHeader":           p.getPassHostHeader,
 		"getPriority":                 p.getPriority,
 		"getEntryPoints":              p.getEntryPoints,
+		"getRedirect":                 p.getRedirect,
+		"getErrorPages":               p.getErrorPages,
+		"getHeaders":                  p.getHeaders,
+		"getRateLimit":                p.getRateLimit,
+		"getMirror":                   p.getMirror,
+		"getSplit":                    p.getSplit,
+		"getBuffering":                p.getBuffering,
+		"getAuth":                     p.getAuth,
+		"getPassTLSClientCert":        p.getPassTLSClientCert,
 		"getFrontendRule":             p.getFrontendRule,
 		"getFrontendBackend":          p.getFrontendBackend,
 		"hasCircuitBreakerLabels":     p.hasCircuitBreakerLabels,
@@ -374,6 +383,42 @@ func (p *Provider) getEntryPoints(application marathon.Application) []string {
 	return []string{}
 }
 
+func (p *Provider) getRedirect(application marathon.Application) *types.Redirect {
+	return provider.GetRedirect(*application.Labels)
+}
+
+func (p *Provider) getErrorPages(application marathon.Application) map[string]*types.ErrorPage {
+	return provider.GetErrorPages(*application.Labels)
+}
+
+func (p *Provider) getHeaders(application marathon.Application) *types.Headers {
+	return provider.GetHeaders(*application.Labels)
+}
+
+func (p *Provider) getRateLimit(application marathon.Application) *types.RateLimit {
+	return provider.GetRateLimit(*application.Labels)
+}
+
+func (p *Provider) getMirror(application marathon.Application) *types.Mirror {
+	return provider.GetMirror(*application.Labels)
+}
+
+func (p *Provider) getSplit(application marathon.Application) *types.Split {
+	return provider.GetSplit(*application.Labels)
+}
+
+func (p *Provider) getBuffering(application marathon.Application) *types.Buffering {
+	return provider.GetBuffering(*application.Labels)
+}
+
+func (p *Provider) getAuth(application marathon.Application) *types.Auth {
+	return provider.GetAuth(*application.Labels)
+}
+
+func (p *Provider) getPassTLSClientCert(application marathon.Application) *types.PassTLSClientCert {
+	return provider.GetPassTLSClientCert(*application.Labels)
+}
+
 // getFrontendRule returns the frontend rule for the specified application, using
 // it's label. It returns a default one (Host) if the label is not present.
 func (p *Provider) getFrontendRule(application marathon.Application) string {
@@ -177,6 +177,119 @@ func TestMarathonLoadConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			applications: &marathon.Applications{
+				Apps: []marathon.Application{
+					{
+						ID:    "/testRedirect",
+						Ports: []int{80},
+						Labels: &map[string]string{
+							"traefik.frontend.redirect.regex":                                `^http://www\.test\.localhost/(.*)`,
+							"traefik.frontend.redirect.replacement":                          "http://test.localhost/it's/$1",
+							"traefik.frontend.redirect.permanent":                            "true",
+							"traefik.frontend.errors.server.status":                          "500-599,404",
+							"traefik.frontend.errors.server.backend":                         "-errors",
+							"traefik.frontend.headers.customResponseHeaders.X-Frame-Options": "DENY",
+							"traefik.frontend.headers.sslRedirect":                           "true",
+							"traefik.frontend.headers.sslProxyHeaders.X-Forwarded-Proto":     "https",
+							"traefik.frontend.rateLimit.rateSet.minute.period":               "1m",
+							"traefik.frontend.rateLimit.rateSet.minute.average":              "60",
+							"traefik.backend.buffering.maxresponsebodybytes":                 "10485760",
+							"traefik.frontend.mirror.maxBodySize":                            "4096",
+							"traefik.frontend.mirror.backends.shadow.backend":                "-shadow",
+							"traefik.frontend.mirror.backends.shadow.percent":                "100",
+							"traefik.frontend.split.backends.canary.backend":                 "-canary",
+							"traefik.frontend.split.backends.canary.weight":                  "1",
+							"traefik.frontend.auth.forward.address":                          "http://auth.localhost",
+							"traefik.frontend.auth.forward.trustForwardHeader":               "true",
+							"traefik.frontend.passTLSClientCert.subjectCN":                   "X-Client-CN",
+						},
+					},
+				},
+			},
+			tasks: &marathon.Tasks{
+				Tasks: []marathon.Task{
+					{
+						ID:    "testRedirect",
+						AppID: "/testRedirect",
+						Host:  "localhost",
+						Ports: []int{80},
+						IPAddresses: []*marathon.IPAddress{
+							{
+								IPAddress: "127.0.0.1",
+								Protocol:  "tcp",
+							},
+						},
+					},
+				},
+			},
+			expectedFrontends: map[string]*types.Frontend{
+				`frontend-testRedirect`: {
+					Backend:        "backend-testRedirect",
+					PassHostHeader: true,
+					EntryPoints:    []string{},
+					Redirect: &types.Redirect{
+						Regex:       `^http://www\.test\.localhost/(.*)`,
+						Replacement: "http://test.localhost/it's/$1",
+						Permanent:   true,
+					},
+					Errors: map[string]*types.ErrorPage{
+						"server": {
+							Status:  []string{"500-599", "404"},
+							Backend: "backend-errors",
+						},
+					},
+					Headers: &types.Headers{
+						CustomResponseHeaders: map[string]string{"X-Frame-Options": "DENY"},
+						SSLRedirect:           true,
+						SSLProxyHeaders:       map[string]string{"X-Forwarded-Proto": "https"},
+					},
+					RateLimit: &types.RateLimit{
+						RateSet: map[string]*types.Rate{
+							"minute": {Period: "1m", Average: 60},
+						},
+					},
+					Mirror: &types.Mirror{
+						Backends: map[string]*types.MirrorBackend{
+							"shadow": {Backend: "backend-shadow", Percent: 100},
+						},
+						MaxBodySize: 4096,
+					},
+					Split: &types.Split{
+						Backends: map[string]*types.WeightedBackend{
+							"canary": {Backend: "backend-canary", Weight: 1},
+						},
+					},
+					Auth: &types.Auth{
+						Forward: &types.Forward{
+							Address:            "http://auth.localhost",
+							TrustForwardHeader: true,
+						},
+					},
+					PassTLSClientCert: &types.PassTLSClientCert{
+						SubjectCN: "X-Client-CN",
+					},
+					Routes: map[string]types.Route{
+						`route-host-testRedirect`: {
+							Rule: "Host:testRedirect.docker.localhost",
+						},
+					},
+				},
+			},
+			expectedBackends: map[string]*types.Backend{
+				"backend-testRedirect": {
+					Servers: map[string]types.Server{
+						"server-testRedirect": {
+							URL:    "http://localhost:80",
+							Weight: 0,
+						},
+					},
+					Buffering: &types.Buffering{
+						MaxResponseBodyBytes: 10485760,
+					},
+				},
+			},
+		},
 		{
 			applications: &marathon.Applications{
 				Apps: []marathon.Application{
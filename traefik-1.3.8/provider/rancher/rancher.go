@@ -86,11 +86,56 @@ func (p *Provider) getFrontendRule(service rancherData) string {
 	return "Host:" + strings.ToLower(strings.Replace(service.Name, "/", ".", -1)) + "." + p.Domain
 }
 
-func (p *Provider) getBasicAuth(service rancherData) []string {
-	if basicAuth, err := getServiceLabel(service, "traefik.frontend.auth.basic"); err == nil {
-		return strings.Split(basicAuth, ",")
+func (p *Provider) getRedirect(service rancherData) *types.Redirect {
+	return provider.GetRedirect(service.Labels)
+}
+
+func (p *Provider) getErrorPages(service rancherData) map[string]*types.ErrorPage {
+	errorPages := provider.GetErrorPages(service.Labels)
+	for _, errorPage := range errorPages {
+		errorPage.Backend = provider.Normalize(errorPage.Backend)
 	}
-	return []string{}
+	return errorPages
+}
+
+func (p *Provider) getHeaders(service rancherData) *types.Headers {
+	return provider.GetHeaders(service.Labels)
+}
+
+func (p *Provider) getRateLimit(service rancherData) *types.RateLimit {
+	return provider.GetRateLimit(service.Labels)
+}
+
+func (p *Provider) getMirror(service rancherData) *types.Mirror {
+	mirror := provider.GetMirror(service.Labels)
+	if mirror != nil {
+		for _, backend := range mirror.Backends {
+			backend.Backend = provider.Normalize(backend.Backend)
+		}
+	}
+	return mirror
+}
+
+func (p *Provider) getSplit(service rancherData) *types.Split {
+	split := provider.GetSplit(service.Labels)
+	if split != nil {
+		for _, backend := range split.Backends {
+			backend.Backend = provider.Normalize(backend.Backend)
+		}
+	}
+	return split
+}
+
+func (p *Provider) getBuffering(service rancherData) *types.Buffering {
+	return provider.GetBuffering(service.Labels)
+}
+
+func (p *Provider) getAuth(service rancherData) *types.Auth {
+	return provider.GetAuth(service.Labels)
+}
+
+func (p *Provider) getPassTLSClientCert(service rancherData) *types.PassTLSClientCert {
+	return provider.GetPassTLSClientCert(service.Labels)
 }
 
 func (p *Provider) getFrontendName(service rancherData) string {
@@ -419,7 +464,15 @@ func (p *Provider) loadRancherConfig(services []rancherData) *types.Configuratio
 		"getPassHostHeader":           p.getPassHostHeader,
 		"getPriority":                 p.getPriority,
 		"getEntryPoints":              p.getEntryPoints,
-		"getBasicAuth":                p.getBasicAuth,
+		"getRedirect":                 p.getRedirect,
+		"getErrorPages":               p.getErrorPages,
+		"getHeaders":                  p.getHeaders,
+		"getRateLimit":                p.getRateLimit,
+		"getMirror":                   p.getMirror,
+		"getSplit":                    p.getSplit,
+		"getBuffering":                p.getBuffering,
+		"getAuth":                     p.getAuth,
+		"getPassTLSClientCert":        p.getPassTLSClientCert,
 		"getFrontendRule":             p.getFrontendRule,
 		"hasCircuitBreakerLabel":      p.hasCircuitBreakerLabel,
 		"getCircuitBreakerExpression": p.getCircuitBreakerExpression,
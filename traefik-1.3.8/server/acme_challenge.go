@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/codegangsta/negroni"
+	"github.com/containous/traefik/acme"
+	"github.com/containous/traefik/log"
+)
+
+// acmeChallengePath is the path prefix of the ACME HTTP-01 challenges
+const acmeChallengePath = "/.well-known/acme-challenge/"
+
+// acmeHTTPChallengeHandler answers the ACME HTTP-01 challenges on the entry
+// point, ahead of its authentication and of the frontends.
+func acmeHTTPChallengeHandler(a *acme.ACME) negroni.Handler {
+	return negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if !strings.HasPrefix(r.URL.Path, acmeChallengePath) {
+			next(rw, r)
+			return
+		}
+		token := strings.TrimPrefix(r.URL.Path, acmeChallengePath)
+		domain, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			domain = r.Host
+		}
+		if len(token) > 0 && !strings.Contains(token, "/") {
+			if keyAuth, ok := a.GetHTTPChallengeToken(token, domain); ok {
+				log.Debugf("Answering ACME HTTP challenge for %s", domain)
+				rw.Header().Set("Content-Type", "text/plain")
+				rw.WriteHeader(http.StatusOK)
+				rw.Write(keyAuth)
+				return
+			}
+		}
+		log.Warnf("No ACME HTTP challenge for token %q of %s", token, domain)
+		http.NotFound(rw, r)
+	})
+}
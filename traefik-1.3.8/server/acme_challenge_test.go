@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/acme"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestACMEHTTPChallengeHandler(t *testing.T) {
+	handler := acmeHTTPChallengeHandler(&acme.ACME{HTTPChallenge: &acme.HTTPChallenge{EntryPoint: "http"}})
+	tests := []struct {
+		desc     string
+		path     string
+		expected int
+	}{
+		{desc: "other path", path: "/foo", expected: http.StatusTeapot},
+		{desc: "unknown token", path: "/.well-known/acme-challenge/token", expected: http.StatusNotFound},
+		{desc: "no token", path: "/.well-known/acme-challenge/", expected: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://foo.com"+test.path, nil)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req, func(rw http.ResponseWriter, r *http.Request) {
+			rw.WriteHeader(http.StatusTeapot)
+		})
+		assert.Equal(t, test.expected, recorder.Code, test.desc)
+	}
+}
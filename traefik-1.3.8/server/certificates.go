@@ -0,0 +1,241 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/safe"
+	kitmetrics "github.com/go-kit/kit/metrics"
+)
+
+// certificateStore holds the certificates of a TLS entrypoint delivered by the
+// providers, by domain. They are replaced all at once on each configuration
+// reload, without restarting the entrypoint.
+type certificateStore struct {
+	certificates *safe.Safe
+}
+
+func newCertificateStore() *certificateStore {
+	return &certificateStore{certificates: safe.New(map[string]*tls.Certificate{})}
+}
+
+func (c *certificateStore) get() map[string]*tls.Certificate {
+	return c.certificates.Get().(map[string]*tls.Certificate)
+}
+
+func (c *certificateStore) set(certificates map[string]*tls.Certificate) {
+	c.certificates.Set(certificates)
+}
+
+// getCertificate returns the certificate of the server name, a wildcard
+// certificate matching it, or the certificate returned by next otherwise
+func (c *certificateStore) getCertificate(next func(*tls.ClientHelloInfo) (*tls.Certificate, error)) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if certificate := c.match(clientHello.ServerName); certificate != nil {
+			return certificate, nil
+		}
+		if next != nil {
+			return next(clientHello)
+		}
+		return nil, nil
+	}
+}
+
+func (c *certificateStore) match(serverName string) *tls.Certificate {
+	return matchCertificate(c.get(), serverName)
+}
+
+// matchCertificate returns the certificate of the server name, or the wildcard
+// certificate matching it
+func matchCertificate(certificates map[string]*tls.Certificate, serverName string) *tls.Certificate {
+	name := strings.TrimSuffix(strings.ToLower(serverName), ".")
+	if len(name) == 0 {
+		return nil
+	}
+	if certificate, ok := certificates[name]; ok {
+		return certificate
+	}
+	labels := strings.Split(name, ".")
+	labels[0] = "*"
+	return certificates[strings.Join(labels, ".")]
+}
+
+// sniCertificates selects the certificate of the handshakes on a TLS entry
+// point, the default certificate being the first one unless configured
+type sniCertificates struct {
+	entryPointName     string
+	next               func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	certificates       map[string]*tls.Certificate
+	defaultCertificate *tls.Certificate
+	strict             bool
+	rejected           kitmetrics.Counter
+}
+
+func newSNICertificates(entryPointName string, tlsOption *TLS, config *tls.Config, rejected kitmetrics.Counter) (*sniCertificates, error) {
+	s := &sniCertificates{
+		entryPointName: entryPointName,
+		next:           config.GetCertificate,
+		certificates:   map[string]*tls.Certificate{},
+		strict:         tlsOption.StrictSNI,
+		rejected:       rejected,
+	}
+	for name, certificate := range config.NameToCertificate {
+		s.certificates[strings.ToLower(name)] = certificate
+	}
+	if tlsOption.DefaultCertificate != nil {
+		cert, err := loadCertificate(tlsOption.DefaultCertificate.CertFile, tlsOption.DefaultCertificate.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default certificate: %v", err)
+		}
+		names, err := certificateNames(&cert)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default certificate: %v", err)
+		}
+		for _, name := range names {
+			if _, exists := s.certificates[name]; !exists {
+				s.certificates[name] = &cert
+			}
+		}
+		s.defaultCertificate = &cert
+	} else if len(config.Certificates) > 0 {
+		s.defaultCertificate = &config.Certificates[0]
+	}
+	return s, nil
+}
+
+// getCertificate returns the certificate returned by next, the certificate of
+// the entry point matching the server name, or the default certificate. In
+// strict mode, the handshakes without a matching certificate are rejected.
+func (s *sniCertificates) getCertificate(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	// like crypto/tls, next isn't asked for the handshakes without server name
+	if s.next != nil && len(clientHello.ServerName) > 0 {
+		certificate, err := s.next(clientHello)
+		if certificate != nil || err != nil {
+			return certificate, err
+		}
+	}
+	if certificate := matchCertificate(s.certificates, clientHello.ServerName); certificate != nil {
+		return certificate, nil
+	}
+	if !s.strict {
+		return s.defaultCertificate, nil
+	}
+	reason := "unknown_sni"
+	if len(clientHello.ServerName) == 0 {
+		reason = "no_sni"
+	}
+	if s.rejected != nil {
+		s.rejected.With("entrypoint", s.entryPointName, "reason", reason).Add(1)
+	}
+	log.Debugf("Rejecting TLS handshake on entrypoint %s for server name %q: no matching certificate", s.entryPointName, clientHello.ServerName)
+	return nil, fmt.Errorf("no certificate for server name %q", clientHello.ServerName)
+}
+
+// served returns the certificates of the entry point, the default one included
+func (s *sniCertificates) served() []*tls.Certificate {
+	var certificates []*tls.Certificate
+	if s.defaultCertificate != nil {
+		certificates = append(certificates, s.defaultCertificate)
+	}
+	for _, certificate := range s.certificates {
+		certificates = append(certificates, certificate)
+	}
+	return certificates
+}
+
+// servedCertificates returns the certificates served by the TLS entrypoints:
+// the certificates of their configuration, the ones delivered by the providers
+// and the ACME ones.
+func (server *Server) servedCertificates() []*tls.Certificate {
+	var certificates []*tls.Certificate
+	for _, serverEntryPoint := range server.serverEntryPoints {
+		certificates = append(certificates, serverEntryPoint.tlsCertificates...)
+		for _, certificate := range serverEntryPoint.certificates.get() {
+			certificates = append(certificates, certificate)
+		}
+	}
+	if server.globalConfiguration.ACME != nil {
+		certificates = append(certificates, server.globalConfiguration.ACME.Certificates()...)
+	}
+	return certificates
+}
+
+// loadProvidedCertificates loads the certificates delivered by the providers in
+// the stores of their entrypoints. The certificates which can't be loaded are
+// skipped, and the first provider serving a domain wins.
+func loadProvidedCertificates(configurations configs, globalConfiguration GlobalConfiguration, serverEntryPoints map[string]*serverEntryPoint) {
+	certificates := map[string]map[string]*tls.Certificate{}
+	for entryPointName, entryPoint := range globalConfiguration.EntryPoints {
+		if entryPoint.TLS != nil {
+			certificates[entryPointName] = map[string]*tls.Certificate{}
+		}
+	}
+
+	providerNames := make([]string, 0, len(configurations))
+	for providerName := range configurations {
+		providerNames = append(providerNames, providerName)
+	}
+	sort.Strings(providerNames)
+
+	for _, providerName := range providerNames {
+		for _, tlsConfiguration := range configurations[providerName].TLS {
+			cert, err := loadCertificate(tlsConfiguration.CertFile, tlsConfiguration.KeyFile)
+			if err != nil {
+				log.Errorf("Error loading certificate from provider %s: %v", providerName, err)
+				continue
+			}
+			names, err := certificateNames(&cert)
+			if err != nil {
+				log.Errorf("Error parsing certificate from provider %s: %v", providerName, err)
+				continue
+			}
+			entryPointNames := tlsConfiguration.EntryPoints
+			if len(entryPointNames) == 0 {
+				for entryPointName := range certificates {
+					entryPointNames = append(entryPointNames, entryPointName)
+				}
+			}
+			for _, entryPointName := range entryPointNames {
+				entryPointCertificates, ok := certificates[entryPointName]
+				if !ok {
+					log.Errorf("Certificate %s from provider %s: unknown TLS entrypoint %s", names, providerName, entryPointName)
+					continue
+				}
+				for _, name := range names {
+					if _, exists := entryPointCertificates[name]; exists {
+						log.Warnf("Certificate for %s from provider %s ignored on entrypoint %s, already provided", name, providerName, entryPointName)
+						continue
+					}
+					entryPointCertificates[name] = &cert
+				}
+			}
+		}
+	}
+
+	for entryPointName, entryPointCertificates := range certificates {
+		if serverEntryPoint, ok := serverEntryPoints[entryPointName]; ok {
+			serverEntryPoint.certificates.set(entryPointCertificates)
+		}
+	}
+}
+
+// certificateNames returns the domains of the certificate, in lower case
+func certificateNames(cert *tls.Certificate) ([]string, error) {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	cert.Leaf = leaf
+	var names []string
+	if len(leaf.Subject.CommonName) > 0 && len(leaf.DNSNames) == 0 {
+		names = append(names, strings.ToLower(leaf.Subject.CommonName))
+	}
+	for _, name := range leaf.DNSNames {
+		names = append(names, strings.ToLower(name))
+	}
+	return names, nil
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	kitmetrics "github.com/go-kit/kit/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProvidedCertificates(t *testing.T) {
+	exampleCert, exampleKey := generateCertificate(t, "example.com", "www.example.com")
+	otherCert, otherKey := generateCertificate(t, "example.com")
+	wildcardCert, wildcardKey := generateCertificate(t, "*.wildcard.com")
+
+	globalConfiguration := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http":  &EntryPoint{},
+			"https": &EntryPoint{TLS: &TLS{}},
+			"admin": &EntryPoint{TLS: &TLS{}},
+		},
+	}
+	configurations := configs{
+		"file": &types.Configuration{
+			TLS: []*types.TLSConfiguration{
+				{CertFile: exampleCert, KeyFile: exampleKey},
+				{EntryPoints: []string{"admin", "http", "unknown"}, CertFile: wildcardCert, KeyFile: wildcardKey},
+				{CertFile: "invalid", KeyFile: "invalid"},
+			},
+		},
+		"web": &types.Configuration{
+			TLS: []*types.TLSConfiguration{
+				{EntryPoints: []string{"https"}, CertFile: otherCert, KeyFile: otherKey},
+			},
+		},
+	}
+
+	server := &Server{globalConfiguration: globalConfiguration}
+	server.serverEntryPoints = server.buildEntryPoints(globalConfiguration)
+	loadProvidedCertificates(configurations, globalConfiguration, server.serverEntryPoints)
+
+	https := server.serverEntryPoints["https"].certificates.get()
+	assert.Len(t, https, 2)
+	require.NotNil(t, https["example.com"])
+	assert.Equal(t, []string{"example.com", "www.example.com"}, https["example.com"].Leaf.DNSNames, "the first provider wins")
+	assert.Equal(t, https["example.com"], https["www.example.com"])
+
+	admin := server.serverEntryPoints["admin"].certificates.get()
+	assert.Len(t, admin, 3)
+	assert.NotNil(t, admin["*.wildcard.com"])
+	assert.Empty(t, server.serverEntryPoints["http"].certificates.get())
+
+	// the certificates are served without static certificates
+	config, err := server.createTLSConfig("admin", globalConfiguration.EntryPoints["admin"].TLS, nil)
+	require.NoError(t, err)
+	for serverName, expected := range map[string]*tls.Certificate{
+		"WWW.example.com":      admin["www.example.com"],
+		"foo.wildcard.com":     admin["*.wildcard.com"],
+		"foo.bar.wildcard.com": nil,
+		"unknown.com":          nil,
+		"":                     nil,
+	} {
+		certificate, err := config.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+		require.NoError(t, err)
+		assert.Equal(t, expected, certificate, serverName)
+	}
+
+	// the certificates are replaced on reload
+	loadProvidedCertificates(configs{}, globalConfiguration, server.serverEntryPoints)
+	certificate, err := config.GetCertificate(&tls.ClientHelloInfo{ServerName: "www.example.com"})
+	require.NoError(t, err)
+	assert.Nil(t, certificate)
+}
+
+// rejectedCounter counts the values added to a counter by label values
+type rejectedCounter struct {
+	labels []string
+	counts map[string]float64
+}
+
+func (c *rejectedCounter) With(labelValues ...string) kitmetrics.Counter {
+	return &rejectedCounter{labels: append(append([]string{}, c.labels...), labelValues...), counts: c.counts}
+}
+
+func (c *rejectedCounter) Add(delta float64) {
+	c.counts[strings.Join(c.labels, ",")] += delta
+}
+
+func TestSNICertificates(t *testing.T) {
+	cert, key := generateCertificate(t, "example.com")
+	defaultCert, defaultKey := generateCertificate(t, "default.example.org")
+
+	tests := []struct {
+		desc     string
+		tls      *TLS
+		expected map[string]string
+		rejected map[string]float64
+	}{
+		{
+			desc: "default certificate",
+			tls:  &TLS{DefaultCertificate: &Certificate{CertFile: defaultCert, KeyFile: defaultKey}},
+			expected: map[string]string{
+				"example.com":         "example.com",
+				"unknown.com":         "default.example.org",
+				"":                    "default.example.org",
+				"default.example.org": "default.example.org",
+			},
+		},
+		{
+			desc: "strict SNI",
+			tls:  &TLS{StrictSNI: true, DefaultCertificate: &Certificate{CertFile: defaultCert, KeyFile: defaultKey}},
+			expected: map[string]string{
+				"EXAMPLE.com":         "example.com",
+				"unknown.com":         "",
+				"":                    "",
+				"default.example.org": "default.example.org",
+			},
+			rejected: map[string]float64{
+				"entrypoint,https,reason,unknown_sni": 1,
+				"entrypoint,https,reason,no_sni":      1,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test.tls.Certificates = Certificates{{CertFile: cert, KeyFile: key}}
+		config, err := test.tls.Certificates.CreateTLSConfig()
+		require.NoError(t, err)
+		config.BuildNameToCertificate()
+		rejected := &rejectedCounter{counts: map[string]float64{}}
+		sniCertificates, err := newSNICertificates("https", test.tls, config, rejected)
+		require.NoError(t, err)
+
+		for serverName, expected := range test.expected {
+			certificate, err := sniCertificates.getCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+			if len(expected) == 0 {
+				assert.Error(t, err, "%s: %s", test.desc, serverName)
+				continue
+			}
+			require.NoError(t, err)
+			require.NotNil(t, certificate, "%s: %s", test.desc, serverName)
+			leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+			require.NoError(t, err)
+			assert.Equal(t, expected, leaf.Subject.CommonName, "%s: %s", test.desc, serverName)
+		}
+		if test.rejected == nil {
+			test.rejected = map[string]float64{}
+		}
+		assert.Equal(t, test.rejected, rejected.counts, test.desc)
+	}
+
+	_, err := newSNICertificates("https", &TLS{DefaultCertificate: &Certificate{CertFile: "invalid", KeyFile: "invalid"}}, &tls.Config{}, nil)
+	assert.Error(t, err)
+
+	// the handshakes don't fall back to the first certificate in strict mode
+	tlsOption := &TLS{StrictSNI: true, Certificates: Certificates{{CertFile: cert, KeyFile: key}}}
+	server := &Server{globalConfiguration: GlobalConfiguration{EntryPoints: EntryPoints{"https": &EntryPoint{TLS: tlsOption}}}}
+	config, err := server.createTLSConfig("https", tlsOption, nil)
+	require.NoError(t, err)
+	assert.Empty(t, config.Certificates)
+	require.NotNil(t, config.GetCertificate)
+	_, err = config.GetCertificate(&tls.ClientHelloInfo{})
+	assert.Error(t, err)
+}
+
+func TestTLSConfigurationJSONHidesKeys(t *testing.T) {
+	cert, key := generateCertificate(t, "example.com")
+	data, err := (&types.TLSConfiguration{CertFile: cert, KeyFile: key}).MarshalJSON()
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "PRIVATE KEY")
+	assert.Contains(t, string(data), "CERTIFICATE")
+
+	data, err = (&types.TLSConfiguration{CertFile: "/etc/ssl/example.crt", KeyFile: "/etc/ssl/example.key"}).MarshalJSON()
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "/etc/ssl/example.key")
+}
+
+func TestCheckHiddenKeys(t *testing.T) {
+	cert, key := generateCertificate(t, "example.com")
+	data, err := json.Marshal(&types.Configuration{TLS: []*types.TLSConfiguration{{CertFile: cert, KeyFile: key}}})
+	require.NoError(t, err)
+
+	// a configuration read from the API can't be sent back as is
+	configuration := new(types.Configuration)
+	require.NoError(t, json.Unmarshal(data, configuration))
+	assert.Error(t, checkHiddenKeys(configuration))
+
+	configuration.TLS[0].KeyFile = key
+	assert.NoError(t, checkHiddenKeys(configuration))
+}
+
+func generateCertificate(t *testing.T, domains ...string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: domains[0]},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     domains,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+)
+
+// clientAuthTypes are the client certificate verification modes of the TLS
+// entrypoints, by lower case name
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":             tls.NoClientCert,
+	"request":          tls.RequestClientCert,
+	"verifyifgiven":    tls.VerifyClientCertIfGiven,
+	"require":          tls.RequireAnyClientCert,
+	"requireandverify": tls.RequireAndVerifyClientCert,
+}
+
+// clientAuthType returns the client certificate verification mode of the TLS
+// profile, requireAndVerify by default when it has client CAs
+func (profile *TLSProfile) clientAuthType() (tls.ClientAuthType, error) {
+	if len(profile.ClientAuth) == 0 {
+		if len(profile.ClientCAFiles) > 0 {
+			return tls.RequireAndVerifyClientCert, nil
+		}
+		return tls.NoClientCert, nil
+	}
+	clientAuth, ok := clientAuthTypes[strings.ToLower(profile.ClientAuth)]
+	if !ok {
+		return tls.NoClientCert, fmt.Errorf("invalid client auth %q, expected none, request, verifyIfGiven, require or requireAndVerify", profile.ClientAuth)
+	}
+	if (clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert) && len(profile.ClientCAFiles) == 0 {
+		return tls.NoClientCert, fmt.Errorf("client auth %s requires client CA files", profile.ClientAuth)
+	}
+	return clientAuth, nil
+}
+
+// configureClientAuth sets the client certificate verification of the TLS
+// profile: its mode, its CAs, and the CRLs the client certificates are checked
+// against
+func configureClientAuth(config *tls.Config, profile *TLSProfile) error {
+	clientAuth, err := profile.clientAuthType()
+	if err != nil {
+		return err
+	}
+	config.ClientAuth = clientAuth
+	config.ClientCAs = nil
+	config.VerifyPeerCertificate = nil
+	if len(profile.ClientCAFiles) == 0 {
+		if len(profile.ClientCRLFiles) > 0 {
+			return errors.New("client CRL files require client CA files")
+		}
+		return nil
+	}
+
+	pool := x509.NewCertPool()
+	var caCerts []*x509.Certificate
+	for _, caFile := range profile.ClientCAFiles {
+		data, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return err
+		}
+		certs, err := parsePEMCertificates(data)
+		if err != nil || len(certs) == 0 {
+			return errors.New("invalid certificate(s) in " + caFile)
+		}
+		for _, cert := range certs {
+			pool.AddCert(cert)
+		}
+		caCerts = append(caCerts, certs...)
+	}
+	config.ClientCAs = pool
+
+	if len(profile.ClientCRLFiles) > 0 {
+		revoked, err := loadRevokedCertificates(profile.ClientCRLFiles, caCerts)
+		if err != nil {
+			return err
+		}
+		config.VerifyPeerCertificate = revoked.verifyPeerCertificate
+	}
+	return nil
+}
+
+func parsePEMCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+}
+
+// revokedCertificates holds the serial numbers of the revoked certificates, by
+// issuer
+type revokedCertificates map[string]map[string]bool
+
+// loadRevokedCertificates reads the PEM or DER CRLs, which must be signed by one
+// of the CAs
+func loadRevokedCertificates(crlFiles []string, caCerts []*x509.Certificate) (revokedCertificates, error) {
+	revoked := revokedCertificates{}
+	for _, crlFile := range crlFiles {
+		data, err := ioutil.ReadFile(crlFile)
+		if err != nil {
+			return nil, err
+		}
+		crl, err := x509.ParseCRL(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CRL in %s: %v", crlFile, err)
+		}
+		issuer := crlIssuer(crl, caCerts)
+		if issuer == nil {
+			return nil, fmt.Errorf("the CRL in %s is not signed by a client CA", crlFile)
+		}
+		if crl.HasExpired(time.Now()) {
+			log.Warnf("The CRL in %s has expired since %s", crlFile, crl.TBSCertList.NextUpdate)
+		}
+		key := string(issuer.RawSubject)
+		if revoked[key] == nil {
+			revoked[key] = map[string]bool{}
+		}
+		for _, revokedCert := range crl.TBSCertList.RevokedCertificates {
+			revoked[key][revokedCert.SerialNumber.String()] = true
+		}
+	}
+	return revoked, nil
+}
+
+func crlIssuer(crl *pkix.CertificateList, caCerts []*x509.Certificate) *x509.Certificate {
+	for _, caCert := range caCerts {
+		if caCert.CheckCRLSignature(crl) == nil {
+			return caCert
+		}
+	}
+	return nil
+}
+
+// verifyPeerCertificate rejects the client certificates which are revoked
+func (r revokedCertificates) verifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	for _, rawCert := range rawCerts {
+		cert, err := x509.ParseCertificate(rawCert)
+		if err != nil {
+			return err
+		}
+		if r[string(cert.RawIssuer)][cert.SerialNumber.String()] {
+			return fmt.Errorf("client certificate %s of %s is revoked", cert.SerialNumber, cert.Subject.CommonName)
+		}
+	}
+	return nil
+}
+
+// matchDomains returns whether the domain is one of the domains, which can be
+// wildcards matching one level of subdomains
+func matchDomains(domains []string, domain string) bool {
+	for _, pattern := range domains {
+		if matchDomain(pattern, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchDomain(pattern string, domain string) bool {
+	pattern = types.CanonicalDomain(pattern)
+	domain = types.CanonicalDomain(domain)
+	if pattern == domain {
+		return true
+	}
+	if strings.HasPrefix(pattern, "*.") {
+		if dot := strings.Index(domain, "."); dot > 0 && domain[dot:] == pattern[1:] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientAuthType(t *testing.T) {
+	tests := []struct {
+		desc     string
+		profile  *TLSProfile
+		expected tls.ClientAuthType
+		err      bool
+	}{
+		{desc: "no CA", profile: &TLSProfile{}, expected: tls.NoClientCert},
+		{desc: "CA", profile: &TLSProfile{ClientCAFiles: []string{"ca.pem"}}, expected: tls.RequireAndVerifyClientCert},
+		{desc: "request", profile: &TLSProfile{ClientAuth: "request"}, expected: tls.RequestClientCert},
+		{desc: "require", profile: &TLSProfile{ClientAuth: "Require"}, expected: tls.RequireAnyClientCert},
+		{desc: "verify if given", profile: &TLSProfile{ClientAuth: "verifyIfGiven", ClientCAFiles: []string{"ca.pem"}}, expected: tls.VerifyClientCertIfGiven},
+		{desc: "none with CA", profile: &TLSProfile{ClientAuth: "none", ClientCAFiles: []string{"ca.pem"}}, expected: tls.NoClientCert},
+		{desc: "verify without CA", profile: &TLSProfile{ClientAuth: "requireAndVerify"}, err: true},
+		{desc: "unknown", profile: &TLSProfile{ClientAuth: "always"}, err: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			clientAuth, err := test.profile.clientAuthType()
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, clientAuth)
+		})
+	}
+}
+
+func TestConfigureClientAuthCRL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "traefik-client-auth")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Client CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0600))
+
+	clientCert := func(serial int64) []byte {
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "client"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &caKey.PublicKey, caKey)
+		require.NoError(t, err)
+		return der
+	}
+	valid := clientCert(2)
+	revoked := clientCert(3)
+
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now(),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{{SerialNumber: big.NewInt(3), RevocationTime: time.Now()}},
+	}, ca, caKey)
+	require.NoError(t, err)
+	crlFile := filepath.Join(dir, "ca.crl")
+	require.NoError(t, ioutil.WriteFile(crlFile, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crlDER}), 0600))
+
+	config := &tls.Config{}
+	err = configureClientAuth(config, &TLSProfile{ClientAuth: "verifyIfGiven", ClientCAFiles: []string{caFile}, ClientCRLFiles: []string{crlFile}})
+	require.NoError(t, err)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, config.ClientAuth)
+	require.NotNil(t, config.ClientCAs)
+	require.NotNil(t, config.VerifyPeerCertificate)
+	assert.NoError(t, config.VerifyPeerCertificate([][]byte{valid}, nil))
+	assert.Error(t, config.VerifyPeerCertificate([][]byte{revoked}, nil))
+
+	// the CRLs must be signed by a client CA
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherCRL, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{Number: big.NewInt(1), ThisUpdate: time.Now(), NextUpdate: time.Now().Add(time.Hour)}, ca, otherKey)
+	require.NoError(t, err)
+	otherCRLFile := filepath.Join(dir, "other.crl")
+	require.NoError(t, ioutil.WriteFile(otherCRLFile, otherCRL, 0600))
+	err = configureClientAuth(&tls.Config{}, &TLSProfile{ClientCAFiles: []string{caFile}, ClientCRLFiles: []string{otherCRLFile}})
+	assert.Error(t, err)
+
+	err = configureClientAuth(&tls.Config{}, &TLSProfile{ClientCRLFiles: []string{crlFile}})
+	assert.Error(t, err)
+}
+
+func TestClientAuthHosts(t *testing.T) {
+	tlsOption := &TLS{ClientAuthHosts: []string{"admin.example.com", "*.secure.example.com"}}
+	config := &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert}
+	profileConfigs, err := serverNameConfigs(config, tlsOption)
+	require.NoError(t, err)
+	getConfigForClient := getConfigForClient(tlsOption, profileConfigs)
+
+	for serverName, clientAuth := range map[string]bool{
+		"admin.example.com":      true,
+		"ADMIN.example.com":      true,
+		"api.secure.example.com": true,
+		"secure.example.com":     false,
+		"www.example.com":        false,
+		"":                       false,
+	} {
+		clientConfig, err := getConfigForClient(&tls.ClientHelloInfo{ServerName: serverName})
+		require.NoError(t, err)
+		if clientAuth {
+			assert.Nil(t, clientConfig, serverName)
+		} else {
+			require.NotNil(t, clientConfig, serverName)
+			assert.Equal(t, tls.NoClientCert, clientConfig.ClientAuth, serverName)
+		}
+	}
+
+	handler := serverNameConfigHandler(tlsOption)
+	tests := []struct {
+		desc       string
+		host       string
+		serverName string
+		expected   int
+	}{
+		{desc: "client auth host", host: "admin.example.com", serverName: "admin.example.com", expected: http.StatusOK},
+		{desc: "other host", host: "www.example.com", serverName: "www.example.com", expected: http.StatusOK},
+		{desc: "other host without server name", host: "www.example.com", serverName: "", expected: http.StatusOK},
+		{desc: "client auth host on another connection", host: "admin.example.com:443", serverName: "www.example.com", expected: http.StatusMisdirectedRequest},
+		{desc: "client auth wildcard on another connection", host: "api.secure.example.com", serverName: "", expected: http.StatusMisdirectedRequest},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "https://"+test.host+"/", nil)
+		req.TLS = &tls.ConnectionState{ServerName: test.serverName}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req, func(rw http.ResponseWriter, r *http.Request) {})
+		assert.Equal(t, test.expected, recorder.Code, test.desc)
+	}
+}
@@ -56,6 +56,8 @@ type GlobalConfiguration struct {
 	InsecureSkipVerify        bool                    `description:"Disable SSL certificate verification"`
 	Retry                     *Retry                  `description:"Enable retry sending request if network error"`
 	HealthCheck               *HealthCheckConfig      `description:"Health check parameters"`
+	RejectRouteConflicts      bool                    `description:"Reject frontends with the same name or rules as another one, or shadowed by another one, instead of routing to one of them"`
+	OCSPStapling              *OCSPStapling           `description:"Staple OCSP responses to the TLS handshakes"`
 	Docker                    *docker.Provider        `description:"Enable Docker backend"`
 	File                      *file.Provider          `description:"Enable File backend"`
 	Web                       *WebProvider            `description:"Enable Web backend"`
@@ -124,7 +126,7 @@ func (ep *EntryPoints) String() string {
 // Set's argument is a string to be parsed to set the flag.
 // It's a comma-separated list, so we split it.
 func (ep *EntryPoints) Set(value string) error {
-	regex := regexp.MustCompile("(?:Name:(?P<Name>\\S*))\\s*(?:Address:(?P<Address>\\S*))?\\s*(?:TLS:(?P<TLS>\\S*))?\\s*((?P<TLSACME>TLS))?\\s*(?:CA:(?P<CA>\\S*))?\\s*(?:Redirect.EntryPoint:(?P<RedirectEntryPoint>\\S*))?\\s*(?:Redirect.Regex:(?P<RedirectRegex>\\S*))?\\s*(?:Redirect.Replacement:(?P<RedirectReplacement>\\S*))?\\s*(?:Compress:(?P<Compress>\\S*))?")
+	regex := regexp.MustCompile("(?:Name:(?P<Name>\\S*))\\s*(?:Address:(?P<Address>\\S*))?\\s*(?:TLS:(?P<TLS>\\S*))?\\s*((?P<TLSACME>TLS))?\\s*(?:CA:(?P<CA>\\S*))?\\s*(?:ClientAuth:(?P<ClientAuth>\\S*))?\\s*(?:Redirect.EntryPoint:(?P<RedirectEntryPoint>\\S*))?\\s*(?:Redirect.Regex:(?P<RedirectRegex>\\S*))?\\s*(?:Redirect.Replacement:(?P<RedirectReplacement>\\S*))?\\s*(?:Compress:(?P<Compress>\\S*))?")
 	match := regex.FindAllStringSubmatch(value, -1)
 	if match == nil {
 		return errors.New("Bad EntryPoints format: " + value)
@@ -154,6 +156,9 @@ func (ep *EntryPoints) Set(value string) error {
 		files := strings.Split(result["CA"], ",")
 		tls.ClientCAFiles = files
 	}
+	if len(result["ClientAuth"]) > 0 {
+		tls.ClientAuth = result["ClientAuth"]
+	}
 	var redirect *Redirect
 	if len(result["RedirectEntryPoint"]) > 0 || len(result["RedirectRegex"]) > 0 || len(result["RedirectReplacement"]) > 0 {
 		redirect = &Redirect{
@@ -195,12 +200,19 @@ func (ep *EntryPoints) Type() string {
 
 // EntryPoint holds an entry point configuration of the reverse proxy (ip, port, TLS...)
 type EntryPoint struct {
-	Network  string
-	Address  string
-	TLS      *TLS
-	Redirect *Redirect
-	Auth     *types.Auth
-	Compress bool
+	Network          string
+	Address          string
+	TLS              *TLS
+	Redirect         *Redirect
+	Auth             *types.Auth
+	Compress         bool
+	ForwardedHeaders *ForwardedHeaders
+	DefaultBackend   string
+}
+
+// ForwardedHeaders configures which peers are trusted to forward the client address
+type ForwardedHeaders struct {
+	TrustedIPs []string
 }
 
 // Redirect configures a redirection of an entry point to another, or to an URL
@@ -212,10 +224,29 @@ type Redirect struct {
 
 // TLS configures TLS for an entry point
 type TLS struct {
-	MinVersion    string
-	CipherSuites  []string
-	Certificates  Certificates
-	ClientCAFiles []string
+	MinVersion      string
+	CipherSuites    []string
+	Certificates    Certificates
+	ClientCAFiles   []string
+	ClientAuth      string   // none, request, verifyIfGiven, require or requireAndVerify
+	ClientCRLFiles  []string // CRLs of the client CAs
+	ClientAuthHosts []string // server names requiring client certificates, all by default
+	Profiles        map[string]*TLSProfile
+	HostProfiles    map[string]string // TLS profiles by server name, which can be wildcards
+	// DefaultCertificate is served when no certificate matches the server name,
+	// instead of the first certificate
+	DefaultCertificate *Certificate
+	StrictSNI          bool // reject the handshakes without a certificate matching their server name
+}
+
+// TLSProfile holds named TLS options, which apply to the server names of an
+// entry point mapped to it instead of the options of the entry point
+type TLSProfile struct {
+	MinVersion     string
+	CipherSuites   []string
+	ClientCAFiles  []string
+	ClientAuth     string
+	ClientCRLFiles []string
 }
 
 // Map of allowed TLS minimum versions
@@ -262,37 +293,31 @@ func (certs *Certificates) CreateTLSConfig() (*tls.Config, error) {
 	config.Certificates = []tls.Certificate{}
 	certsSlice := []Certificate(*certs)
 	for _, v := range certsSlice {
-		isAPath := false
-		_, errCert := os.Stat(v.CertFile)
-		_, errKey := os.Stat(v.KeyFile)
-		if errCert == nil {
-			if errKey == nil {
-				isAPath = true
-			} else {
-				return nil, fmt.Errorf("bad TLS Certificate KeyFile format, expected a path")
-			}
-		} else if errKey == nil {
-			return nil, fmt.Errorf("bad TLS Certificate KeyFile format, expected a path")
-		}
-
-		cert := tls.Certificate{}
-		var err error
-		if isAPath {
-			cert, err = tls.LoadX509KeyPair(v.CertFile, v.KeyFile)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			cert, err = tls.X509KeyPair([]byte(v.CertFile), []byte(v.KeyFile))
-			if err != nil {
-				return nil, err
-			}
+		cert, err := loadCertificate(v.CertFile, v.KeyFile)
+		if err != nil {
+			return nil, err
 		}
 		config.Certificates = append(config.Certificates, cert)
 	}
 	return config, nil
 }
 
+// loadCertificate loads a cert/key pair, which could be either file paths, or
+// the file contents themselves
+func loadCertificate(certFile, keyFile string) (tls.Certificate, error) {
+	_, errCert := os.Stat(certFile)
+	_, errKey := os.Stat(keyFile)
+	if errCert == nil {
+		if errKey == nil {
+			return tls.LoadX509KeyPair(certFile, keyFile)
+		}
+		return tls.Certificate{}, fmt.Errorf("bad TLS Certificate KeyFile format, expected a path")
+	} else if errKey == nil {
+		return tls.Certificate{}, fmt.Errorf("bad TLS Certificate KeyFile format, expected a path")
+	}
+	return tls.X509KeyPair([]byte(certFile), []byte(keyFile))
+}
+
 // String is the method to format the flag's value, part of the flag.Value interface.
 // The String method's output will be used in diagnostics.
 func (certs *Certificates) String() string {
@@ -341,6 +366,12 @@ type Retry struct {
 	Attempts int `description:"Number of attempts"`
 }
 
+// OCSPStapling contains the OCSP stapling configuration
+type OCSPStapling struct {
+	CacheDir   string `description:"Directory caching the OCSP responses across restarts"`
+	MustStaple bool   `description:"Request the ACME certificates with the OCSP must staple extension"`
+}
+
 // HealthCheckConfig contains health check configuration parameters.
 type HealthCheckConfig struct {
 	Interval flaeg.Duration `description:"Default periodicity of enabled health checks"`
@@ -0,0 +1,317 @@
+package server
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+)
+
+// Kinds of route conflicts
+const (
+	conflictDuplicateName  = "duplicateName"
+	conflictIdenticalRules = "identicalRules"
+	conflictShadowed       = "shadowed"
+)
+
+// frontendRef identifies a frontend of a provider
+type frontendRef struct {
+	Provider string `json:"provider"`
+	Frontend string `json:"frontend"`
+}
+
+// routeName returns the name of the routes of the frontend in the entrypoint
+// routers, unique across the providers
+func (ref frontendRef) routeName() string {
+	return ref.Provider + "/" + ref.Frontend
+}
+
+// routeConflict describes a frontend which can't be routed to as its provider intended
+type routeConflict struct {
+	Kind          string      `json:"kind"`
+	EntryPoint    string      `json:"entryPoint,omitempty"`
+	Frontend      frontendRef `json:"frontend"`
+	ConflictsWith frontendRef `json:"conflictsWith"`
+	Rejected      bool        `json:"rejected"`
+}
+
+// diagnostics holds the result of the analysis of the last loaded configuration
+type diagnostics struct {
+	Conflicts []*routeConflict `json:"conflicts"`
+}
+
+// rejectedFrontends holds the entrypoints on which the conflicting frontends
+// are rejected, the empty entrypoint name rejecting them on every entrypoint
+type rejectedFrontends map[frontendRef]map[string]bool
+
+func (r rejectedFrontends) reject(ref frontendRef, entryPointName string) {
+	if r[ref] == nil {
+		r[ref] = map[string]bool{}
+	}
+	r[ref][entryPointName] = true
+}
+
+// isRejected tells whether the frontend is rejected on the entrypoint
+func (r rejectedFrontends) isRejected(ref frontendRef, entryPointName string) bool {
+	return r[ref][""] || r[ref][entryPointName]
+}
+
+// isRejectedEverywhere tells whether the frontend is rejected on all of its
+// entrypoints
+func (r rejectedFrontends) isRejectedEverywhere(ref frontendRef, frontend *types.Frontend) bool {
+	if r[ref][""] {
+		return true
+	}
+	for _, entryPointName := range frontend.EntryPoints {
+		if !r[ref][entryPointName] {
+			return false
+		}
+	}
+	return len(frontend.EntryPoints) > 0
+}
+
+type analyzedFrontend struct {
+	frontendRef
+	frontend *types.Frontend
+	priority int
+	// rules is the sorted canonical form of the frontend routes
+	rules []string
+	// matchers are the matchers of the frontend, when all of its rules are
+	// conjunctions of matchers
+	matchers []*ruleNode
+	valid    bool
+}
+
+// analyzeRouteConflicts finds the frontends which are shadowed by, or
+// conflicting with, other frontends, and returns them along with the frontends
+// to reject if rejection is enabled. The frontends are only rejected on the
+// entrypoints where they conflict, except for the duplicate names which
+// conflict on every entrypoint.
+func analyzeRouteConflicts(configurations configs, globalConfiguration GlobalConfiguration) ([]*routeConflict, rejectedFrontends) {
+	var providerNames []string
+	for providerName := range configurations {
+		providerNames = append(providerNames, providerName)
+	}
+	sort.Strings(providerNames)
+
+	conflicts := []*routeConflict{}
+	names := map[string]frontendRef{}
+	entryPointFrontends := map[string][]*analyzedFrontend{}
+	for _, providerName := range providerNames {
+		configuration := configurations[providerName]
+		for _, frontendName := range sortedFrontendNamesForConfig(configuration) {
+			analyzed := analyzeFrontend(providerName, frontendName, configuration.Frontends[frontendName])
+			if first, ok := names[frontendName]; ok {
+				conflicts = append(conflicts, &routeConflict{Kind: conflictDuplicateName, Frontend: analyzed.frontendRef, ConflictsWith: first})
+			} else {
+				names[frontendName] = analyzed.frontendRef
+			}
+			if !analyzed.valid {
+				continue
+			}
+			for _, entryPointName := range analyzed.frontend.EntryPoints {
+				entryPointFrontends[entryPointName] = append(entryPointFrontends[entryPointName], analyzed)
+			}
+		}
+	}
+
+	var entryPointNames []string
+	for entryPointName := range entryPointFrontends {
+		entryPointNames = append(entryPointNames, entryPointName)
+	}
+	sort.Strings(entryPointNames)
+
+	for _, entryPointName := range entryPointNames {
+		frontends := entryPointFrontends[entryPointName]
+		for i, a := range frontends {
+			for _, b := range frontends[i+1:] {
+				conflict := &routeConflict{EntryPoint: entryPointName}
+				switch {
+				case a.priority == b.priority && strings.Join(a.rules, "\n") == strings.Join(b.rules, "\n"):
+					conflict.Kind, conflict.Frontend, conflict.ConflictsWith = conflictIdenticalRules, b.frontendRef, a.frontendRef
+				case a.priority > b.priority && a.covers(b):
+					conflict.Kind, conflict.Frontend, conflict.ConflictsWith = conflictShadowed, b.frontendRef, a.frontendRef
+				case b.priority > a.priority && b.covers(a):
+					conflict.Kind, conflict.Frontend, conflict.ConflictsWith = conflictShadowed, a.frontendRef, b.frontendRef
+				default:
+					continue
+				}
+				conflicts = append(conflicts, conflict)
+			}
+		}
+	}
+
+	rejected := rejectedFrontends{}
+	for _, conflict := range conflicts {
+		conflict.Rejected = globalConfiguration.RejectRouteConflicts
+		if conflict.Rejected {
+			rejected.reject(conflict.Frontend, conflict.EntryPoint)
+		}
+		logRouteConflict(conflict)
+	}
+	return conflicts, rejected
+}
+
+func logRouteConflict(conflict *routeConflict) {
+	action := "routing is undetermined"
+	if conflict.Rejected {
+		action = "rejecting it"
+	}
+	switch conflict.Kind {
+	case conflictDuplicateName:
+		log.Warnf("Frontend %s from provider %s has the same name as the one from provider %s, %s", conflict.Frontend.Frontend, conflict.Frontend.Provider, conflict.ConflictsWith.Provider, action)
+	case conflictIdenticalRules:
+		log.Warnf("Frontend %s from provider %s has the same rules and priority as frontend %s from provider %s on entrypoint %s, %s", conflict.Frontend.Frontend, conflict.Frontend.Provider, conflict.ConflictsWith.Frontend, conflict.ConflictsWith.Provider, conflict.EntryPoint, action)
+	case conflictShadowed:
+		if !conflict.Rejected {
+			action = "it is never used"
+		}
+		log.Warnf("Frontend %s from provider %s is shadowed by frontend %s from provider %s with a higher priority on entrypoint %s, %s", conflict.Frontend.Frontend, conflict.Frontend.Provider, conflict.ConflictsWith.Frontend, conflict.ConflictsWith.Provider, conflict.EntryPoint, action)
+	}
+}
+
+func analyzeFrontend(providerName string, frontendName string, frontend *types.Frontend) *analyzedFrontend {
+	analyzed := &analyzedFrontend{
+		frontendRef: frontendRef{Provider: providerName, Frontend: frontendName},
+		frontend:    frontend,
+		priority:    frontend.Priority,
+		matchers:    []*ruleNode{},
+		valid:       true,
+	}
+	conjunctive := true
+	rules := &Rules{}
+	for _, route := range frontend.Routes {
+		if frontend.Priority <= 0 {
+			analyzed.priority += len(route.Rule)
+		}
+		node, err := rules.parseRules(route.Rule)
+		if err != nil {
+			// reported when the frontend is loaded
+			analyzed.valid = false
+			return analyzed
+		}
+		analyzed.rules = append(analyzed.rules, node.String())
+		node.walk(false, func(matcher *ruleNode, negated bool) error {
+			if _, ok := matcherFamilies[matcher.name]; ok || !ruleModifiers[matcher.name] {
+				analyzed.matchers = append(analyzed.matchers, matcher)
+			}
+			return nil
+		})
+		conjunctive = conjunctive && isConjunction(node)
+	}
+	sort.Strings(analyzed.rules)
+	if !conjunctive {
+		analyzed.matchers = nil
+	}
+	return analyzed
+}
+
+func isConjunction(node *ruleNode) bool {
+	switch node.kind {
+	case ruleNodeMatcher:
+		return true
+	case ruleNodeAnd:
+		for _, child := range node.children {
+			if !isConjunction(child) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// covers tells whether every request matched by the other frontend is also
+// matched by this one. The analysis is conservative: it only handles
+// conjunctions of matchers, and may miss some covered frontends.
+func (a *analyzedFrontend) covers(b *analyzedFrontend) bool {
+	if a.matchers == nil || b.matchers == nil {
+		return false
+	}
+	for _, m := range a.matchers {
+		implied := false
+		for _, n := range b.matchers {
+			if matcherImplies(n, m) {
+				implied = true
+				break
+			}
+		}
+		if !implied {
+			return false
+		}
+	}
+	return true
+}
+
+// matcherFamilies maps the path matchers which also modify the request to
+// the matcher they use.
+var matcherFamilies = map[string]string{
+	"PathStrip":            "Path",
+	"PathStripRegex":       "Path",
+	"PathPrefixStrip":      "PathPrefix",
+	"PathPrefixStripRegex": "PathPrefix",
+}
+
+func matcherFamily(name string) string {
+	if family, ok := matcherFamilies[name]; ok {
+		return family
+	}
+	return name
+}
+
+// matcherImplies tells whether every request matched by n is matched by m.
+func matcherImplies(n *ruleNode, m *ruleNode) bool {
+	nFamily, mFamily := matcherFamily(n.name), matcherFamily(m.name)
+	if mFamily == "PathPrefix" && (nFamily == "Path" || nFamily == "PathPrefix") {
+		for _, path := range n.args {
+			if !hasLiteralPrefix(path, m.args) {
+				return false
+			}
+		}
+		return true
+	}
+	if nFamily != mFamily {
+		return false
+	}
+	switch nFamily {
+	case "Headers", "HeadersRegexp", "ClientIP", "QueryRegexp":
+		// arguments are not simple alternatives
+		return strings.Join(n.args, ",") == strings.Join(m.args, ",")
+	}
+	for _, arg := range n.args {
+		found := false
+		for _, other := range m.args {
+			if normalizeMatcherArgument(nFamily, arg) == normalizeMatcherArgument(nFamily, other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func hasLiteralPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if !strings.Contains(prefix, "{") && !strings.Contains(path, "{") && strings.HasPrefix(path, prefix) {
+			return true
+		}
+		if prefix == path {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeMatcherArgument(family string, arg string) string {
+	switch family {
+	case "Host", "HostRegexp":
+		return types.CanonicalDomain(arg)
+	case "Method":
+		return strings.ToUpper(arg)
+	}
+	return arg
+}
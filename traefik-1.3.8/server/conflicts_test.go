@@ -0,0 +1,176 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func frontendWithRule(rule string, priority int) *types.Frontend {
+	return &types.Frontend{
+		EntryPoints: []string{"http"},
+		Backend:     "backend",
+		Priority:    priority,
+		Routes: map[string]types.Route{
+			"route": {Rule: rule},
+		},
+	}
+}
+
+func TestAnalyzeRouteConflicts(t *testing.T) {
+	cases := []struct {
+		desc      string
+		configs   configs
+		conflicts []*routeConflict
+	}{
+		{
+			desc: "no conflict",
+			configs: configs{
+				"file": &types.Configuration{Frontends: map[string]*types.Frontend{
+					"a": frontendWithRule("Host:a.com", 0),
+					"b": frontendWithRule("Host:b.com", 0),
+				}},
+			},
+			conflicts: []*routeConflict{},
+		},
+		{
+			desc: "duplicate names",
+			configs: configs{
+				"file":   &types.Configuration{Frontends: map[string]*types.Frontend{"a": frontendWithRule("Host:a.com", 0)}},
+				"docker": &types.Configuration{Frontends: map[string]*types.Frontend{"a": frontendWithRule("Host:b.com", 0)}},
+			},
+			conflicts: []*routeConflict{
+				{Kind: conflictDuplicateName, Frontend: frontendRef{"file", "a"}, ConflictsWith: frontendRef{Provider: "docker", Frontend: "a"}},
+			},
+		},
+		{
+			desc: "identical rules",
+			configs: configs{
+				"file":   &types.Configuration{Frontends: map[string]*types.Frontend{"a": frontendWithRule("Host:a.com;Path:/foo", 0)}},
+				"docker": &types.Configuration{Frontends: map[string]*types.Frontend{"b": frontendWithRule("Host: a.com AND Path:/foo", 20)}},
+			},
+			conflicts: []*routeConflict{
+				{Kind: conflictIdenticalRules, EntryPoint: "http", Frontend: frontendRef{"file", "a"}, ConflictsWith: frontendRef{"docker", "b"}},
+			},
+		},
+		{
+			desc: "shadowed rules",
+			configs: configs{
+				"file": &types.Configuration{Frontends: map[string]*types.Frontend{
+					"api":     frontendWithRule("Host:a.com,b.com;PathPrefix:/api", 100),
+					"users":   frontendWithRule("Host:a.com;PathPrefixStrip:/api/users", 0),
+					"regex":   frontendWithRule("Host:a.com;PathPrefix:/api/{id:[0-9]+}", 0),
+					"other":   frontendWithRule("Host:a.com;PathPrefix:/web", 0),
+					"alterns": frontendWithRule("Host:a.com OR PathPrefix:/api", 0),
+				}},
+			},
+			conflicts: []*routeConflict{
+				{Kind: conflictShadowed, EntryPoint: "http", Frontend: frontendRef{"file", "users"}, ConflictsWith: frontendRef{"file", "api"}},
+			},
+		},
+	}
+
+	for _, test := range cases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			conflicts, rejected := analyzeRouteConflicts(test.configs, GlobalConfiguration{})
+			assert.Equal(t, test.conflicts, conflicts)
+			assert.Empty(t, rejected)
+		})
+	}
+}
+
+func TestServerLoadConfigRejectRouteConflicts(t *testing.T) {
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+		RejectRouteConflicts: true,
+	}
+	backends := map[string]*types.Backend{
+		"backend": {
+			Servers: map[string]types.Server{
+				"server": {
+					URL: "http://localhost",
+				},
+			},
+			LoadBalancer: &types.LoadBalancer{
+				Method: "Wrr",
+			},
+		},
+	}
+	dynamicConfigs := configs{
+		"docker": &types.Configuration{
+			Frontends: map[string]*types.Frontend{"a": frontendWithRule("Host:a.com", 0)},
+			Backends:  backends,
+		},
+		"file": &types.Configuration{
+			Frontends: map[string]*types.Frontend{"b": frontendWithRule("Host:a.com", 0)},
+			Backends:  backends,
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	serverEntryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	router := serverEntryPoints["http"].httpRouter.GetHandler()
+	assert.NotNil(t, router.Get(frontendRef{Provider: "docker", Frontend: "a"}.routeName()))
+	assert.Nil(t, router.Get(frontendRef{Provider: "file", Frontend: "b"}.routeName()))
+
+	conflicts := srv.diagnostics.Get().(*diagnostics).Conflicts
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, frontendRef{"file", "b"}, conflicts[0].Frontend)
+	assert.True(t, conflicts[0].Rejected)
+}
+
+func TestServerLoadConfigRejectRouteConflictsPerEntryPoint(t *testing.T) {
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http":  &EntryPoint{},
+			"https": &EntryPoint{},
+		},
+		RejectRouteConflicts: true,
+	}
+	backends := map[string]*types.Backend{
+		"backend": {
+			Servers: map[string]types.Server{
+				"server": {
+					URL: "http://localhost",
+				},
+			},
+			LoadBalancer: &types.LoadBalancer{
+				Method: "Wrr",
+			},
+		},
+	}
+	shared := frontendWithRule("Host:a.com", 0)
+	shared.EntryPoints = []string{"http", "https"}
+	dynamicConfigs := configs{
+		"docker": &types.Configuration{
+			Frontends: map[string]*types.Frontend{"a": frontendWithRule("Host:a.com", 0)},
+			Backends:  backends,
+		},
+		"file": &types.Configuration{
+			Frontends: map[string]*types.Frontend{"b": shared},
+			Backends:  backends,
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	serverEntryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	// b only conflicts with a on http
+	assert.NotNil(t, serverEntryPoints["http"].httpRouter.GetHandler().Get(frontendRef{Provider: "docker", Frontend: "a"}.routeName()))
+	assert.Nil(t, serverEntryPoints["http"].httpRouter.GetHandler().Get(frontendRef{Provider: "file", Frontend: "b"}.routeName()))
+	assert.NotNil(t, serverEntryPoints["https"].httpRouter.GetHandler().Get(frontendRef{Provider: "file", Frontend: "b"}.routeName()))
+
+	conflicts := srv.diagnostics.Get().(*diagnostics).Conflicts
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "http", conflicts[0].EntryPoint)
+	assert.True(t, conflicts[0].Rejected)
+}
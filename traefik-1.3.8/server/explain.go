@@ -0,0 +1,236 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+
+	"github.com/containous/mux"
+	"github.com/containous/traefik/types"
+	"github.com/containous/traefik/whitelist"
+)
+
+// matchReport explains how a request is routed on an entrypoint
+type matchReport struct {
+	EntryPoint string                 `json:"entryPoint"`
+	Frontends  []*frontendMatchReport `json:"frontends"`
+	Frontend   string                 `json:"frontend,omitempty"`
+	Provider   string                 `json:"provider,omitempty"`
+	Backend    string                 `json:"backend,omitempty"`
+	Split      []*splitMatchReport    `json:"split,omitempty"`
+	URL        string                 `json:"url,omitempty"`
+}
+
+// frontendMatchReport explains how a request is matched by a frontend
+type frontendMatchReport struct {
+	Provider string              `json:"provider"`
+	Frontend string              `json:"frontend"`
+	Backend  string              `json:"backend,omitempty"`
+	Split    []*splitMatchReport `json:"split,omitempty"`
+	Priority int                 `json:"priority"`
+	Active   bool                `json:"active"`
+	Matched  bool                `json:"matched"`
+	Matchers []*matcherReport    `json:"matchers,omitempty"`
+	Error    string              `json:"error,omitempty"`
+
+	serverRoute *serverRoute
+}
+
+// splitMatchReport is a backend of a split frontend, with its current weight
+type splitMatchReport struct {
+	Name    string `json:"name"`
+	Backend string `json:"backend"`
+	Weight  int    `json:"weight"`
+}
+
+// matcherReport tells whether a single matcher of a rule matches a request
+type matcherReport struct {
+	Rule    string `json:"rule"`
+	Negated bool   `json:"negated,omitempty"`
+	Matched bool   `json:"matched"`
+}
+
+// explainMatch evaluates every frontend of the entrypoint against the request,
+// and returns the frontend which handles it along with the URL its backend receives.
+func (server *Server) explainMatch(entryPointName string, req *http.Request) (*matchReport, error) {
+	serverEntryPoint, ok := server.serverEntryPoints[entryPointName]
+	if !ok {
+		return nil, errors.New("Unknown entrypoint " + entryPointName)
+	}
+	trustedIPs, err := entryPointTrustedIPs(server.globalConfiguration.EntryPoints[entryPointName])
+	if err != nil {
+		return nil, err
+	}
+	router := serverEntryPoint.httpRouter.GetHandler()
+	currentConfigurations := server.currentConfigurations.Get().(configs)
+
+	var providerNames []string
+	for providerName := range currentConfigurations {
+		providerNames = append(providerNames, providerName)
+	}
+	sort.Strings(providerNames)
+
+	// the router serves the request with the first of its routes, sorted by
+	// priority, which matches it
+	winnerMatch := &mux.RouteMatch{}
+	router.Match(req, winnerMatch)
+
+	report := &matchReport{EntryPoint: entryPointName, Frontends: []*frontendMatchReport{}}
+	var winner *frontendMatchReport
+	for _, providerName := range providerNames {
+		configuration := currentConfigurations[providerName]
+		for _, frontendName := range sortedFrontendNamesForConfig(configuration) {
+			frontend := configuration.Frontends[frontendName]
+			if !containsString(frontend.EntryPoints, entryPointName) {
+				continue
+			}
+			frontendReport := explainFrontendMatch(frontend, req, trustedIPs)
+			frontendReport.Provider = providerName
+			frontendReport.Frontend = frontendName
+			route := router.Get(frontendRef{Provider: providerName, Frontend: frontendName}.routeName())
+			frontendReport.Active = route != nil
+			frontendReport.Split = server.explainSplit(providerName, frontendName, frontend)
+			report.Frontends = append(report.Frontends, frontendReport)
+
+			if winner == nil && route != nil && winnerMatch.Route != nil && matchedRoute(route, req) == winnerMatch.Route {
+				winner = frontendReport
+			}
+		}
+	}
+
+	if winner != nil {
+		report.Frontend = winner.Frontend
+		report.Provider = winner.Provider
+		report.Backend = winner.Backend
+		report.Split = winner.Split
+		report.URL = server.forwardedURL(winner.serverRoute, req)
+	}
+	return report, nil
+}
+
+// matchedRoute returns the route reported by the router when the request is
+// matched by the given route: the route itself, or the one of its subrouters
+// which matched.
+func matchedRoute(route *mux.Route, req *http.Request) *mux.Route {
+	match := &mux.RouteMatch{}
+	if !route.Match(req, match) {
+		return nil
+	}
+	return match.Route
+}
+
+// explainFrontendMatch rebuilds the routes of the frontend outside of any
+// router, and evaluates them and each of their matchers against the request.
+func explainFrontendMatch(frontend *types.Frontend, req *http.Request, trustedIPs *whitelist.IP) *frontendMatchReport {
+	report := &frontendMatchReport{
+		Matchers:    []*matcherReport{},
+		serverRoute: &serverRoute{route: newDetachedRoute()},
+	}
+	if frontend.Split == nil || len(frontend.Split.Backends) == 0 {
+		report.Backend = frontend.Backend
+	}
+
+	var routeNames []string
+	for routeName := range frontend.Routes {
+		routeNames = append(routeNames, routeName)
+	}
+	sort.Strings(routeNames)
+
+	for _, routeName := range routeNames {
+		route := frontend.Routes[routeName]
+		if err := getRoute(report.serverRoute, &route, trustedIPs); err != nil {
+			report.Error = err.Error()
+			return report
+		}
+
+		rules := &Rules{}
+		node, err := rules.parseRules(route.Rule)
+		if err != nil {
+			report.Error = err.Error()
+			return report
+		}
+		node.walk(false, func(matcher *ruleNode, negated bool) error {
+			matcherRules := &Rules{route: &serverRoute{route: newDetachedRoute()}, trustedIPs: trustedIPs}
+			matched := false
+			if err := matcherRules.callFunction(matcherRules.route.route, matcher.name, matcher.args); err == nil {
+				matched = matcherRules.route.route.Match(req, &mux.RouteMatch{})
+			}
+			report.Matchers = append(report.Matchers, &matcherReport{Rule: matcher.String(), Negated: negated, Matched: matched})
+			return nil
+		})
+	}
+
+	report.Priority = report.serverRoute.route.GetPriority()
+	if frontend.Priority > 0 {
+		report.Priority = frontend.Priority
+	}
+	report.Matched = report.serverRoute.route.Match(req, &mux.RouteMatch{})
+	return report
+}
+
+// explainSplit returns the backends of the split of the frontend, if it has
+// one, with the weights currently applied.
+func (server *Server) explainSplit(providerName string, frontendName string, frontend *types.Frontend) []*splitMatchReport {
+	if frontend.Split == nil || len(frontend.Split.Backends) == 0 {
+		return nil
+	}
+	var weights map[string]int
+	if split := server.frontendSplit(providerName, frontendName); split != nil {
+		weights = split.Weights()
+	}
+
+	var names []string
+	for name := range frontend.Split.Backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var reports []*splitMatchReport
+	for _, name := range names {
+		weightedBackend := frontend.Split.Backends[name]
+		weight, ok := weights[name]
+		if !ok {
+			weight = weightedBackend.Weight
+		}
+		reports = append(reports, &splitMatchReport{Name: name, Backend: weightedBackend.Backend, Weight: weight})
+	}
+	return reports
+}
+
+// forwardedURL returns the URL of the request once the modifiers of the route have been applied.
+func (server *Server) forwardedURL(serverRoute *serverRoute, req *http.Request) string {
+	var forwarded string
+	server.wireFrontendBackend(serverRoute, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.URL.String()
+	}))
+
+	// modifiers rewrite the request in place
+	clone := new(http.Request)
+	*clone = *req
+	u := *req.URL
+	clone.URL = &u
+	clone.Header = make(http.Header)
+	for name, values := range req.Header {
+		clone.Header[name] = values
+	}
+	serverRoute.route.GetHandler().ServeHTTP(httptest.NewRecorder(), clone)
+	return forwarded
+}
+
+// entryPointTrustedIPs returns the addresses allowed to forward the client address to the entrypoint.
+func entryPointTrustedIPs(entryPoint *EntryPoint) (*whitelist.IP, error) {
+	if entryPoint == nil || entryPoint.ForwardedHeaders == nil || len(entryPoint.ForwardedHeaders.TrustedIPs) == 0 {
+		return nil, nil
+	}
+	return whitelist.NewIP(entryPoint.ForwardedHeaders.TrustedIPs)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerExplainMatch(t *testing.T) {
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+	backend := &types.Backend{
+		Servers: map[string]types.Server{
+			"server": {
+				URL: "http://localhost",
+			},
+		},
+		LoadBalancer: &types.LoadBalancer{
+			Method: "Wrr",
+		},
+	}
+	dynamicConfigs := configs{
+		"file": &types.Configuration{
+			Frontends: map[string]*types.Frontend{
+				"api": {
+					EntryPoints: []string{"http"},
+					Backend:     "api",
+					Routes: map[string]types.Route{
+						"host": {Rule: "Host:foo.bar"},
+						"path": {Rule: "PathPrefixStrip:/api;AddPrefix:/v2"},
+					},
+				},
+				"web": {
+					EntryPoints: []string{"http"},
+					Backend:     "web",
+					Routes: map[string]types.Route{
+						"host": {Rule: "Host:foo.bar AND NOT Method:DELETE"},
+					},
+				},
+				"other": {
+					EntryPoints: []string{"https"},
+					Backend:     "web",
+					Routes: map[string]types.Route{
+						"host": {Rule: "Host:foo.bar"},
+					},
+				},
+			},
+			Backends: map[string]*types.Backend{
+				"api": backend,
+				"web": backend,
+			},
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	serverEntryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+	srv.serverEntryPoints = serverEntryPoints
+	srv.currentConfigurations.Set(dynamicConfigs)
+
+	req := testhelpers.MustNewRequest(http.MethodGet, "/api/users?id=1", nil)
+	req.Host = "foo.bar"
+	report, err := srv.explainMatch("http", req)
+	require.NoError(t, err)
+
+	assert.Equal(t, "api", report.Frontend)
+	assert.Equal(t, "file", report.Provider)
+	assert.Equal(t, "api", report.Backend)
+	assert.Equal(t, "/v2/users?id=1", report.URL)
+	require.Len(t, report.Frontends, 2)
+
+	api := report.Frontends[0]
+	assert.Equal(t, "api", api.Frontend)
+	assert.True(t, api.Active)
+	assert.True(t, api.Matched)
+	assert.Equal(t, len("Host:foo.bar")+len("PathPrefixStrip:/api;AddPrefix:/v2"), api.Priority)
+	assert.Len(t, api.Matchers, 3)
+
+	web := report.Frontends[1]
+	assert.Equal(t, "web", web.Frontend)
+	assert.True(t, web.Matched)
+	assert.Equal(t, []*matcherReport{
+		{Rule: "Host:foo.bar", Matched: true},
+		{Rule: "Method:DELETE", Negated: true, Matched: false},
+	}, web.Matchers)
+
+	req = testhelpers.MustNewRequest(http.MethodDelete, "/", nil)
+	req.Host = "foo.bar"
+	report, err = srv.explainMatch("http", req)
+	require.NoError(t, err)
+	assert.Empty(t, report.Frontend)
+	assert.Empty(t, report.URL)
+
+	_, err = srv.explainMatch("https", req)
+	assert.Error(t, err)
+}
+
+func TestServerExplainMatchRouterOrder(t *testing.T) {
+	newBackendServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+	}
+	backendServer1 := newBackendServer("backend-1")
+	defer backendServer1.Close()
+	backendServer2 := newBackendServer("backend-2")
+	defer backendServer2.Close()
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+	dynamicConfigs := configs{
+		"file": &types.Configuration{
+			Frontends: map[string]*types.Frontend{
+				"frontend-1": {
+					EntryPoints: []string{"http"},
+					Backend:     "backend-1",
+					Routes: map[string]types.Route{
+						"host": {Rule: "Host:foo.bar"},
+					},
+				},
+				"frontend-2": {
+					EntryPoints: []string{"http"},
+					Backend:     "backend-2",
+					Routes: map[string]types.Route{
+						"host": {Rule: "Host:foo.bar"},
+					},
+				},
+				"split": {
+					EntryPoints: []string{"http"},
+					Routes: map[string]types.Route{
+						"host": {Rule: "Host:split.bar"},
+					},
+					Split: &types.Split{
+						Backends: map[string]*types.WeightedBackend{
+							"stable": {Backend: "backend-1", Weight: 9},
+							"canary": {Backend: "backend-2", Weight: 1},
+						},
+					},
+				},
+			},
+			Backends: map[string]*types.Backend{
+				"backend-1": {
+					Servers: map[string]types.Server{
+						"server": {URL: backendServer1.URL},
+					},
+					LoadBalancer: &types.LoadBalancer{Method: "Wrr"},
+				},
+				"backend-2": {
+					Servers: map[string]types.Server{
+						"server": {URL: backendServer2.URL},
+					},
+					LoadBalancer: &types.LoadBalancer{Method: "Wrr"},
+				},
+			},
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	serverEntryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+	srv.serverEntryPoints = serverEntryPoints
+	srv.currentConfigurations.Set(dynamicConfigs)
+
+	// both frontends have the same priority, the one reported must be the one
+	// the router serves the request with
+	req := testhelpers.MustNewRequest(http.MethodGet, "http://foo.bar/", nil)
+	recorder := httptest.NewRecorder()
+	serverEntryPoints["http"].httpRouter.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	report, err := srv.explainMatch("http", req)
+	require.NoError(t, err)
+	assert.Equal(t, recorder.Body.String(), report.Backend)
+	assert.Equal(t, "frontend"+strings.TrimPrefix(recorder.Body.String(), "backend"), report.Frontend)
+
+	require.NoError(t, srv.frontendSplit("file", "split").SetWeights(map[string]int{"stable": 1, "canary": 3}))
+	req = testhelpers.MustNewRequest(http.MethodGet, "http://split.bar/", nil)
+	report, err = srv.explainMatch("http", req)
+	require.NoError(t, err)
+	assert.Equal(t, "split", report.Frontend)
+	assert.Empty(t, report.Backend)
+	assert.Equal(t, []*splitMatchReport{
+		{Name: "canary", Backend: "backend-2", Weight: 3},
+		{Name: "stable", Backend: "backend-1", Weight: 1},
+	}, report.Split)
+}
+
+func TestServerExplainMatchSameFrontendName(t *testing.T) {
+	newBackendServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+	}
+	dockerServer := newBackendServer("docker-backend")
+	defer dockerServer.Close()
+	fileServer := newBackendServer("file-backend")
+	defer fileServer.Close()
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+	newConfiguration := func(host string, backendName string, url string) *types.Configuration {
+		return &types.Configuration{
+			Frontends: map[string]*types.Frontend{
+				"frontend": {
+					EntryPoints: []string{"http"},
+					Backend:     backendName,
+					Routes: map[string]types.Route{
+						"host": {Rule: "Host:" + host},
+					},
+				},
+			},
+			Backends: map[string]*types.Backend{
+				backendName: {
+					Servers: map[string]types.Server{
+						"server": {URL: url},
+					},
+					LoadBalancer: &types.LoadBalancer{Method: "Wrr"},
+				},
+			},
+		}
+	}
+	dynamicConfigs := configs{
+		"docker": newConfiguration("docker.bar", "docker-backend", dockerServer.URL),
+		"file":   newConfiguration("file.bar", "file-backend", fileServer.URL),
+	}
+
+	srv := NewServer(globalConfig)
+	serverEntryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+	srv.serverEntryPoints = serverEntryPoints
+	srv.currentConfigurations.Set(dynamicConfigs)
+
+	for _, providerName := range []string{"docker", "file"} {
+		req := testhelpers.MustNewRequest(http.MethodGet, "http://"+providerName+".bar/", nil)
+		report, err := srv.explainMatch("http", req)
+		require.NoError(t, err)
+		assert.Equal(t, "frontend", report.Frontend)
+		assert.Equal(t, providerName, report.Provider)
+		assert.Equal(t, providerName+"-backend", report.Backend)
+		require.Len(t, report.Frontends, 2)
+		for _, frontendReport := range report.Frontends {
+			assert.True(t, frontendReport.Active)
+			assert.Equal(t, frontendReport.Provider == providerName, frontendReport.Matched)
+		}
+	}
+}
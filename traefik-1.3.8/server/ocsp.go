@@ -0,0 +1,415 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	kitmetrics "github.com/go-kit/kit/metrics"
+	"github.com/xenolf/lego/acme"
+	"golang.org/x/crypto/ocsp"
+)
+
+// Statuses of the OCSP staples
+const (
+	ocspStatusGood        = "good"
+	ocspStatusRevoked     = "revoked"
+	ocspStatusUnknown     = "unknown"
+	ocspStatusPending     = "pending"
+	ocspStatusError       = "error"
+	ocspStatusUnsupported = "unsupported"
+)
+
+var ocspStatuses = []string{ocspStatusGood, ocspStatusRevoked, ocspStatusUnknown, ocspStatusPending, ocspStatusError, ocspStatusUnsupported}
+
+const (
+	// ocspCheckInterval is the interval between the checks of the staples to refresh
+	ocspCheckInterval = time.Minute
+	// ocspRetryInterval is the delay before fetching again a failed OCSP response
+	ocspRetryInterval = 10 * time.Minute
+	// ocspDefaultValidity is the delay before refreshing the OCSP responses
+	// without next update
+	ocspDefaultValidity = time.Hour
+)
+
+// ocspStaple holds the OCSP response of a served certificate. It is replaced,
+// never modified, once shared.
+type ocspStaple struct {
+	certificate *tls.Certificate
+	leaf        *x509.Certificate
+	issuer      *x509.Certificate
+	raw         []byte
+	response    *ocsp.Response
+	status      string
+	err         error
+	nextRefresh time.Time
+}
+
+// valid returns whether the OCSP response can be stapled
+func (staple *ocspStaple) valid(now time.Time) bool {
+	return staple.response != nil && (staple.response.NextUpdate.IsZero() || now.Before(staple.response.NextUpdate))
+}
+
+// ocspStapleStatus describes the OCSP staple of a served certificate in the API
+type ocspStapleStatus struct {
+	Domains     []string   `json:"domains"`
+	Status      string     `json:"status"`
+	ThisUpdate  *time.Time `json:"thisUpdate,omitempty"`
+	NextUpdate  *time.Time `json:"nextUpdate,omitempty"`
+	NextRefresh *time.Time `json:"nextRefresh,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// ocspStapler fetches the OCSP responses of the served certificates in the
+// background, caches them on disk, and staples them to the handshakes
+type ocspStapler struct {
+	cacheDir string
+	fetch    func(bundle []byte) ([]byte, *ocsp.Response, error)
+	staples  map[string]*ocspStaple
+	lock     sync.RWMutex
+	refresh  chan struct{}
+	gauge    kitmetrics.Gauge
+	// served returns the certificates currently served, which are stapled
+	// before their first handshake and whose staples are dropped once they
+	// aren't served anymore
+	served func() []*tls.Certificate
+}
+
+func newOCSPStapler(config *OCSPStapling, gauge kitmetrics.Gauge) (*ocspStapler, error) {
+	if len(config.CacheDir) > 0 {
+		if err := os.MkdirAll(config.CacheDir, 0700); err != nil {
+			return nil, fmt.Errorf("invalid OCSP cache directory: %v", err)
+		}
+	}
+	return &ocspStapler{
+		cacheDir: config.CacheDir,
+		fetch:    acme.GetOCSPForCert,
+		staples:  map[string]*ocspStaple{},
+		refresh:  make(chan struct{}, 1),
+		gauge:    gauge,
+	}, nil
+}
+
+// getCertificate staples the OCSP response of the certificate returned by next
+func (s *ocspStapler) getCertificate(next func(*tls.ClientHelloInfo) (*tls.Certificate, error)) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		certificate, err := next(clientHello)
+		if certificate == nil || err != nil {
+			return certificate, err
+		}
+		return s.staple(certificate), nil
+	}
+}
+
+// staple returns a copy of the certificate with its OCSP response, or the
+// certificate itself while its OCSP response isn't available
+func (s *ocspStapler) staple(certificate *tls.Certificate) *tls.Certificate {
+	if len(certificate.Certificate) == 0 {
+		return certificate
+	}
+	key := ocspKey(certificate)
+	s.lock.RLock()
+	staple, ok := s.staples[key]
+	s.lock.RUnlock()
+	if !ok {
+		staple = s.add(key, certificate)
+		s.update()
+	}
+	if staple == nil || !staple.valid(time.Now()) {
+		return certificate
+	}
+	stapled := *certificate
+	stapled.OCSPStaple = staple.raw
+	return &stapled
+}
+
+// update asks for a refresh of the staples, after the served certificates
+// changed
+func (s *ocspStapler) update() {
+	select {
+	case s.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// register starts stapling the served certificates, and drops the staples of
+// the other ones
+func (s *ocspStapler) register(certificates []*tls.Certificate) {
+	served := map[string]bool{}
+	for _, certificate := range certificates {
+		if certificate == nil || len(certificate.Certificate) == 0 {
+			continue
+		}
+		key := ocspKey(certificate)
+		if served[key] {
+			continue
+		}
+		served[key] = true
+		s.lock.RLock()
+		_, ok := s.staples[key]
+		s.lock.RUnlock()
+		if !ok {
+			s.add(key, certificate)
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for key, staple := range s.staples {
+		if !served[key] {
+			log.Debugf("Dropping OCSP staple of %s, not served anymore", staple.leaf.DNSNames)
+			delete(s.staples, key)
+		}
+	}
+}
+
+// add starts stapling the certificate, with its cached OCSP response if valid
+func (s *ocspStapler) add(key string, certificate *tls.Certificate) *ocspStaple {
+	staple := &ocspStaple{certificate: certificate, status: ocspStatusPending, nextRefresh: time.Now()}
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		log.Errorf("Error parsing certificate for OCSP stapling: %v", err)
+		return nil
+	}
+	staple.leaf = leaf
+	if len(certificate.Certificate) > 1 {
+		if staple.issuer, err = x509.ParseCertificate(certificate.Certificate[1]); err != nil {
+			log.Errorf("Error parsing issuer of certificate %s for OCSP stapling: %v", leaf.DNSNames, err)
+		}
+	}
+	if len(leaf.OCSPServer) == 0 {
+		staple.status = ocspStatusUnsupported
+	} else if cached := s.loadCached(key, staple); cached != nil {
+		staple = cached
+	}
+
+	s.lock.Lock()
+	if existing, ok := s.staples[key]; ok {
+		staple = existing
+	} else {
+		s.staples[key] = staple
+	}
+	s.lock.Unlock()
+	return staple
+}
+
+// loadCached returns the staple with the OCSP response cached on disk, or nil
+func (s *ocspStapler) loadCached(key string, staple *ocspStaple) *ocspStaple {
+	if len(s.cacheDir) == 0 || staple.issuer == nil {
+		return nil
+	}
+	raw, err := ioutil.ReadFile(filepath.Join(s.cacheDir, key+".ocsp"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Error reading cached OCSP response for %s: %v", staple.leaf.DNSNames, err)
+		}
+		return nil
+	}
+	response, err := ocsp.ParseResponse(raw, staple.issuer)
+	if err == nil {
+		err = checkOCSPResponse(staple.leaf, response)
+	}
+	if err != nil {
+		log.Warnf("Ignoring cached OCSP response for %s: %v", staple.leaf.DNSNames, err)
+		return nil
+	}
+	cached := staple.withResponse(raw, response)
+	if !cached.valid(time.Now()) {
+		return nil
+	}
+	return cached
+}
+
+// withResponse returns a copy of the staple with the OCSP response, refreshed
+// half way to its next update
+func (staple *ocspStaple) withResponse(raw []byte, response *ocsp.Response) *ocspStaple {
+	updated := *staple
+	updated.raw = raw
+	updated.response = response
+	updated.err = nil
+	switch response.Status {
+	case ocsp.Good:
+		updated.status = ocspStatusGood
+	case ocsp.Revoked:
+		updated.status = ocspStatusRevoked
+	default:
+		updated.status = ocspStatusUnknown
+	}
+	updated.nextRefresh = time.Now().Add(ocspDefaultValidity)
+	if !response.NextUpdate.IsZero() {
+		updated.nextRefresh = response.ThisUpdate.Add(response.NextUpdate.Sub(response.ThisUpdate) / 2)
+		if minRefresh := time.Now().Add(ocspCheckInterval); updated.nextRefresh.Before(minRefresh) {
+			updated.nextRefresh = minRefresh
+		}
+	}
+	return &updated
+}
+
+// checkOCSPResponse checks that the OCSP response is a current response for the
+// certificate
+func checkOCSPResponse(leaf *x509.Certificate, response *ocsp.Response) error {
+	if response.Status == ocsp.ServerFailed {
+		return errors.New("OCSP responder failed")
+	}
+	if response.SerialNumber == nil || response.SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+		return errors.New("OCSP response for another certificate")
+	}
+	if !response.NextUpdate.IsZero() && time.Now().After(response.NextUpdate) {
+		return fmt.Errorf("OCSP response expired on %s", response.NextUpdate)
+	}
+	return nil
+}
+
+// run refreshes the OCSP responses until stopped
+func (s *ocspStapler) run(stop chan bool) {
+	ticker := time.NewTicker(ocspCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-s.refresh:
+		case <-ticker.C:
+		}
+		s.refreshStaples()
+	}
+}
+
+// refreshStaples registers the served certificates, fetches the OCSP responses
+// due for a refresh, and forgets the expired certificates
+func (s *ocspStapler) refreshStaples() {
+	if s.served != nil {
+		s.register(s.served())
+	}
+	now := time.Now()
+	var due []string
+	s.lock.Lock()
+	for key, staple := range s.staples {
+		if now.After(staple.leaf.NotAfter) {
+			delete(s.staples, key)
+			continue
+		}
+		if staple.status != ocspStatusUnsupported && !now.Before(staple.nextRefresh) {
+			due = append(due, key)
+		}
+	}
+	s.lock.Unlock()
+
+	for _, key := range due {
+		s.lock.RLock()
+		staple, ok := s.staples[key]
+		s.lock.RUnlock()
+		if !ok {
+			continue
+		}
+		refreshed := s.fetchStaple(key, staple)
+		s.lock.Lock()
+		// unless dropped meanwhile
+		if _, ok := s.staples[key]; ok {
+			s.staples[key] = refreshed
+		}
+		s.lock.Unlock()
+	}
+	s.updateGauge()
+}
+
+// fetchStaple returns the staple with a new OCSP response, or with the error
+// while the previous response is still valid
+func (s *ocspStapler) fetchStaple(key string, staple *ocspStaple) *ocspStaple {
+	var bundle []byte
+	for _, der := range staple.certificate.Certificate {
+		bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	raw, response, err := s.fetch(bundle)
+	if err == nil {
+		err = checkOCSPResponse(staple.leaf, response)
+	}
+	if err != nil {
+		log.Warnf("Error fetching OCSP response for %s: %v", staple.leaf.DNSNames, err)
+		failed := *staple
+		failed.err = err
+		failed.nextRefresh = time.Now().Add(ocspRetryInterval)
+		if !failed.valid(time.Now()) {
+			failed.raw = nil
+			failed.response = nil
+			failed.status = ocspStatusError
+		}
+		return &failed
+	}
+
+	log.Debugf("Fetched OCSP response for %s, next update on %s", staple.leaf.DNSNames, response.NextUpdate)
+	if len(s.cacheDir) > 0 {
+		if err := ioutil.WriteFile(filepath.Join(s.cacheDir, key+".ocsp"), raw, 0600); err != nil {
+			log.Warnf("Error caching OCSP response for %s: %v", staple.leaf.DNSNames, err)
+		}
+	}
+	return staple.withResponse(raw, response)
+}
+
+func (s *ocspStapler) updateGauge() {
+	if s.gauge == nil {
+		return
+	}
+	counts := map[string]int{}
+	s.lock.RLock()
+	for _, staple := range s.staples {
+		counts[staple.status]++
+	}
+	s.lock.RUnlock()
+	for _, status := range ocspStatuses {
+		s.gauge.With("status", status).Set(float64(counts[status]))
+	}
+}
+
+// statuses returns the status of the OCSP staples, sorted by domains
+func (s *ocspStapler) statuses() []*ocspStapleStatus {
+	statuses := []*ocspStapleStatus{}
+	if s == nil {
+		return statuses
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, staple := range s.staples {
+		status := &ocspStapleStatus{Domains: staple.leaf.DNSNames, Status: staple.status}
+		if len(status.Domains) == 0 {
+			status.Domains = []string{staple.leaf.Subject.CommonName}
+		}
+		if staple.response != nil {
+			thisUpdate, nextUpdate := staple.response.ThisUpdate, staple.response.NextUpdate
+			status.ThisUpdate = &thisUpdate
+			if !nextUpdate.IsZero() {
+				status.NextUpdate = &nextUpdate
+			}
+		}
+		if staple.status != ocspStatusUnsupported {
+			nextRefresh := staple.nextRefresh
+			status.NextRefresh = &nextRefresh
+		}
+		if staple.err != nil {
+			status.Error = staple.err.Error()
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return strings.Join(statuses[i].Domains, ",") < strings.Join(statuses[j].Domains, ",")
+	})
+	return statuses
+}
+
+// ocspKey identifies the certificate by the SHA-256 fingerprint of its leaf
+func ocspKey(certificate *tls.Certificate) string {
+	fingerprint := sha256.Sum256(certificate.Certificate[0])
+	return hex.EncodeToString(fingerprint[:])
+}
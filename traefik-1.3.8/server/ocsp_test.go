@@ -0,0 +1,277 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+)
+
+// testOCSPResponder signs the OCSP responses of the certificates it issues
+type testOCSPResponder struct {
+	caKey    *ecdsa.PrivateKey
+	ca       *x509.Certificate
+	requests int
+}
+
+func newTestOCSPResponder(t *testing.T) *testOCSPResponder {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "OCSP CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testOCSPResponder{caKey: caKey, ca: ca}
+}
+
+// certificate issues a certificate for the domain, checked with the OCSP server
+func (r *testOCSPResponder) certificate(t *testing.T, domain string, ocspServer string) *tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{domain},
+	}
+	if len(ocspServer) > 0 {
+		template.OCSPServer = []string{ocspServer}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, r.ca, &key.PublicKey, r.caKey)
+	require.NoError(t, err)
+	return &tls.Certificate{Certificate: [][]byte{der, r.ca.Raw}, PrivateKey: key}
+}
+
+// response returns a good OCSP response for the serial number, following RFC 6960
+func (r *testOCSPResponder) response(t *testing.T, serialNumber *big.Int, thisUpdate, nextUpdate time.Time) []byte {
+	type certID struct {
+		HashAlgorithm pkix.AlgorithmIdentifier
+		NameHash      []byte
+		IssuerKeyHash []byte
+		SerialNumber  *big.Int
+	}
+	type singleResponse struct {
+		CertID     certID
+		Status     asn1.RawValue
+		ThisUpdate time.Time `asn1:"generalized"`
+		NextUpdate time.Time `asn1:"generalized,explicit,tag:0"`
+	}
+	type responseData struct {
+		ResponderID asn1.RawValue
+		ProducedAt  time.Time `asn1:"generalized"`
+		Responses   []singleResponse
+	}
+	type basicResponse struct {
+		TBSResponseData    asn1.RawValue
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		Signature          asn1.BitString
+	}
+	type responseBytes struct {
+		ResponseType asn1.ObjectIdentifier
+		Response     []byte
+	}
+	type response struct {
+		Status   asn1.Enumerated
+		Response responseBytes `asn1:"explicit,tag:0"`
+	}
+
+	keyHash := sha256.Sum256(r.ca.RawSubjectPublicKeyInfo)
+	responderID, err := asn1.Marshal(keyHash[:20])
+	require.NoError(t, err)
+	tbs, err := asn1.Marshal(responseData{
+		ResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: responderID},
+		ProducedAt:  thisUpdate.UTC(),
+		Responses: []singleResponse{{
+			CertID: certID{
+				HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}, Parameters: asn1.NullRawValue},
+				NameHash:      make([]byte, 20),
+				IssuerKeyHash: make([]byte, 20),
+				SerialNumber:  serialNumber,
+			},
+			Status:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0},
+			ThisUpdate: thisUpdate.UTC(),
+			NextUpdate: nextUpdate.UTC(),
+		}},
+	})
+	require.NoError(t, err)
+	digest := sha256.Sum256(tbs)
+	signature, err := ecdsa.SignASN1(rand.Reader, r.caKey, digest[:])
+	require.NoError(t, err)
+	basic, err := asn1.Marshal(basicResponse{
+		TBSResponseData:    asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	})
+	require.NoError(t, err)
+	raw, err := asn1.Marshal(response{
+		Response: responseBytes{ResponseType: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}, Response: basic},
+	})
+	require.NoError(t, err)
+	return raw
+}
+
+func TestOCSPStapler(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "traefik-ocsp")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	responder := newTestOCSPResponder(t)
+	var certificate *tls.Certificate
+	ocspServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		responder.requests++
+		leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+		require.NoError(t, err)
+		rw.Header().Set("Content-Type", "application/ocsp-response")
+		rw.Write(responder.response(t, leaf.SerialNumber, time.Now().Add(-time.Minute), time.Now().Add(time.Hour)))
+	}))
+	defer ocspServer.Close()
+	certificate = responder.certificate(t, "example.com", ocspServer.URL)
+
+	stapler, err := newOCSPStapler(&OCSPStapling{CacheDir: cacheDir}, nil)
+	require.NoError(t, err)
+
+	// the response is fetched in the background
+	assert.Empty(t, stapler.staple(certificate).OCSPStaple)
+	statuses := stapler.statuses()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, ocspStatusPending, statuses[0].Status)
+
+	stapler.refreshStaples()
+	assert.Equal(t, 1, responder.requests)
+	stapled := stapler.staple(certificate)
+	require.NotEmpty(t, stapled.OCSPStaple)
+	assert.Empty(t, certificate.OCSPStaple, "the served certificate must not be modified")
+	response, err := ocsp.ParseResponse(stapled.OCSPStaple, responder.ca)
+	require.NoError(t, err)
+	assert.Equal(t, ocsp.Good, response.Status)
+	statuses = stapler.statuses()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, []string{"example.com"}, statuses[0].Domains)
+	assert.Equal(t, ocspStatusGood, statuses[0].Status)
+	require.NotNil(t, statuses[0].NextUpdate)
+	require.NotNil(t, statuses[0].NextRefresh)
+	assert.True(t, statuses[0].NextRefresh.Before(*statuses[0].NextUpdate))
+
+	// not refreshed before half of its validity
+	stapler.refreshStaples()
+	assert.Equal(t, 1, responder.requests)
+
+	// the cached response is stapled after a restart
+	restarted, err := newOCSPStapler(&OCSPStapling{CacheDir: cacheDir}, nil)
+	require.NoError(t, err)
+	restarted.fetch = func(bundle []byte) ([]byte, *ocsp.Response, error) {
+		return nil, nil, errors.New("unreachable")
+	}
+	assert.Equal(t, stapled.OCSPStaple, restarted.staple(certificate).OCSPStaple)
+
+	// the failures are reported
+	failing, err := newOCSPStapler(&OCSPStapling{}, nil)
+	require.NoError(t, err)
+	failing.fetch = restarted.fetch
+	failing.staple(certificate)
+	failing.refreshStaples()
+	assert.Empty(t, failing.staple(certificate).OCSPStaple)
+	statuses = failing.statuses()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, ocspStatusError, statuses[0].Status)
+	assert.Equal(t, "unreachable", statuses[0].Error)
+
+	// the certificates without OCSP server aren't fetched
+	unsupported := responder.certificate(t, "www.example.com", "")
+	failing.staple(unsupported)
+	failing.refreshStaples()
+	statuses = failing.statuses()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, ocspStatusUnsupported, statuses[1].Status)
+	assert.Nil(t, statuses[1].NextRefresh)
+}
+
+func TestOCSPStaplerRejectsOtherCertificates(t *testing.T) {
+	responder := newTestOCSPResponder(t)
+	certificate := responder.certificate(t, "example.com", "http://127.0.0.1/")
+	stapler, err := newOCSPStapler(&OCSPStapling{}, nil)
+	require.NoError(t, err)
+	stapler.fetch = func(bundle []byte) ([]byte, *ocsp.Response, error) {
+		raw := responder.response(t, big.NewInt(42), time.Now().Add(-time.Minute), time.Now().Add(time.Hour))
+		response, err := ocsp.ParseResponse(raw, responder.ca)
+		return raw, response, err
+	}
+	stapler.staple(certificate)
+	stapler.refreshStaples()
+	assert.Empty(t, stapler.staple(certificate).OCSPStaple)
+	assert.Equal(t, ocspStatusError, stapler.statuses()[0].Status)
+}
+
+func TestCreateTLSConfigWithOCSPStapling(t *testing.T) {
+	responder := newTestOCSPResponder(t)
+	certificate := responder.certificate(t, "example.com", "http://127.0.0.1/")
+	stapler, err := newOCSPStapler(&OCSPStapling{}, nil)
+	require.NoError(t, err)
+	stapler.fetch = func(bundle []byte) ([]byte, *ocsp.Response, error) {
+		leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+		require.NoError(t, err)
+		raw := responder.response(t, leaf.SerialNumber, time.Now().Add(-time.Minute), time.Now().Add(time.Hour))
+		response, err := ocsp.ParseResponse(raw, responder.ca)
+		return raw, response, err
+	}
+	cert, key := generateCertificate(t, "example.org")
+
+	tlsOption := &TLS{Certificates: Certificates{{CertFile: cert, KeyFile: key}}}
+	server := &Server{
+		globalConfiguration: GlobalConfiguration{EntryPoints: EntryPoints{"https": &EntryPoint{TLS: tlsOption}}},
+		serverEntryPoints:   serverEntryPoints{"https": &serverEntryPoint{certificates: newCertificateStore()}},
+		ocspStapler:         stapler,
+	}
+	stapler.served = server.servedCertificates
+	server.serverEntryPoints["https"].certificates.set(map[string]*tls.Certificate{"example.com": certificate})
+	config, err := server.createTLSConfig("https", tlsOption, nil)
+	require.NoError(t, err)
+	assert.Empty(t, config.Certificates)
+
+	// the first certificate is still served without server name
+	served, err := config.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(served.Certificate[0])
+	require.NoError(t, err)
+	assert.Equal(t, "example.org", leaf.Subject.CommonName)
+
+	// the served certificates are stapled before their first handshake
+	stapler.refreshStaples()
+	require.Len(t, stapler.statuses(), 2)
+	served, err = config.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, served.OCSPStaple)
+
+	// and dropped once they aren't served anymore
+	server.serverEntryPoints["https"].certificates.set(map[string]*tls.Certificate{})
+	stapler.refreshStaples()
+	statuses := stapler.statuses()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, []string{"example.org"}, statuses[0].Domains)
+}
@@ -1,23 +1,30 @@
 package server
 
 import (
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
 
 	"github.com/BurntSushi/ty/fun"
 	"github.com/containous/mux"
+	"github.com/containous/traefik/log"
 	"github.com/containous/traefik/types"
+	"github.com/containous/traefik/whitelist"
 )
 
 // Rules holds rule parsing and configuration
 type Rules struct {
 	route *serverRoute
 	err   error
+	// trustedIPs are the addresses allowed to set the X-Forwarded-For header
+	// read by the ClientIP matcher
+	trustedIPs *whitelist.IP
 }
 
 func (r *Rules) host(hosts ...string) *mux.Route {
@@ -131,111 +138,288 @@ func (r *Rules) headersRegexp(headers ...string) *mux.Route {
 	return r.route.route.HeadersRegexp(headers...)
 }
 
-func (r *Rules) parseRules(expression string, onRule func(functionName string, function interface{}, arguments []string) error) error {
-	functions := map[string]interface{}{
-		"Host":                 r.host,
-		"HostRegexp":           r.hostRegexp,
-		"Path":                 r.path,
-		"PathStrip":            r.pathStrip,
-		"PathStripRegex":       r.pathStripRegex,
-		"PathPrefix":           r.pathPrefix,
-		"PathPrefixStrip":      r.pathPrefixStrip,
-		"PathPrefixStripRegex": r.pathPrefixStripRegex,
-		"Method":               r.methods,
-		"Headers":              r.headers,
-		"HeadersRegexp":        r.headersRegexp,
-		"AddPrefix":            r.addPrefix,
-		"ReplacePath":          r.replacePath,
+// splitKeyValue splits a key=value pair, the value being optional.
+func splitKeyValue(pair string) (string, string, bool) {
+	kv := strings.SplitN(pair, "=", 2)
+	if len(kv) == 1 {
+		return strings.TrimSpace(kv[0]), "", false
 	}
+	return strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]), true
+}
 
-	if len(expression) == 0 {
-		return errors.New("Empty rule")
-	}
+func (r *Rules) query(queries ...string) *mux.Route {
+	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
+		values := req.URL.Query()
+		for _, query := range queries {
+			key, value, hasValue := splitKeyValue(query)
+			if _, ok := values[key]; !ok {
+				continue
+			}
+			if !hasValue {
+				return true
+			}
+			for _, v := range values[key] {
+				if v == value {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
 
-	f := func(c rune) bool {
-		return c == ':'
+func (r *Rules) queryRegexp(queries ...string) *mux.Route {
+	keys := make([]string, len(queries))
+	regexps := make([]*regexp.Regexp, len(queries))
+	for i, query := range queries {
+		key, value, _ := splitKeyValue(query)
+		re, err := regexp.Compile(value)
+		if err != nil {
+			r.err = fmt.Errorf("invalid regular expression for query parameter %s: %v", key, err)
+			return r.route.route
+		}
+		keys[i] = key
+		regexps[i] = re
 	}
+	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
+		values := req.URL.Query()
+		for i, key := range keys {
+			for _, v := range values[key] {
+				if regexps[i].MatchString(v) {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
 
-	// Allow multiple rules separated by ;
-	splitRule := func(c rune) bool {
-		return c == ';'
-	}
+func (r *Rules) cookie(cookies ...string) *mux.Route {
+	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
+		for _, cookie := range cookies {
+			name, value, hasValue := splitKeyValue(cookie)
+			for _, c := range req.Cookies() {
+				if c.Name == name && (!hasValue || c.Value == value) {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
 
-	parsedRules := strings.FieldsFunc(expression, splitRule)
+func (r *Rules) clientIP(ranges ...string) *mux.Route {
+	ips, err := whitelist.NewIP(ranges)
+	if err != nil {
+		r.err = err
+		return r.route.route
+	}
+	trustedIPs := r.trustedIPs
+	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
+		clientIP, err := trustedIPs.ClientIP(req)
+		if err != nil {
+			log.Debugf("Unable to match client IP: %v", err)
+			return false
+		}
+		return ips.ContainsIP(clientIP)
+	})
+}
 
-	for _, rule := range parsedRules {
-		// get function
-		parsedFunctions := strings.FieldsFunc(rule, f)
-		if len(parsedFunctions) == 0 {
-			return errors.New("Error parsing rule: '" + rule + "'")
+// clientCertCN matches the requests whose verified client certificate has one
+// of the common names
+func (r *Rules) clientCertCN(names ...string) *mux.Route {
+	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
+		cert := verifiedClientCert(req)
+		if cert == nil {
+			return false
 		}
-		functionName := strings.TrimSpace(parsedFunctions[0])
-		parsedFunction, ok := functions[functionName]
-		if !ok {
-			return errors.New("Error parsing rule: '" + rule + "'. Unknown function: '" + parsedFunctions[0] + "'")
+		for _, name := range names {
+			if name == cert.Subject.CommonName {
+				return true
+			}
 		}
-		parsedFunctions = append(parsedFunctions[:0], parsedFunctions[1:]...)
-		fargs := func(c rune) bool {
-			return c == ','
+		return false
+	})
+}
+
+// clientCertOrganization matches the requests whose verified client certificate
+// belongs to one of the organizations
+func (r *Rules) clientCertOrganization(organizations ...string) *mux.Route {
+	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
+		cert := verifiedClientCert(req)
+		if cert == nil {
+			return false
 		}
-		// get function
-		parsedArgs := strings.FieldsFunc(strings.Join(parsedFunctions, ":"), fargs)
-		if len(parsedArgs) == 0 {
-			return errors.New("Error parsing args from rule: '" + rule + "'")
+		for _, organization := range cert.Subject.Organization {
+			for _, name := range organizations {
+				if name == organization {
+					return true
+				}
+			}
 		}
+		return false
+	})
+}
 
-		for i := range parsedArgs {
-			parsedArgs[i] = strings.TrimSpace(parsedArgs[i])
-		}
+// verifiedClientCert returns the client certificate of the request, if it has
+// been verified against the client CAs of the entrypoint
+func verifiedClientCert(req *http.Request) *x509.Certificate {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return req.TLS.VerifiedChains[0][0]
+}
 
-		err := onRule(functionName, parsedFunction, parsedArgs)
-		if err != nil {
-			return fmt.Errorf("Parsing error on rule: %v", err)
-		}
+// ruleModifiers lists the rules modifying the request before it is forwarded.
+// They apply to the whole frontend, so they cannot be negated or alternated.
+var ruleModifiers = map[string]bool{
+	"PathStrip":            true,
+	"PathStripRegex":       true,
+	"PathPrefixStrip":      true,
+	"PathPrefixStripRegex": true,
+	"AddPrefix":            true,
+	"ReplacePath":          true,
+}
+
+func (r *Rules) functions() map[string]interface{} {
+	return map[string]interface{}{
+		"Host":                   r.host,
+		"HostRegexp":             r.hostRegexp,
+		"Path":                   r.path,
+		"PathStrip":              r.pathStrip,
+		"PathStripRegex":         r.pathStripRegex,
+		"PathPrefix":             r.pathPrefix,
+		"PathPrefixStrip":        r.pathPrefixStrip,
+		"PathPrefixStripRegex":   r.pathPrefixStripRegex,
+		"Method":                 r.methods,
+		"Headers":                r.headers,
+		"HeadersRegexp":          r.headersRegexp,
+		"Query":                  r.query,
+		"QueryRegexp":            r.queryRegexp,
+		"Cookie":                 r.cookie,
+		"ClientIP":               r.clientIP,
+		"ClientCertCN":           r.clientCertCN,
+		"ClientCertOrganization": r.clientCertOrganization,
+		"AddPrefix":              r.addPrefix,
+		"ReplacePath":            r.replacePath,
 	}
-	return nil
 }
 
-// Parse parses rules expressions
-func (r *Rules) Parse(expression string) (*mux.Route, error) {
-	var resultRoute *mux.Route
-	err := r.parseRules(expression, func(functionName string, function interface{}, arguments []string) error {
-		inputs := make([]reflect.Value, len(arguments))
-		for i := range arguments {
-			inputs[i] = reflect.ValueOf(arguments[i])
-		}
-		method := reflect.ValueOf(function)
-		if method.IsValid() {
-			resultRoute = method.Call(inputs)[0].Interface().(*mux.Route)
-			if r.err != nil {
-				return r.err
-			}
-			if resultRoute.GetError() != nil {
-				return resultRoute.GetError()
-			}
+func (r *Rules) parseRules(expression string) (*ruleNode, error) {
+	if len(strings.TrimSpace(expression)) == 0 {
+		return nil, errors.New("Empty rule")
+	}
 
-		} else {
-			return errors.New("Method not found: '" + functionName + "'")
+	node, err := parseRuleExpression(expression)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing rule: '%s': %v", expression, err)
+	}
+
+	functions := r.functions()
+	err = node.walk(false, func(matcher *ruleNode, negated bool) error {
+		if _, ok := functions[matcher.name]; !ok {
+			return fmt.Errorf("Error parsing rule: '%s'. Unknown function: '%s' at position %d", expression, matcher.name, matcher.pos)
 		}
 		return nil
 	})
 	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// buildRoute adds the matchers of the expression node to the given route.
+// Conjunctions are added directly to the route, disjunctions through a
+// subrouter, and negations through a detached route.
+func (r *Rules) buildRoute(route *mux.Route, node *ruleNode, nested bool) error {
+	switch node.kind {
+	case ruleNodeAnd:
+		for _, child := range node.children {
+			if err := r.buildRoute(route, child, nested); err != nil {
+				return err
+			}
+		}
+	case ruleNodeOr:
+		router := route.Subrouter()
+		for _, child := range node.children {
+			if err := r.buildRoute(router.NewRoute(), child, true); err != nil {
+				return err
+			}
+		}
+	case ruleNodeNot:
+		negated := newDetachedRoute()
+		if err := r.buildRoute(negated, node.children[0], true); err != nil {
+			return err
+		}
+		route.MatcherFunc(func(req *http.Request, match *mux.RouteMatch) bool {
+			return !negated.Match(req, &mux.RouteMatch{})
+		})
+	case ruleNodeMatcher:
+		if nested && ruleModifiers[node.name] {
+			return fmt.Errorf("'%s' at position %d modifies the request and cannot be used with OR or NOT", node.name, node.pos)
+		}
+		return r.callFunction(route, node.name, node.args)
+	}
+	return route.GetError()
+}
+
+// callFunction applies the named rule function to the given route.
+func (r *Rules) callFunction(route *mux.Route, functionName string, arguments []string) error {
+	method := reflect.ValueOf(r.functions()[functionName])
+	if !method.IsValid() {
+		return errors.New("Method not found: '" + functionName + "'")
+	}
+
+	// rule functions work on the current route: point it to the target one
+	// for the duration of the call.
+	current := r.route.route
+	r.route.route = route
+	defer func() { r.route.route = current }()
+
+	inputs := make([]reflect.Value, len(arguments))
+	for i := range arguments {
+		inputs[i] = reflect.ValueOf(arguments[i])
+	}
+	resultRoute := method.Call(inputs)[0].Interface().(*mux.Route)
+	if r.err != nil {
+		return r.err
+	}
+	if resultRoute == nil {
+		return route.GetError()
+	}
+	return resultRoute.GetError()
+}
+
+// newDetachedRoute returns a route which is not registered as a matcher of
+// any other route, configured as the entrypoint routers are.
+func newDetachedRoute() *mux.Route {
+	return mux.NewRouter().StrictSlash(true).SkipClean(true).NewRoute()
+}
+
+// Parse parses rules expressions
+func (r *Rules) Parse(expression string) (*mux.Route, error) {
+	node, err := r.parseRules(expression)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.buildRoute(r.route.route, node, false); err != nil {
 		return nil, fmt.Errorf("Error parsing rule: %v", err)
 	}
-	return resultRoute, nil
+	return r.route.route, nil
 }
 
 // ParseDomains parses rules expressions and returns domains
 func (r *Rules) ParseDomains(expression string) ([]string, error) {
 	domains := []string{}
-	err := r.parseRules(expression, func(functionName string, function interface{}, arguments []string) error {
-		if functionName == "Host" {
-			domains = append(domains, arguments...)
-		}
-		return nil
-	})
+	node, err := r.parseRules(expression)
 	if err != nil {
 		return nil, fmt.Errorf("Error parsing domains: %v", err)
 	}
+	node.walk(false, func(matcher *ruleNode, negated bool) error {
+		if matcher.name == "Host" && !negated {
+			domains = append(domains, matcher.args...)
+		}
+		return nil
+	})
 	return fun.Map(types.CanonicalDomain, domains).([]string), nil
 }
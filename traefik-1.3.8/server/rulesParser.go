@@ -0,0 +1,415 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Rule expressions combine matchers with boolean operators:
+//
+//	Host:a.com AND (PathPrefix:/api OR Headers:X-Beta,1) AND NOT Method:DELETE
+//
+// `AND` (or `&&`, or the legacy `;`), `OR` (or `||`) and `NOT` (or `!`) are
+// supported, as well as parentheses for grouping. NOT binds tighter than AND,
+// which binds tighter than OR.
+//
+// expression := or
+// or         := and ( "OR" and )*
+// and        := unary ( ( "AND" | ";" ) unary )*
+// unary      := "NOT" unary | "(" or ")" | matcher
+// matcher    := name ":" arguments
+
+type ruleTokenKind int
+
+const (
+	ruleTokenEOF ruleTokenKind = iota
+	ruleTokenMatcher
+	ruleTokenAnd
+	ruleTokenOr
+	ruleTokenNot
+	ruleTokenOpen
+	ruleTokenClose
+)
+
+type ruleToken struct {
+	kind ruleTokenKind
+	// pos is the 1-based position of the token in the expression.
+	pos  int
+	name string
+	args []string
+}
+
+func (t ruleToken) String() string {
+	switch t.kind {
+	case ruleTokenEOF:
+		return "end of rule"
+	case ruleTokenMatcher:
+		return "'" + t.name + "'"
+	case ruleTokenAnd:
+		return "'AND'"
+	case ruleTokenOr:
+		return "'OR'"
+	case ruleTokenNot:
+		return "'NOT'"
+	case ruleTokenOpen:
+		return "'('"
+	case ruleTokenClose:
+		return "')'"
+	}
+	return "unknown token"
+}
+
+type ruleNodeKind int
+
+const (
+	ruleNodeMatcher ruleNodeKind = iota
+	ruleNodeAnd
+	ruleNodeOr
+	ruleNodeNot
+)
+
+// ruleNode is a node of a parsed rule expression.
+type ruleNode struct {
+	kind     ruleNodeKind
+	pos      int
+	name     string
+	args     []string
+	children []*ruleNode
+}
+
+// String returns a canonical representation of the expression.
+func (n *ruleNode) String() string {
+	switch n.kind {
+	case ruleNodeMatcher:
+		return n.name + ":" + strings.Join(n.args, ",")
+	case ruleNodeNot:
+		return "NOT " + n.children[0].wrappedString()
+	}
+	var parts []string
+	for _, child := range n.children {
+		parts = append(parts, child.wrappedString())
+	}
+	if n.kind == ruleNodeAnd {
+		return strings.Join(parts, " AND ")
+	}
+	return strings.Join(parts, " OR ")
+}
+
+func (n *ruleNode) wrappedString() string {
+	if n.kind == ruleNodeAnd || n.kind == ruleNodeOr {
+		return "(" + n.String() + ")"
+	}
+	return n.String()
+}
+
+// walk calls fn for each matcher of the expression, telling whether it is
+// negated by an odd number of NOT operators.
+func (n *ruleNode) walk(negated bool, fn func(matcher *ruleNode, negated bool) error) error {
+	if n.kind == ruleNodeMatcher {
+		return fn(n, negated)
+	}
+	if n.kind == ruleNodeNot {
+		negated = !negated
+	}
+	for _, child := range n.children {
+		if err := child.walk(negated, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type ruleLexer struct {
+	input  string
+	pos    int
+	depth  int
+	tokens []ruleToken
+}
+
+func lexRule(input string) ([]ruleToken, error) {
+	l := &ruleLexer{input: input}
+	expectOperand := true
+	for {
+		l.skipSpaces()
+		if l.pos >= len(l.input) {
+			break
+		}
+		start := l.pos
+		c := l.input[l.pos]
+		if expectOperand {
+			switch {
+			case c == ';' && len(l.tokens) == 0:
+				// leading separators are ignored, as they used to be
+				l.pos++
+			case c == '(':
+				l.emit(ruleToken{kind: ruleTokenOpen, pos: start + 1})
+				l.depth++
+				l.pos++
+			case c == '!':
+				l.emit(ruleToken{kind: ruleTokenNot, pos: start + 1})
+				l.pos++
+			case l.keywordAt(l.pos, "NOT"):
+				l.emit(ruleToken{kind: ruleTokenNot, pos: start + 1})
+				l.pos += len("NOT")
+			default:
+				token, err := l.lexMatcher()
+				if err != nil {
+					return nil, err
+				}
+				l.emit(token)
+				expectOperand = false
+			}
+			continue
+		}
+		switch {
+		case c == ')':
+			if l.depth == 0 {
+				return nil, fmt.Errorf("unexpected ')' at position %d", start+1)
+			}
+			l.emit(ruleToken{kind: ruleTokenClose, pos: start + 1})
+			l.depth--
+			l.pos++
+		case c == ';':
+			// repeated or trailing separators are ignored, as they used to be
+			for l.pos < len(l.input) && (l.input[l.pos] == ';' || unicode.IsSpace(rune(l.input[l.pos]))) {
+				l.pos++
+			}
+			if l.pos < len(l.input) {
+				l.emit(ruleToken{kind: ruleTokenAnd, pos: start + 1})
+				expectOperand = true
+			}
+		case l.keywordAt(l.pos, "AND"), l.keywordAt(l.pos, "&&"):
+			l.emit(ruleToken{kind: ruleTokenAnd, pos: start + 1})
+			l.pos += 2
+			if c == 'A' {
+				l.pos++
+			}
+			expectOperand = true
+		case l.keywordAt(l.pos, "OR"), l.keywordAt(l.pos, "||"):
+			l.emit(ruleToken{kind: ruleTokenOr, pos: start + 1})
+			l.pos += 2
+			expectOperand = true
+		default:
+			return nil, fmt.Errorf("unexpected '%s' at position %d, expected an operator", l.word(), start+1)
+		}
+	}
+	l.emit(ruleToken{kind: ruleTokenEOF, pos: len(l.input) + 1})
+	return l.tokens, nil
+}
+
+func (l *ruleLexer) emit(token ruleToken) {
+	l.tokens = append(l.tokens, token)
+}
+
+func (l *ruleLexer) skipSpaces() {
+	for l.pos < len(l.input) && unicode.IsSpace(rune(l.input[l.pos])) {
+		l.pos++
+	}
+}
+
+// word returns the text from the current position up to the next space.
+func (l *ruleLexer) word() string {
+	end := strings.IndexFunc(l.input[l.pos:], unicode.IsSpace)
+	if end < 0 {
+		return l.input[l.pos:]
+	}
+	return l.input[l.pos : l.pos+end]
+}
+
+// keywordAt tells whether the operator keyword starts at pos and is followed
+// by a space, a parenthesis, a negation or the end of the expression.
+func (l *ruleLexer) keywordAt(pos int, keyword string) bool {
+	if !strings.HasPrefix(l.input[pos:], keyword) {
+		return false
+	}
+	next := pos + len(keyword)
+	if next == len(l.input) {
+		return true
+	}
+	c := l.input[next]
+	return unicode.IsSpace(rune(c)) || c == '(' || c == '!'
+}
+
+// operatorAfterSpaces tells whether an AND/OR operator follows the spaces at pos.
+func (l *ruleLexer) operatorAfterSpaces(pos int) bool {
+	for pos < len(l.input) && unicode.IsSpace(rune(l.input[pos])) {
+		pos++
+	}
+	if pos >= len(l.input) {
+		return false
+	}
+	return l.keywordAt(pos, "AND") || l.keywordAt(pos, "&&") || l.keywordAt(pos, "OR") || l.keywordAt(pos, "||")
+}
+
+func (l *ruleLexer) lexMatcher() (ruleToken, error) {
+	start := l.pos
+	for l.pos < len(l.input) && (unicode.IsLetter(rune(l.input[l.pos])) || unicode.IsDigit(rune(l.input[l.pos]))) {
+		l.pos++
+	}
+	name := l.input[start:l.pos]
+	l.skipSpaces()
+	if len(name) == 0 || l.pos >= len(l.input) || l.input[l.pos] != ':' {
+		l.pos = start
+		return ruleToken{}, fmt.Errorf("unexpected '%s' at position %d, expected a matcher", l.word(), start+1)
+	}
+	l.pos++
+
+	// Arguments run until a separator, an operator, or the parenthesis closing
+	// the current group. Brackets opened inside an argument (regular
+	// expressions, mux variables) are kept as part of it, except when escaped
+	// with a backslash or inside a character class.
+	argsStart := l.pos
+	nesting := 0
+	inClass := false
+	var args []string
+	argStart := l.pos
+	for ; l.pos < len(l.input); l.pos++ {
+		c := l.input[l.pos]
+		if c == '\\' && l.pos+1 < len(l.input) {
+			l.pos++
+			continue
+		}
+		if inClass {
+			if c == ']' {
+				inClass = false
+			}
+			continue
+		}
+		if c == '[' {
+			// a ']' right after the opening bracket, or its negation, is part
+			// of the class
+			inClass = true
+			if l.pos+1 < len(l.input) && l.input[l.pos+1] == '^' {
+				l.pos++
+			}
+			if l.pos+1 < len(l.input) && l.input[l.pos+1] == ']' {
+				l.pos++
+			}
+			continue
+		}
+		if nesting == 0 {
+			if c == ';' || (c == ')' && l.depth > 0) {
+				break
+			}
+			if unicode.IsSpace(rune(c)) && l.operatorAfterSpaces(l.pos) {
+				break
+			}
+			if c == ',' {
+				args = appendRuleArgument(args, l.input[argStart:l.pos])
+				argStart = l.pos + 1
+				continue
+			}
+		}
+		switch c {
+		case '(', '{':
+			nesting++
+		case ')', '}':
+			if nesting > 0 {
+				nesting--
+			}
+		}
+	}
+	args = appendRuleArgument(args, l.input[argStart:l.pos])
+	if len(args) == 0 {
+		return ruleToken{}, fmt.Errorf("missing arguments for '%s' at position %d", name, argsStart+1)
+	}
+	return ruleToken{kind: ruleTokenMatcher, pos: start + 1, name: name, args: args}, nil
+}
+
+func appendRuleArgument(args []string, arg string) []string {
+	arg = strings.TrimSpace(arg)
+	if len(arg) == 0 {
+		return args
+	}
+	return append(args, arg)
+}
+
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+}
+
+// parseRuleExpression parses a rule expression into its syntax tree.
+func parseRuleExpression(expression string) (*ruleNode, error) {
+	tokens, err := lexRule(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &ruleParser{tokens: tokens}
+	if p.peek().kind == ruleTokenEOF {
+		return nil, fmt.Errorf("empty rule")
+	}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token.kind != ruleTokenEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", token, token.pos)
+	}
+	return node, nil
+}
+
+func (p *ruleParser) peek() ruleToken {
+	return p.tokens[p.pos]
+}
+
+func (p *ruleParser) next() ruleToken {
+	token := p.tokens[p.pos]
+	if token.kind != ruleTokenEOF {
+		p.pos++
+	}
+	return token
+}
+
+func (p *ruleParser) parseOr() (*ruleNode, error) {
+	return p.parseBinary(ruleTokenOr, ruleNodeOr, p.parseAnd)
+}
+
+func (p *ruleParser) parseAnd() (*ruleNode, error) {
+	return p.parseBinary(ruleTokenAnd, ruleNodeAnd, p.parseUnary)
+}
+
+func (p *ruleParser) parseBinary(operator ruleTokenKind, kind ruleNodeKind, operand func() (*ruleNode, error)) (*ruleNode, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != operator {
+		return first, nil
+	}
+	node := &ruleNode{kind: kind, pos: first.pos, children: []*ruleNode{first}}
+	for p.peek().kind == operator {
+		p.next()
+		child, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		node.children = append(node.children, child)
+	}
+	return node, nil
+}
+
+func (p *ruleParser) parseUnary() (*ruleNode, error) {
+	token := p.next()
+	switch token.kind {
+	case ruleTokenNot:
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &ruleNode{kind: ruleNodeNot, pos: token.pos, children: []*ruleNode{child}}, nil
+	case ruleTokenOpen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != ruleTokenClose {
+			return nil, fmt.Errorf("missing ')' at position %d to close '(' at position %d", closing.pos, token.pos)
+		}
+		return node, nil
+	case ruleTokenMatcher:
+		return &ruleNode{kind: ruleNodeMatcher, pos: token.pos, name: token.name, args: token.args}, nil
+	}
+	return nil, fmt.Errorf("unexpected %s at position %d, expected a matcher", token, token.pos)
+}
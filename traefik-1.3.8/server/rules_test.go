@@ -1,12 +1,16 @@
 package server
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"net/http"
 	"net/url"
 	"reflect"
 	"testing"
 
 	"github.com/containous/mux"
+	"github.com/containous/traefik/whitelist"
 )
 
 func TestParseOneRule(t *testing.T) {
@@ -68,12 +72,16 @@ func TestParseDomains(t *testing.T) {
 		"Path:/test",
 		"Host:foo.bar;Path:/test",
 		"Host: Foo.Bar ;Path:/test",
+		"Host:foo.bar OR (Host:test.bar AND Path:/test)",
+		"Host:foo.bar AND NOT Host:test.bar",
 	}
 	domainsSlice := [][]string{
 		{"foo.bar", "test.bar"},
 		{},
 		{"foo.bar"},
 		{"foo.bar"},
+		{"foo.bar", "test.bar"},
+		{"foo.bar"},
 	}
 	for i, expression := range expressionsSlice {
 		domains, err := rules.ParseDomains(expression)
@@ -86,6 +94,181 @@ func TestParseDomains(t *testing.T) {
 	}
 }
 
+func TestParseBooleanRules(t *testing.T) {
+	expression := "Host:a.com AND (PathPrefix:/api OR Headers:X-Beta,1) AND NOT Method:DELETE"
+	cases := []struct {
+		method  string
+		url     string
+		headers map[string]string
+		match   bool
+	}{
+		{method: "GET", url: "http://a.com/api/users", match: true},
+		{method: "GET", url: "http://a.com/home", headers: map[string]string{"X-Beta": "1"}, match: true},
+		{method: "GET", url: "http://a.com/home", match: false},
+		{method: "DELETE", url: "http://a.com/api/users", match: false},
+		{method: "GET", url: "http://b.com/api/users", match: false},
+	}
+
+	router := mux.NewRouter()
+	rules := &Rules{route: &serverRoute{route: router.NewRoute()}}
+	routeResult, err := rules.Parse(expression)
+	if err != nil {
+		t.Fatalf("Error while building route for %s: %v", expression, err)
+	}
+
+	for _, c := range cases {
+		request, _ := http.NewRequest(c.method, c.url, nil)
+		for name, value := range c.headers {
+			request.Header.Set(name, value)
+		}
+		routeMatch := routeResult.Match(request, &mux.RouteMatch{Route: routeResult})
+		if routeMatch != c.match {
+			t.Errorf("Rule %s on %s %s %v: expected match %t, got %t", expression, c.method, c.url, c.headers, c.match, routeMatch)
+		}
+	}
+}
+
+func TestParseRulesOperatorsPrecedence(t *testing.T) {
+	cases := []struct {
+		expression string
+		expected   string
+	}{
+		{"Host:foo.bar", "Host:foo.bar"},
+		{"Host: Foo.Bar ; Path:/FOObar", "Host:Foo.Bar AND Path:/FOObar"},
+		{";Host:foo.bar;;Path:/test;", "Host:foo.bar AND Path:/test"},
+		{"Host:a OR Host:b AND Path:/c", "Host:a OR (Host:b AND Path:/c)"},
+		{"(Host:a || Host:b) && !Path:/c", "(Host:a OR Host:b) AND NOT Path:/c"},
+		{"NOT (Method:GET,HEAD)", "NOT Method:GET,HEAD"},
+		{"NOT(Host:a OR Host:b)", "NOT (Host:a OR Host:b)"},
+		{"HeadersRegexp:Content-Type, application/(text|json)", "HeadersRegexp:Content-Type,application/(text|json)"},
+		{"(HeadersRegexp:Content-Type,application/(text|json))", "HeadersRegexp:Content-Type,application/(text|json)"},
+		{"Path:/{id:[0-9]{1,3}}", "Path:/{id:[0-9]{1,3}}"},
+		{"Headers:User-Agent,Mozilla 5.0", "Headers:User-Agent,Mozilla 5.0"},
+		{`PathRegexp:/a\(b; Host:x`, `PathRegexp:/a\(b AND Host:x`},
+		{`(PathRegexp:/a\) OR Host:x)`, `PathRegexp:/a\) OR Host:x`},
+		{"PathRegexp:/[(]a; Host:x", "PathRegexp:/[(]a AND Host:x"},
+		{"PathRegexp:/[]);]a; Host:x", "PathRegexp:/[]);]a AND Host:x"},
+		{"HeadersRegexp:X-Id,[^]{]+ OR Host:x", "HeadersRegexp:X-Id,[^]{]+ OR Host:x"},
+	}
+	for _, c := range cases {
+		node, err := parseRuleExpression(c.expression)
+		if err != nil {
+			t.Errorf("Error parsing %q: %v", c.expression, err)
+			continue
+		}
+		if node.String() != c.expected {
+			t.Errorf("Error parsing %q: expected %q, got %q", c.expression, c.expected, node.String())
+		}
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	cases := []struct {
+		expression string
+		expected   string
+	}{
+		{"Host:a AND", "unexpected end of rule at position 11, expected a matcher"},
+		{"(Host:a OR Host:b", "missing ')' at position 18 to close '(' at position 1"},
+		{"(Host:a))", "unexpected ')' at position 9"},
+		{"(Host:a) Path:/b", "unexpected 'Path:/b' at position 10, expected an operator"},
+		{"Host:a AND Path", "unexpected 'Path' at position 12, expected a matcher"},
+		{"Host: , ", "missing arguments for 'Host' at position 6"},
+		{"Host:a OR ()", "unexpected ')' at position 12, expected a matcher"},
+	}
+	for _, c := range cases {
+		_, err := parseRuleExpression(c.expression)
+		if err == nil {
+			t.Errorf("Expected an error parsing %q", c.expression)
+			continue
+		}
+		if err.Error() != c.expected {
+			t.Errorf("Error parsing %q: expected error %q, got %q", c.expression, c.expected, err.Error())
+		}
+	}
+
+	rules := &Rules{route: &serverRoute{route: mux.NewRouter().NewRoute()}}
+	for _, expression := range []string{"Host:a OR PathPrefixStrip:/a", "NOT AddPrefix:/a", "Host:a AND Foo:bar"} {
+		if _, err := rules.Parse(expression); err == nil {
+			t.Errorf("Expected an error parsing %q", expression)
+		}
+	}
+}
+
+func TestRequestMatchers(t *testing.T) {
+	trustedIPs, err := whitelist.NewIP([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientCert := &x509.Certificate{Subject: pkix.Name{CommonName: "client1", Organization: []string{"Ops", "Dev"}}}
+	verified := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{clientCert}, VerifiedChains: [][]*x509.Certificate{{clientCert}}}
+	unverified := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{clientCert}}
+
+	cases := []struct {
+		expression    string
+		url           string
+		cookie        *http.Cookie
+		remoteAddr    string
+		xForwardedFor string
+		tls           *tls.ConnectionState
+		match         bool
+	}{
+		{expression: "Query:tenant=foo", url: "http://foo.bar/?tenant=foo", match: true},
+		{expression: "Query:tenant=foo", url: "http://foo.bar/?tenant=bar", match: false},
+		{expression: "Query:tenant=foo,tenant=bar", url: "http://foo.bar/?tenant=bar", match: true},
+		{expression: "Query:debug", url: "http://foo.bar/?debug", match: true},
+		{expression: "Query:debug", url: "http://foo.bar/", match: false},
+		{expression: "QueryRegexp:tenant=^t[0-9]{1,3}$", url: "http://foo.bar/?tenant=t42", match: true},
+		{expression: "QueryRegexp:tenant=^t[0-9]{1,3}$", url: "http://foo.bar/?tenant=t4242", match: false},
+		{expression: "Cookie:beta=1", url: "http://foo.bar/", cookie: &http.Cookie{Name: "beta", Value: "1"}, match: true},
+		{expression: "Cookie:beta=1", url: "http://foo.bar/", cookie: &http.Cookie{Name: "beta", Value: "0"}, match: false},
+		{expression: "Cookie:beta", url: "http://foo.bar/", cookie: &http.Cookie{Name: "beta", Value: "0"}, match: true},
+		{expression: "ClientIP:10.0.0.0/8,::1/128", url: "http://foo.bar/", remoteAddr: "10.1.2.3:1234", match: true},
+		{expression: "ClientIP:10.0.0.0/8,::1/128", url: "http://foo.bar/", remoteAddr: "[::1]:1234", match: true},
+		{expression: "ClientIP:10.0.0.0/8,::1/128", url: "http://foo.bar/", remoteAddr: "8.8.8.8:1234", match: false},
+		{expression: "ClientIP:192.168.0.0/16", url: "http://foo.bar/", remoteAddr: "10.1.2.3:1234", xForwardedFor: "192.168.1.1", match: true},
+		{expression: "ClientIP:192.168.0.0/16", url: "http://foo.bar/", remoteAddr: "8.8.8.8:1234", xForwardedFor: "192.168.1.1", match: false},
+		{expression: "ClientCertCN:client2,client1", url: "https://foo.bar/", tls: verified, match: true},
+		{expression: "ClientCertCN:client2", url: "https://foo.bar/", tls: verified, match: false},
+		{expression: "ClientCertCN:client1", url: "https://foo.bar/", tls: unverified, match: false},
+		{expression: "ClientCertCN:client1", url: "http://foo.bar/", match: false},
+		{expression: "ClientCertOrganization:Dev", url: "https://foo.bar/", tls: verified, match: true},
+		{expression: "ClientCertOrganization:Sales", url: "https://foo.bar/", tls: verified, match: false},
+		{expression: "ClientCertOrganization:Dev", url: "https://foo.bar/", tls: unverified, match: false},
+	}
+
+	for _, c := range cases {
+		rules := &Rules{route: &serverRoute{route: mux.NewRouter().NewRoute()}, trustedIPs: trustedIPs}
+		routeResult, err := rules.Parse(c.expression)
+		if err != nil {
+			t.Fatalf("Error while building route for %s: %v", c.expression, err)
+		}
+
+		request, _ := http.NewRequest("GET", c.url, nil)
+		if c.cookie != nil {
+			request.AddCookie(c.cookie)
+		}
+		if c.remoteAddr != "" {
+			request.RemoteAddr = c.remoteAddr
+		}
+		if c.xForwardedFor != "" {
+			request.Header.Set("X-Forwarded-For", c.xForwardedFor)
+		}
+		request.TLS = c.tls
+		routeMatch := routeResult.Match(request, &mux.RouteMatch{Route: routeResult})
+		if routeMatch != c.match {
+			t.Errorf("Rule %s on %s: expected match %t, got %t", c.expression, c.url, c.match, routeMatch)
+		}
+	}
+
+	rules := &Rules{route: &serverRoute{route: mux.NewRouter().NewRoute()}}
+	for _, expression := range []string{"ClientIP:foo", "QueryRegexp:tenant=("} {
+		if _, err := rules.Parse(expression); err == nil {
+			t.Errorf("Expected an error parsing %q", expression)
+		}
+	}
+}
+
 func TestPriorites(t *testing.T) {
 	router := mux.NewRouter()
 	router.StrictSlash(true)
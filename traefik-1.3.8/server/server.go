@@ -3,10 +3,10 @@ package server
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
 	"errors"
-	"io/ioutil"
+	"fmt"
+	"hash/fnv"
 	"net/http"
 	"net/url"
 	"os"
@@ -14,6 +14,7 @@ import (
 	"reflect"
 	"regexp"
 	"sort"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -27,6 +28,8 @@ import (
 	"github.com/containous/traefik/provider"
 	"github.com/containous/traefik/safe"
 	"github.com/containous/traefik/types"
+	"github.com/containous/traefik/whitelist"
+	kitmetrics "github.com/go-kit/kit/metrics"
 	"github.com/streamrail/concurrent-map"
 	"github.com/vulcand/oxy/cbreaker"
 	"github.com/vulcand/oxy/connlimit"
@@ -50,13 +53,23 @@ type Server struct {
 	loggerMiddleware           *middlewares.Logger
 	routinesPool               *safe.Pool
 	leadership                 *cluster.Leadership
+	diagnostics                safe.Safe
+	// splits holds the weighted splits of the frontends, by frontend
+	splits safe.Safe
+	// rateLimits holds the rate limits of the frontends, by frontend, so that
+	// they are kept across the reloads
+	rateLimits  safe.Safe
+	ocspStapler *ocspStapler
 }
 
 type serverEntryPoints map[string]*serverEntryPoint
 
 type serverEntryPoint struct {
-	httpServer *http.Server
-	httpRouter *middlewares.HandlerSwitcher
+	httpServer   *http.Server
+	httpRouter   *middlewares.HandlerSwitcher
+	certificates *certificateStore
+	// tlsCertificates are the certificates of the entrypoint TLS configuration
+	tlsCertificates []*tls.Certificate
 }
 
 type serverRoute struct {
@@ -80,6 +93,9 @@ func NewServer(globalConfiguration GlobalConfiguration) *Server {
 	signal.Notify(server.signals, syscall.SIGINT, syscall.SIGTERM)
 	currentConfigurations := make(configs)
 	server.currentConfigurations.Set(currentConfigurations)
+	server.diagnostics.Set(&diagnostics{Conflicts: []*routeConflict{}})
+	server.splits.Set(map[frontendRef]*middlewares.WeightedSplit{})
+	server.rateLimits.Set(map[frontendRef]*frontendRateLimit{})
 	server.globalConfiguration = globalConfiguration
 	server.loggerMiddleware = middlewares.NewLogger(globalConfiguration.AccessLogsFile)
 	server.routinesPool = safe.NewPool(context.Background())
@@ -87,6 +103,19 @@ func NewServer(globalConfiguration GlobalConfiguration) *Server {
 		// leadership creation if cluster mode
 		server.leadership = cluster.NewLeadership(server.routinesPool.Ctx(), globalConfiguration.Cluster)
 	}
+	if globalConfiguration.OCSPStapling != nil {
+		var gauge kitmetrics.Gauge
+		if globalConfiguration.Web != nil && globalConfiguration.Web.Metrics != nil && globalConfiguration.Web.Metrics.Prometheus != nil {
+			gauge = middlewares.NewPrometheusOCSPGauge()
+		}
+		ocspStapler, err := newOCSPStapler(globalConfiguration.OCSPStapling, gauge)
+		if err != nil {
+			log.Errorf("Error enabling OCSP stapling: %v", err)
+		} else {
+			ocspStapler.served = server.servedCertificates
+			server.ocspStapler = ocspStapler
+		}
+	}
 
 	return server
 }
@@ -101,6 +130,9 @@ func (server *Server) Start() {
 	server.routinesPool.Go(func(stop chan bool) {
 		server.listenConfigurations(stop)
 	})
+	if server.ocspStapler != nil {
+		server.routinesPool.Go(server.ocspStapler.run)
+	}
 	server.configureProviders()
 	server.startProviders()
 	go server.listenSignals()
@@ -184,6 +216,9 @@ func (server *Server) startHTTPServers() {
 			statsRecorder = middlewares.NewStatsRecorder(server.globalConfiguration.Web.Statistics.RecentErrors)
 			serverMiddlewares = append(serverMiddlewares, statsRecorder)
 		}
+		if server.globalConfiguration.ACME != nil && server.globalConfiguration.ACME.HTTPChallenge != nil && server.globalConfiguration.ACME.HTTPChallenge.EntryPoint == newServerEntryPointName {
+			serverMiddlewares = append(serverMiddlewares, acmeHTTPChallengeHandler(server.globalConfiguration.ACME))
+		}
 		if server.globalConfiguration.EntryPoints[newServerEntryPointName].Auth != nil {
 			authMiddleware, err := middlewares.NewAuthenticator(server.globalConfiguration.EntryPoints[newServerEntryPointName].Auth)
 			if err != nil {
@@ -219,7 +254,7 @@ func (server *Server) listenProviders(stop chan bool) {
 			currentConfigurations := server.currentConfigurations.Get().(configs)
 			jsonConf, _ := json.Marshal(configMsg.Configuration)
 			log.Debugf("Configuration received from provider %s: %s", configMsg.ProviderName, string(jsonConf))
-			if configMsg.Configuration == nil || configMsg.Configuration.Backends == nil && configMsg.Configuration.Frontends == nil {
+			if configMsg.Configuration == nil || configMsg.Configuration.Backends == nil && configMsg.Configuration.Frontends == nil && configMsg.Configuration.TLS == nil {
 				log.Infof("Skipping empty Configuration for provider %s", configMsg.ProviderName)
 			} else if reflect.DeepEqual(currentConfigurations[configMsg.ProviderName], configMsg.Configuration) {
 				log.Infof("Skipping same configuration for provider %s", configMsg.ProviderName)
@@ -280,9 +315,13 @@ func (server *Server) listenConfigurations(stop chan bool) {
 			if err == nil {
 				for newServerEntryPointName, newServerEntryPoint := range newServerEntryPoints {
 					server.serverEntryPoints[newServerEntryPointName].httpRouter.UpdateHandler(newServerEntryPoint.httpRouter.GetHandler())
+					server.serverEntryPoints[newServerEntryPointName].certificates.set(newServerEntryPoint.certificates.get())
 					log.Infof("Server configuration reloaded on %s", server.serverEntryPoints[newServerEntryPointName].httpServer.Addr)
 				}
 				server.currentConfigurations.Set(newConfigurations)
+				if server.ocspStapler != nil {
+					server.ocspStapler.update()
+				}
 				server.postLoadConfig()
 			} else {
 				log.Error("Error loading new configuration, aborted ", err)
@@ -416,25 +455,18 @@ func (server *Server) createTLSConfig(entryPointName string, tlsOption *TLS, rou
 	// ensure http2 enabled
 	config.NextProtos = []string{"h2", "http/1.1"}
 
-	if len(tlsOption.ClientCAFiles) > 0 {
-		pool := x509.NewCertPool()
-		for _, caFile := range tlsOption.ClientCAFiles {
-			data, err := ioutil.ReadFile(caFile)
-			if err != nil {
-				return nil, err
-			}
-			ok := pool.AppendCertsFromPEM(data)
-			if !ok {
-				return nil, errors.New("invalid certificate(s) in " + caFile)
-			}
-		}
-		config.ClientCAs = pool
-		config.ClientAuth = tls.RequireAndVerifyClientCert
+	if err := configureClientAuth(config, tlsOption.defaultProfile()); err != nil {
+		return nil, err
 	}
 
 	if server.globalConfiguration.ACME != nil {
 		if _, ok := server.serverEntryPoints[server.globalConfiguration.ACME.EntryPoint]; ok {
 			if entryPointName == server.globalConfiguration.ACME.EntryPoint {
+				if httpChallenge := server.globalConfiguration.ACME.HTTPChallenge; httpChallenge != nil {
+					if _, ok := server.serverEntryPoints[httpChallenge.EntryPoint]; !ok {
+						return nil, errors.New("Unknown entrypoint " + httpChallenge.EntryPoint + " for ACME HTTP challenge")
+					}
+				}
 				checkOnDemandDomain := func(domain string) bool {
 					routeMatch := &mux.RouteMatch{}
 					router := router.GetHandler()
@@ -444,13 +476,14 @@ func (server *Server) createTLSConfig(entryPointName string, tlsOption *TLS, rou
 					}
 					return false
 				}
+				mustStaple := server.globalConfiguration.OCSPStapling != nil && server.globalConfiguration.OCSPStapling.MustStaple
 				if server.leadership == nil {
-					err := server.globalConfiguration.ACME.CreateLocalConfig(config, checkOnDemandDomain)
+					err := server.globalConfiguration.ACME.CreateLocalConfig(config, checkOnDemandDomain, mustStaple)
 					if err != nil {
 						return nil, err
 					}
 				} else {
-					err := server.globalConfiguration.ACME.CreateClusterConfig(server.leadership, config, checkOnDemandDomain)
+					err := server.globalConfiguration.ACME.CreateClusterConfig(server.leadership, config, checkOnDemandDomain, mustStaple)
 					if err != nil {
 						return nil, err
 					}
@@ -460,29 +493,49 @@ func (server *Server) createTLSConfig(entryPointName string, tlsOption *TLS, rou
 			return nil, errors.New("Unknown entrypoint " + server.globalConfiguration.ACME.EntryPoint + " for ACME configuration")
 		}
 	}
-	if len(config.Certificates) == 0 {
+	// the certificates delivered by the providers take precedence
+	if serverEntryPoint, ok := server.serverEntryPoints[entryPointName]; ok {
+		config.GetCertificate = serverEntryPoint.certificates.getCertificate(config.GetCertificate)
+	}
+	if len(config.Certificates) == 0 && config.GetCertificate == nil {
 		return nil, errors.New("No certificates found for TLS entrypoint " + entryPointName)
 	}
 	// BuildNameToCertificate parses the CommonName and SubjectAlternateName fields
 	// in each certificate and populates the config.NameToCertificate map.
 	config.BuildNameToCertificate()
-	//Set the minimum TLS version if set in the config TOML
-	if minConst, exists := minVersion[server.globalConfiguration.EntryPoints[entryPointName].TLS.MinVersion]; exists {
-		config.PreferServerCipherSuites = true
-		config.MinVersion = minConst
-	}
-	//Set the list of CipherSuites if set in the config TOML
-	if server.globalConfiguration.EntryPoints[entryPointName].TLS.CipherSuites != nil {
-		//if our list of CipherSuites is defined in the entrypoint config, we can re-initilize the suites list as empty
-		config.CipherSuites = make([]uint16, 0)
-		for _, cipher := range server.globalConfiguration.EntryPoints[entryPointName].TLS.CipherSuites {
-			if cipherConst, exists := cipherSuites[cipher]; exists {
-				config.CipherSuites = append(config.CipherSuites, cipherConst)
-			} else {
-				//CipherSuite listed in the toml does not exist in our listed
-				return nil, errors.New("Invalid CipherSuite: " + cipher)
-			}
+	// the default certificate replaces the first certificate for the unknown
+	// server names, and the stapled certificates are all served by GetCertificate
+	if tlsOption.DefaultCertificate != nil || tlsOption.StrictSNI || server.ocspStapler != nil {
+		var rejected kitmetrics.Counter
+		if server.globalConfiguration.Web != nil && server.globalConfiguration.Web.Metrics != nil && server.globalConfiguration.Web.Metrics.Prometheus != nil {
+			rejected = middlewares.NewPrometheusTLSRejectedCounter()
 		}
+		sniCertificates, err := newSNICertificates(entryPointName, tlsOption, config, rejected)
+		if err != nil {
+			return nil, err
+		}
+		config.GetCertificate = sniCertificates.getCertificate
+		// so that the handshakes without server name don't fall back to the
+		// first certificate either
+		config.Certificates = nil
+		if serverEntryPoint, ok := server.serverEntryPoints[entryPointName]; ok {
+			serverEntryPoint.tlsCertificates = sniCertificates.served()
+		}
+	}
+	if server.ocspStapler != nil {
+		config.GetCertificate = server.ocspStapler.getCertificate(config.GetCertificate)
+		server.ocspStapler.update()
+	}
+	if err := configureCipherSuites(config, tlsOption.defaultProfile()); err != nil {
+		return nil, err
+	}
+	// the TLS profiles and the client authentication apply to some server names
+	if tlsOption.selectsServerNameConfigs() {
+		profileConfigs, err := serverNameConfigs(config, tlsOption)
+		if err != nil {
+			return nil, err
+		}
+		config.GetConfigForClient = getConfigForClient(tlsOption, profileConfigs)
 	}
 	return config, nil
 }
@@ -507,6 +560,9 @@ func (server *Server) prepareServer(entryPointName string, router *middlewares.H
 	for _, middleware := range middlewares {
 		negroni.Use(middleware)
 	}
+	if entryPoint.TLS != nil && entryPoint.TLS.selectsServerNameConfigs() {
+		negroni.Use(serverNameConfigHandler(entryPoint.TLS))
+	}
 	negroni.UseHandler(router)
 	tlsConfig, err := server.createTLSConfig(entryPointName, entryPoint.TLS, router)
 	if err != nil {
@@ -527,7 +583,8 @@ func (server *Server) buildEntryPoints(globalConfiguration GlobalConfiguration)
 	for entryPointName := range globalConfiguration.EntryPoints {
 		router := server.buildDefaultHTTPRouter()
 		serverEntryPoints[entryPointName] = &serverEntryPoint{
-			httpRouter: middlewares.NewHandlerSwitcher(router),
+			httpRouter:   middlewares.NewHandlerSwitcher(router),
+			certificates: newCertificateStore(),
 		}
 	}
 	return serverEntryPoints
@@ -541,12 +598,29 @@ func (server *Server) loadConfig(configurations configs, globalConfiguration Glo
 	backends := map[string]http.Handler{}
 	backendsHealthcheck := map[string]*healthcheck.BackendHealthCheck{}
 	backend2FrontendMap := map[string]string{}
+	splits := map[frontendRef]*middlewares.WeightedSplit{}
+	rateLimits := map[frontendRef]*frontendRateLimit{}
+
+	trustedIPs := map[string]*whitelist.IP{}
+	for entryPointName, entryPoint := range globalConfiguration.EntryPoints {
+		ips, err := entryPointTrustedIPs(entryPoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted IPs for entrypoint %s: %v", entryPointName, err)
+		}
+		trustedIPs[entryPointName] = ips
+	}
+
+	conflicts, rejected := analyzeRouteConflicts(configurations, globalConfiguration)
 
-	for _, configuration := range configurations {
+	for providerName, configuration := range configurations {
 		frontendNames := sortedFrontendNamesForConfig(configuration)
 	frontend:
 		for _, frontendName := range frontendNames {
 			frontend := configuration.Frontends[frontendName]
+			if rejected.isRejectedEverywhere(frontendRef{Provider: providerName, Frontend: frontendName}, frontend) {
+				log.Errorf("Skipping conflicting frontend %s from provider %s...", frontendName, providerName)
+				continue frontend
+			}
 
 			log.Debugf("Creating frontend %s", frontendName)
 
@@ -563,7 +637,29 @@ func (server *Server) loadConfig(configurations configs, globalConfiguration Glo
 				continue frontend
 			}
 
+			frontendMiddlewares, err := server.loadFrontendMiddlewares(frontendRef{Provider: providerName, Frontend: frontendName}, frontend, configuration, backendsHealthcheck, rateLimits)
+			if err != nil {
+				log.Errorf("Error creating middlewares for frontend %s: %v", frontendName, err)
+				log.Errorf("Skipping frontend %s...", frontendName)
+				continue frontend
+			}
+
+			var split *middlewares.WeightedSplit
+			if frontend.Split != nil && len(frontend.Split.Backends) > 0 {
+				split, err = server.loadFrontendSplit(providerName, frontendName, frontend.Split)
+				if err != nil {
+					log.Errorf("Error creating split for frontend %s: %v", frontendName, err)
+					log.Errorf("Skipping frontend %s...", frontendName)
+					continue frontend
+				}
+				splits[frontendRef{Provider: providerName, Frontend: frontendName}] = split
+			}
+
 			for _, entryPointName := range frontend.EntryPoints {
+				if rejected.isRejected(frontendRef{Provider: providerName, Frontend: frontendName}, entryPointName) {
+					log.Errorf("Skipping conflicting frontend %s from provider %s on entrypoint %s...", frontendName, providerName, entryPointName)
+					continue
+				}
 				log.Debugf("Wiring frontend %s to entryPoint %s", frontendName, entryPointName)
 				if _, ok := serverEntryPoints[entryPointName]; !ok {
 					log.Errorf("Undefined entrypoint '%s' for frontend %s", entryPointName, frontendName)
@@ -571,9 +667,9 @@ func (server *Server) loadConfig(configurations configs, globalConfiguration Glo
 					continue frontend
 				}
 
-				newServerRoute := &serverRoute{route: serverEntryPoints[entryPointName].httpRouter.GetHandler().NewRoute().Name(frontendName)}
+				newServerRoute := &serverRoute{route: serverEntryPoints[entryPointName].httpRouter.GetHandler().NewRoute().Name(frontendRef{Provider: providerName, Frontend: frontendName}.routeName())}
 				for routeName, route := range frontend.Routes {
-					err := getRoute(newServerRoute, &route)
+					err := getRoute(newServerRoute, &route, trustedIPs[entryPointName])
 					if err != nil {
 						log.Errorf("Error creating route for frontend %s: %v", frontendName, err)
 						log.Errorf("Skipping frontend %s...", frontendName)
@@ -583,170 +679,67 @@ func (server *Server) loadConfig(configurations configs, globalConfiguration Glo
 				}
 
 				entryPoint := globalConfiguration.EntryPoints[entryPointName]
-				negroni := negroni.New()
-				if entryPoint.Redirect != nil {
-					if redirectHandlers[entryPointName] != nil {
-						negroni.Use(redirectHandlers[entryPointName])
-					} else if handler, err := server.loadEntryPointConfig(entryPointName, entryPoint); err != nil {
+				if entryPoint.Redirect != nil && redirectHandlers[entryPointName] == nil {
+					handler, err := server.loadEntryPointConfig(entryPointName, entryPoint)
+					if err != nil {
 						log.Errorf("Error loading entrypoint configuration for frontend %s: %v", frontendName, err)
 						log.Errorf("Skipping frontend %s...", frontendName)
 						continue frontend
-					} else {
-						negroni.Use(handler)
-						redirectHandlers[entryPointName] = handler
 					}
+					redirectHandlers[entryPointName] = handler
 				}
-				if backends[entryPointName+frontend.Backend] == nil {
-					log.Debugf("Creating backend %s", frontend.Backend)
-					var lb http.Handler
-					rr, _ := roundrobin.New(saveBackend)
-					if configuration.Backends[frontend.Backend] == nil {
-						log.Errorf("Undefined backend '%s' for frontend %s", frontend.Backend, frontendName)
-						log.Errorf("Skipping frontend %s...", frontendName)
-						continue frontend
-					}
-
-					lbMethod, err := types.NewLoadBalancerMethod(configuration.Backends[frontend.Backend].LoadBalancer)
-					if err != nil {
-						log.Errorf("Error loading load balancer method '%+v' for frontend %s: %v", configuration.Backends[frontend.Backend].LoadBalancer, frontendName, err)
-						log.Errorf("Skipping frontend %s...", frontendName)
-						continue frontend
-					}
-
-					stickysession := configuration.Backends[frontend.Backend].LoadBalancer.Sticky
-					cookiename := "_TRAEFIK_BACKEND"
-					var sticky *roundrobin.StickySession
-
-					if stickysession {
-						sticky = roundrobin.NewStickySession(cookiename)
-					}
-
-					switch lbMethod {
-					case types.Drr:
-						log.Debugf("Creating load-balancer drr")
-						rebalancer, _ := roundrobin.NewRebalancer(rr, roundrobin.RebalancerLogger(oxyLogger))
-						if stickysession {
-							log.Debugf("Sticky session with cookie %v", cookiename)
-							rebalancer, _ = roundrobin.NewRebalancer(rr, roundrobin.RebalancerLogger(oxyLogger), roundrobin.RebalancerStickySession(sticky))
-						}
-						lb = rebalancer
-						for serverName, server := range configuration.Backends[frontend.Backend].Servers {
-							url, err := url.Parse(server.URL)
-							if err != nil {
-								log.Errorf("Error parsing server URL %s: %v", server.URL, err)
-								log.Errorf("Skipping frontend %s...", frontendName)
-								continue frontend
-							}
-							backend2FrontendMap[url.String()] = frontendName
-							log.Debugf("Creating server %s at %s with weight %d", serverName, url.String(), server.Weight)
-							if err := rebalancer.UpsertServer(url, roundrobin.Weight(server.Weight)); err != nil {
-								log.Errorf("Error adding server %s to load balancer: %v", server.URL, err)
-								log.Errorf("Skipping frontend %s...", frontendName)
-								continue frontend
-							}
-							hcOpts := parseHealthCheckOptions(rebalancer, frontend.Backend, configuration.Backends[frontend.Backend].HealthCheck, globalConfiguration.HealthCheck)
-							if hcOpts != nil {
-								log.Debugf("Setting up backend health check %s", *hcOpts)
-								backendsHealthcheck[frontend.Backend] = healthcheck.NewBackendHealthCheck(*hcOpts)
-							}
-						}
-					case types.Wrr:
-						log.Debugf("Creating load-balancer wrr")
-						if stickysession {
-							log.Debugf("Sticky session with cookie %v", cookiename)
-							rr, _ = roundrobin.New(saveBackend, roundrobin.EnableStickySession(sticky))
-						}
-						lb = rr
-						for serverName, server := range configuration.Backends[frontend.Backend].Servers {
-							url, err := url.Parse(server.URL)
-							if err != nil {
-								log.Errorf("Error parsing server URL %s: %v", server.URL, err)
-								log.Errorf("Skipping frontend %s...", frontendName)
-								continue frontend
-							}
-							backend2FrontendMap[url.String()] = frontendName
-							log.Debugf("Creating server %s at %s with weight %d", serverName, url.String(), server.Weight)
-							if err := rr.UpsertServer(url, roundrobin.Weight(server.Weight)); err != nil {
-								log.Errorf("Error adding server %s to load balancer: %v", server.URL, err)
-								log.Errorf("Skipping frontend %s...", frontendName)
-								continue frontend
-							}
-						}
-						hcOpts := parseHealthCheckOptions(rr, frontend.Backend, configuration.Backends[frontend.Backend].HealthCheck, globalConfiguration.HealthCheck)
-						if hcOpts != nil {
-							log.Debugf("Setting up backend health check %s", *hcOpts)
-							backendsHealthcheck[frontend.Backend] = healthcheck.NewBackendHealthCheck(*hcOpts)
-						}
-					}
-					maxConns := configuration.Backends[frontend.Backend].MaxConn
-					if maxConns != nil && maxConns.Amount != 0 {
-						extractFunc, err := utils.NewExtractor(maxConns.ExtractorFunc)
-						if err != nil {
-							log.Errorf("Error creating connlimit: %v", err)
+				for _, backendName := range frontendBackendNames(frontend) {
+					if backends[entryPointName+backendName] == nil {
+						backend := configuration.Backends[backendName]
+						if backend == nil {
+							log.Errorf("Undefined backend '%s' for frontend %s", backendName, frontendName)
 							log.Errorf("Skipping frontend %s...", frontendName)
 							continue frontend
 						}
-						log.Debugf("Creating load-balancer connlimit")
-						lb, err = connlimit.New(lb, extractFunc, maxConns.Amount, connlimit.Logger(oxyLogger))
+						log.Debugf("Creating backend %s", backendName)
+						lb, err := server.buildBackendHandler(backendName, backend, saveBackend, backendName, backendsHealthcheck)
 						if err != nil {
-							log.Errorf("Error creating connlimit: %v", err)
+							log.Errorf("Error creating backend %s for frontend %s: %v", backendName, frontendName, err)
 							log.Errorf("Skipping frontend %s...", frontendName)
 							continue frontend
 						}
-					}
-					// retry ?
-					if globalConfiguration.Retry != nil {
-						retries := len(configuration.Backends[frontend.Backend].Servers)
-						if globalConfiguration.Retry.Attempts > 0 {
-							retries = globalConfiguration.Retry.Attempts
-						}
-						lb = middlewares.NewRetry(retries, lb)
-						log.Debugf("Creating retries max attempts %d", retries)
-					}
-
-					if server.globalConfiguration.Web != nil && server.globalConfiguration.Web.Metrics != nil {
-						if server.globalConfiguration.Web.Metrics.Prometheus != nil {
-							metricsMiddlewareBackend := middlewares.NewMetricsWrapper(middlewares.NewPrometheus(frontend.Backend, server.globalConfiguration.Web.Metrics.Prometheus))
-							negroni.Use(metricsMiddlewareBackend)
-						}
-					}
-					if len(frontend.BasicAuth) > 0 {
-						users := types.Users{}
-						for _, user := range frontend.BasicAuth {
-							users = append(users, user)
-						}
-
-						auth := &types.Auth{}
-						auth.Basic = &types.Basic{
-							Users: users,
-						}
-						authMiddleware, err := middlewares.NewAuthenticator(auth)
-						if err != nil {
-							log.Errorf("Error creating Auth: %s", err)
-						} else {
-							negroni.Use(authMiddleware)
+						for _, backendServer := range backend.Servers {
+							if url, err := url.Parse(backendServer.URL); err == nil {
+								backend2FrontendMap[url.String()] = frontendName
+							}
 						}
-					}
-					if configuration.Backends[frontend.Backend].CircuitBreaker != nil {
-						log.Debugf("Creating circuit breaker %s", configuration.Backends[frontend.Backend].CircuitBreaker.Expression)
-						cbreaker, err := middlewares.NewCircuitBreaker(lb, configuration.Backends[frontend.Backend].CircuitBreaker.Expression, cbreaker.Logger(oxyLogger))
-						if err != nil {
-							log.Errorf("Error creating circuit breaker: %v", err)
-							log.Errorf("Skipping frontend %s...", frontendName)
-							continue frontend
+						negroni := negroni.New()
+						if redirectHandlers[entryPointName] != nil {
+							negroni.Use(redirectHandlers[entryPointName])
 						}
-						negroni.Use(cbreaker)
-					} else {
 						negroni.UseHandler(lb)
+						backends[entryPointName+backendName] = negroni
+					} else {
+						log.Debugf("Reusing backend %s", backendName)
 					}
-					backends[entryPointName+frontend.Backend] = negroni
-				} else {
-					log.Debugf("Reusing backend %s", frontend.Backend)
 				}
 				if frontend.Priority > 0 {
 					newServerRoute.route.Priority(frontend.Priority)
 				}
-				server.wireFrontendBackend(newServerRoute, backends[entryPointName+frontend.Backend])
+				var handler http.Handler = backends[entryPointName+frontend.Backend]
+				if split != nil {
+					splitHandlers := map[string]http.Handler{}
+					for name, weightedBackend := range frontend.Split.Backends {
+						splitHandlers[name] = backends[entryPointName+weightedBackend.Backend]
+					}
+					handler = split.Handler(splitHandlers)
+				}
+				// backends are shared between frontends, their middlewares are not
+				for i := len(frontendMiddlewares) - 1; i >= 0; i-- {
+					middleware := frontendMiddlewares[i]
+					if redirect, ok := middleware.(*middlewares.Redirect); ok {
+						// the scheme forwarded by the peers is only trusted
+						// on the entrypoints trusting them
+						middleware = redirect.WithTrustedIPs(trustedIPs[entryPointName])
+					}
+					handler = withFrontendMiddleware(middleware, handler)
+				}
+				server.wireFrontendBackend(newServerRoute, handler)
 
 				err := newServerRoute.route.GetError()
 				if err != nil {
@@ -755,15 +748,131 @@ func (server *Server) loadConfig(configurations configs, globalConfiguration Glo
 			}
 		}
 	}
+	for entryPointName, serverEntryPoint := range serverEntryPoints {
+		entryPoint := globalConfiguration.EntryPoints[entryPointName]
+		if entryPoint == nil || len(entryPoint.DefaultBackend) == 0 {
+			continue
+		}
+		if err := server.loadEntryPointDefaultBackend(entryPointName, serverEntryPoint, entryPoint.DefaultBackend, configurations, backendsHealthcheck); err != nil {
+			log.Errorf("Error loading default backend %s for entrypoint %s: %v", entryPoint.DefaultBackend, entryPointName, err)
+		}
+	}
 	healthcheck.GetHealthCheck().SetBackendsConfiguration(server.routinesPool.Ctx(), backendsHealthcheck)
 	middlewares.SetBackend2FrontendMap(&backend2FrontendMap)
 	//sort routes
 	for _, serverEntryPoint := range serverEntryPoints {
 		serverEntryPoint.httpRouter.GetHandler().SortRoutes()
 	}
+	loadProvidedCertificates(configurations, globalConfiguration, serverEntryPoints)
+	server.diagnostics.Set(&diagnostics{Conflicts: conflicts})
+	server.splits.Set(splits)
+	server.rateLimits.Set(rateLimits)
 	return serverEntryPoints, nil
 }
 
+// getFrontendAuth returns the auth configuration of a frontend, built from its
+// deprecated basicAuth users when it has no auth.
+func getFrontendAuth(frontendName string, frontend *types.Frontend) *types.Auth {
+	if frontend.Auth != nil {
+		return frontend.Auth
+	}
+	users := types.Users{}
+	for _, user := range frontend.BasicAuth {
+		if len(user) > 0 {
+			users = append(users, user)
+		}
+	}
+	if len(users) == 0 {
+		return nil
+	}
+	log.Warnf("basicAuth of frontend %s is deprecated, use auth.basic instead", frontendName)
+	return &types.Auth{Basic: &types.Basic{Users: users}}
+}
+
+// frontendBackendNames returns the names of the backends of a frontend: the
+// backends of its split if it has one, or its backend.
+func frontendBackendNames(frontend *types.Frontend) []string {
+	if frontend.Split == nil || len(frontend.Split.Backends) == 0 {
+		return []string{frontend.Backend}
+	}
+	var backendNames []string
+	seen := map[string]bool{}
+	for _, weightedBackend := range frontend.Split.Backends {
+		if !seen[weightedBackend.Backend] {
+			seen[weightedBackend.Backend] = true
+			backendNames = append(backendNames, weightedBackend.Backend)
+		}
+	}
+	sort.Strings(backendNames)
+	return backendNames
+}
+
+// loadFrontendSplit creates the weighted split of a frontend, which keeps the
+// weights changed at runtime as long as the split configuration is unchanged.
+func (server *Server) loadFrontendSplit(providerName string, frontendName string, split *types.Split) (*middlewares.WeightedSplit, error) {
+	cookieHash := fnv.New32a()
+	cookieHash.Write([]byte(frontendName))
+	weightedSplit, err := middlewares.NewWeightedSplit(split, fmt.Sprintf("_TRAEFIK_SPLIT_%x", cookieHash.Sum32()))
+	if err != nil {
+		return nil, err
+	}
+
+	previous := server.frontendSplit(providerName, frontendName)
+	currentConfigurations, _ := server.currentConfigurations.Get().(configs)
+	if previous == nil || currentConfigurations[providerName] == nil {
+		return weightedSplit, nil
+	}
+	if frontend := currentConfigurations[providerName].Frontends[frontendName]; frontend != nil && reflect.DeepEqual(frontend.Split, split) {
+		if err := weightedSplit.SetWeights(previous.Weights()); err != nil {
+			log.Warnf("Error restoring the weights of frontend %s: %v", frontendName, err)
+		}
+	}
+	return weightedSplit, nil
+}
+
+// frontendRateLimit is the rate limit of a frontend along with its settings
+type frontendRateLimit struct {
+	config    *types.RateLimit
+	rateLimit *middlewares.RateLimit
+}
+
+// loadFrontendRateLimit returns the rate limit of the frontend, which is the
+// one of the previous configuration when its settings didn't change, so that
+// the buckets of the clients are not reset by the reloads.
+func (server *Server) loadFrontendRateLimit(ref frontendRef, config *types.RateLimit, rateLimits map[frontendRef]*frontendRateLimit) (*middlewares.RateLimit, error) {
+	previous, _ := server.rateLimits.Get().(map[frontendRef]*frontendRateLimit)
+	if previous := previous[ref]; previous != nil && reflect.DeepEqual(previous.config, config) {
+		rateLimits[ref] = previous
+		return previous.rateLimit, nil
+	}
+
+	var rejected kitmetrics.Counter
+	if server.globalConfiguration.Web != nil && server.globalConfiguration.Web.Metrics != nil && server.globalConfiguration.Web.Metrics.Prometheus != nil {
+		rejected = middlewares.NewPrometheusRateLimitCounter().With("frontend", ref.Frontend)
+	}
+	rateLimit, err := middlewares.NewRateLimit(config, rejected)
+	if err != nil {
+		return nil, fmt.Errorf("error creating rate limit: %v", err)
+	}
+	log.Debugf("Creating rate limit for frontend %s", ref.Frontend)
+	rateLimits[ref] = &frontendRateLimit{config: config, rateLimit: rateLimit}
+	return rateLimit, nil
+}
+
+// frontendSplit returns the weighted split of a frontend, or nil if it has none
+func (server *Server) frontendSplit(providerName string, frontendName string) *middlewares.WeightedSplit {
+	splits, _ := server.splits.Get().(map[frontendRef]*middlewares.WeightedSplit)
+	return splits[frontendRef{Provider: providerName, Frontend: frontendName}]
+}
+
+// withFrontendMiddleware chains a middleware specific to a frontend with the
+// handler of its backend.
+func withFrontendMiddleware(middleware negroni.Handler, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		middleware.ServeHTTP(rw, r, handler.ServeHTTP)
+	})
+}
+
 func (server *Server) wireFrontendBackend(serverRoute *serverRoute, handler http.Handler) {
 	// path replace - This needs to always be the very last on the handler chain (first in the order in this function)
 	// -- Replacing Path should happen at the very end of the Modifier chain, after all the Matcher+Modifiers ran
@@ -804,19 +913,11 @@ func (server *Server) loadEntryPointConfig(entryPointName string, entryPoint *En
 	replacement := entryPoint.Redirect.Replacement
 	if len(entryPoint.Redirect.EntryPoint) > 0 {
 		regex = "^(?:https?:\\/\\/)?([\\w\\._-]+)(?::\\d+)?(.*)$"
-		if server.globalConfiguration.EntryPoints[entryPoint.Redirect.EntryPoint] == nil {
-			return nil, errors.New("Unknown entrypoint " + entryPoint.Redirect.EntryPoint)
-		}
-		protocol := "http"
-		if server.globalConfiguration.EntryPoints[entryPoint.Redirect.EntryPoint].TLS != nil {
-			protocol = "https"
-		}
-		r, _ := regexp.Compile("(:\\d+)")
-		match := r.FindStringSubmatch(server.globalConfiguration.EntryPoints[entryPoint.Redirect.EntryPoint].Address)
-		if len(match) == 0 {
-			return nil, errors.New("Bad Address format: " + server.globalConfiguration.EntryPoints[entryPoint.Redirect.EntryPoint].Address)
+		base, err := server.entryPointBaseURL(entryPoint.Redirect.EntryPoint)
+		if err != nil {
+			return nil, err
 		}
-		replacement = protocol + "://$1" + match[0] + "$2"
+		replacement = base + "$2"
 	}
 	rewrite, err := middlewares.NewRewrite(regex, replacement, true)
 	if err != nil {
@@ -827,6 +928,292 @@ func (server *Server) loadEntryPointConfig(entryPointName string, entryPoint *En
 	return rewrite, nil
 }
 
+// loadEntryPointDefaultBackend makes the backend, looked up by name in the
+// configurations of the providers, handle the requests matched by no frontend.
+func (server *Server) loadEntryPointDefaultBackend(entryPointName string, serverEntryPoint *serverEntryPoint, backendName string, configurations configs, backendsHealthcheck map[string]*healthcheck.BackendHealthCheck) error {
+	var providerNames []string
+	for providerName := range configurations {
+		providerNames = append(providerNames, providerName)
+	}
+	sort.Strings(providerNames)
+	for _, providerName := range providerNames {
+		backend := configurations[providerName].Backends[backendName]
+		if backend == nil {
+			continue
+		}
+		fwd, err := forward.New(forward.Logger(oxyLogger), forward.PassHostHeader(true))
+		if err != nil {
+			return err
+		}
+		handler, err := server.buildBackendHandler(backendName, backend, fwd, entryPointName+"/defaultBackend", backendsHealthcheck)
+		if err != nil {
+			return err
+		}
+		serverEntryPoint.httpRouter.GetHandler().NotFoundHandler = handler
+		return nil
+	}
+	return errors.New("undefined backend")
+}
+
+// loadFrontendMiddlewares returns the middlewares of a frontend which are not
+// shared with the other frontends of its backend, in order.
+func (server *Server) loadFrontendMiddlewares(ref frontendRef, frontend *types.Frontend, configuration *types.Configuration, backendsHealthcheck map[string]*healthcheck.BackendHealthCheck, rateLimits map[frontendRef]*frontendRateLimit) ([]negroni.Handler, error) {
+	frontendName := ref.Frontend
+	// the error pages and mirrors forward the requests as the frontend does
+	fwd, err := forward.New(forward.Logger(oxyLogger), forward.PassHostHeader(frontend.PassHostHeader))
+	if err != nil {
+		return nil, fmt.Errorf("error creating forwarder: %v", err)
+	}
+	var handlers []negroni.Handler
+	if frontend.PassTLSClientCert != nil {
+		// first so that the headers of the clients are removed before any use
+		handlers = append(handlers, middlewares.NewPassTLSClientCert(frontend.PassTLSClientCert))
+	}
+	if frontend.RateLimit != nil {
+		rateLimit, err := server.loadFrontendRateLimit(ref, frontend.RateLimit, rateLimits)
+		if err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, rateLimit)
+	}
+	if frontend.Redirect != nil {
+		redirect, err := server.loadFrontendRedirect(frontendName, frontend.Redirect)
+		if err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, redirect)
+	}
+	if frontend.Headers.HasCustomHeadersDefined() || frontend.Headers.HasSecureHeadersDefined() {
+		// outside of the error pages so their responses get the headers too
+		handlers = append(handlers, middlewares.NewHeaders(frontend.Headers))
+	}
+	if frontendAuth := getFrontendAuth(frontendName, frontend); frontendAuth != nil {
+		// before the mirror, so that only the authenticated requests are mirrored
+		authMiddleware, err := middlewares.NewAuthenticator(frontendAuth)
+		if err != nil {
+			return nil, fmt.Errorf("error creating auth: %v", err)
+		}
+		handlers = append(handlers, authMiddleware)
+	}
+	if frontend.Mirror != nil && len(frontend.Mirror.Backends) > 0 {
+		mirror, err := server.loadFrontendMirror(frontendName, frontend.Mirror, configuration, fwd, backendsHealthcheck)
+		if err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, mirror)
+	}
+
+	var errorPageNames []string
+	for errorPageName := range frontend.Errors {
+		errorPageNames = append(errorPageNames, errorPageName)
+	}
+	sort.Strings(errorPageNames)
+	for _, errorPageName := range errorPageNames {
+		errorPage := frontend.Errors[errorPageName]
+		backend := configuration.Backends[errorPage.Backend]
+		if backend == nil {
+			return nil, fmt.Errorf("undefined backend '%s' for error page %s", errorPage.Backend, errorPageName)
+		}
+		backendHandler, err := server.buildBackendHandler(errorPage.Backend, backend, fwd, frontendName+"/errors/"+errorPageName, backendsHealthcheck)
+		if err != nil {
+			return nil, fmt.Errorf("error creating backend %s for error page %s: %v", errorPage.Backend, errorPageName, err)
+		}
+		errorPages, err := middlewares.NewErrorPagesHandler(errorPage, backendHandler)
+		if err != nil {
+			return nil, fmt.Errorf("error creating error page %s: %v", errorPageName, err)
+		}
+		log.Debugf("Creating error page %s for frontend %s with backend %s", errorPageName, frontendName, errorPage.Backend)
+		handlers = append(handlers, errorPages)
+	}
+	return handlers, nil
+}
+
+func (server *Server) loadFrontendMirror(frontendName string, mirror *types.Mirror, configuration *types.Configuration, fwd http.Handler, backendsHealthcheck map[string]*healthcheck.BackendHealthCheck) (negroni.Handler, error) {
+	mirrorHandlers := map[string]http.Handler{}
+	for mirrorName, mirrorBackend := range mirror.Backends {
+		backend := configuration.Backends[mirrorBackend.Backend]
+		if backend == nil {
+			return nil, fmt.Errorf("undefined backend '%s' for mirror %s", mirrorBackend.Backend, mirrorName)
+		}
+		backendHandler, err := server.buildBackendHandler(mirrorBackend.Backend, backend, fwd, frontendName+"/mirror/"+mirrorName, backendsHealthcheck)
+		if err != nil {
+			return nil, fmt.Errorf("error creating backend %s for mirror %s: %v", mirrorBackend.Backend, mirrorName, err)
+		}
+		log.Debugf("Creating mirror %s for frontend %s with backend %s and percent %d", mirrorName, frontendName, mirrorBackend.Backend, mirrorBackend.Percent)
+		mirrorHandlers[mirrorName] = backendHandler
+	}
+
+	var mirrored, dropped kitmetrics.Counter
+	if server.globalConfiguration.Web != nil && server.globalConfiguration.Web.Metrics != nil && server.globalConfiguration.Web.Metrics.Prometheus != nil {
+		mirrored, dropped = middlewares.NewPrometheusMirrorCounters()
+		mirrored = mirrored.With("frontend", frontendName)
+		dropped = dropped.With("frontend", frontendName)
+	}
+	handler, err := middlewares.NewMirror(mirror, mirrorHandlers, mirrored, dropped)
+	if err != nil {
+		return nil, fmt.Errorf("error creating mirror: %v", err)
+	}
+	return handler, nil
+}
+
+// buildBackendHandler returns the load balancer of the backend, forwarding the
+// requests with fwd, along with its health check, registered with the given
+// id, its connection limit, retries, buffering, metrics and circuit breaker.
+func (server *Server) buildBackendHandler(backendName string, backend *types.Backend, fwd http.Handler, healthCheckID string, backendsHealthcheck map[string]*healthcheck.BackendHealthCheck) (http.Handler, error) {
+	globalConfiguration := server.globalConfiguration
+	lbMethod, err := types.NewLoadBalancerMethod(backend.LoadBalancer)
+	if err != nil {
+		return nil, fmt.Errorf("error loading load balancer method '%+v': %v", backend.LoadBalancer, err)
+	}
+
+	stickysession := backend.LoadBalancer.Sticky
+	cookiename := "_TRAEFIK_BACKEND"
+	var sticky *roundrobin.StickySession
+	if stickysession {
+		sticky = roundrobin.NewStickySession(cookiename)
+	}
+
+	var lb http.Handler
+	var hcLB healthcheck.LoadBalancer
+	rr, _ := roundrobin.New(fwd)
+	switch lbMethod {
+	case types.Drr:
+		log.Debugf("Creating load-balancer drr")
+		rebalancer, _ := roundrobin.NewRebalancer(rr, roundrobin.RebalancerLogger(oxyLogger))
+		if stickysession {
+			log.Debugf("Sticky session with cookie %v", cookiename)
+			rebalancer, _ = roundrobin.NewRebalancer(rr, roundrobin.RebalancerLogger(oxyLogger), roundrobin.RebalancerStickySession(sticky))
+		}
+		lb, hcLB = rebalancer, rebalancer
+		for serverName, backendServer := range backend.Servers {
+			url, err := url.Parse(backendServer.URL)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing server URL %s: %v", backendServer.URL, err)
+			}
+			log.Debugf("Creating server %s at %s with weight %d", serverName, url.String(), backendServer.Weight)
+			if err := rebalancer.UpsertServer(url, roundrobin.Weight(backendServer.Weight)); err != nil {
+				return nil, fmt.Errorf("error adding server %s to load balancer: %v", backendServer.URL, err)
+			}
+		}
+	case types.Wrr:
+		log.Debugf("Creating load-balancer wrr")
+		if stickysession {
+			log.Debugf("Sticky session with cookie %v", cookiename)
+			rr, _ = roundrobin.New(fwd, roundrobin.EnableStickySession(sticky))
+		}
+		lb, hcLB = rr, rr
+		for serverName, backendServer := range backend.Servers {
+			url, err := url.Parse(backendServer.URL)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing server URL %s: %v", backendServer.URL, err)
+			}
+			log.Debugf("Creating server %s at %s with weight %d", serverName, url.String(), backendServer.Weight)
+			if err := rr.UpsertServer(url, roundrobin.Weight(backendServer.Weight)); err != nil {
+				return nil, fmt.Errorf("error adding server %s to load balancer: %v", backendServer.URL, err)
+			}
+		}
+	}
+	hcOpts := parseHealthCheckOptions(hcLB, backendName, backend.HealthCheck, globalConfiguration.HealthCheck)
+	if hcOpts != nil {
+		log.Debugf("Setting up backend health check %s", *hcOpts)
+		backendsHealthcheck[healthCheckID] = healthcheck.NewBackendHealthCheck(*hcOpts)
+	}
+
+	maxConns := backend.MaxConn
+	if maxConns != nil && maxConns.Amount != 0 {
+		extractFunc, err := utils.NewExtractor(maxConns.ExtractorFunc)
+		if err != nil {
+			return nil, fmt.Errorf("error creating connlimit: %v", err)
+		}
+		log.Debugf("Creating load-balancer connlimit")
+		lb, err = connlimit.New(lb, extractFunc, maxConns.Amount, connlimit.Logger(oxyLogger))
+		if err != nil {
+			return nil, fmt.Errorf("error creating connlimit: %v", err)
+		}
+	}
+	// retry ?
+	if globalConfiguration.Retry != nil {
+		retries := len(backend.Servers)
+		if globalConfiguration.Retry.Attempts > 0 {
+			retries = globalConfiguration.Retry.Attempts
+		}
+		lb = middlewares.NewRetry(retries, lb)
+		log.Debugf("Creating retries max attempts %d", retries)
+	}
+	// outside of the retries so they can replay the buffered requests
+	if buffering := backend.Buffering; buffering != nil {
+		log.Debugf("Creating buffering for backend %s", backendName)
+		lb, err = middlewares.NewBuffering(buffering, lb)
+		if err != nil {
+			return nil, fmt.Errorf("error creating buffering: %v", err)
+		}
+	}
+
+	negroni := negroni.New()
+	if globalConfiguration.Web != nil && globalConfiguration.Web.Metrics != nil {
+		if globalConfiguration.Web.Metrics.Prometheus != nil {
+			metricsMiddlewareBackend := middlewares.NewMetricsWrapper(middlewares.NewPrometheus(backendName, globalConfiguration.Web.Metrics.Prometheus))
+			negroni.Use(metricsMiddlewareBackend)
+		}
+	}
+	if backend.CircuitBreaker != nil {
+		log.Debugf("Creating circuit breaker %s", backend.CircuitBreaker.Expression)
+		cbreaker, err := middlewares.NewCircuitBreaker(lb, backend.CircuitBreaker.Expression, cbreaker.Logger(oxyLogger))
+		if err != nil {
+			return nil, fmt.Errorf("error creating circuit breaker: %v", err)
+		}
+		negroni.Use(cbreaker)
+	} else {
+		negroni.UseHandler(lb)
+	}
+	return negroni, nil
+}
+
+func (server *Server) loadFrontendRedirect(frontendName string, redirect *types.Redirect) (negroni.Handler, error) {
+	regex := redirect.Regex
+	replacement := redirect.Replacement
+	if len(redirect.EntryPoint) > 0 {
+		regex = "^(?:https?:\\/\\/)?([\\w\\._-]+)(?::\\d+)?([^?]*)(.*)$"
+		base, err := server.entryPointBaseURL(redirect.EntryPoint)
+		if err != nil {
+			return nil, err
+		}
+		replacement = base + "$2$3"
+		if redirect.DropPath {
+			replacement = base + "/$3"
+		}
+		if redirect.DropQuery {
+			replacement = strings.TrimSuffix(replacement, "$3")
+		}
+	}
+	handler, err := middlewares.NewRedirect(regex, replacement, redirect.Permanent)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Creating frontend redirect %s -> %s : %s -> %s", frontendName, redirect.EntryPoint, regex, replacement)
+
+	return handler, nil
+}
+
+// entryPointBaseURL returns the scheme and port of the entrypoint, after the
+// host captured by the first group of a redirect regex.
+func (server *Server) entryPointBaseURL(entryPointName string) (string, error) {
+	entryPoint := server.globalConfiguration.EntryPoints[entryPointName]
+	if entryPoint == nil {
+		return "", errors.New("Unknown entrypoint " + entryPointName)
+	}
+	protocol := "http"
+	if entryPoint.TLS != nil {
+		protocol = "https"
+	}
+	r, _ := regexp.Compile("(:\\d+)")
+	match := r.FindStringSubmatch(entryPoint.Address)
+	if len(match) == 0 {
+		return "", errors.New("Bad Address format: " + entryPoint.Address)
+	}
+	return protocol + "://$1" + match[0], nil
+}
+
 func (server *Server) buildDefaultHTTPRouter() *mux.Router {
 	router := mux.NewRouter()
 	router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
@@ -860,8 +1247,8 @@ func parseHealthCheckOptions(lb healthcheck.LoadBalancer, backend string, hc *ty
 	}
 }
 
-func getRoute(serverRoute *serverRoute, route *types.Route) error {
-	rules := Rules{route: serverRoute}
+func getRoute(serverRoute *serverRoute, route *types.Route, trustedIPs *whitelist.IP) error {
+	rules := Rules{route: serverRoute, trustedIPs: trustedIPs}
 	newRoute, err := rules.Parse(route.Rule)
 	if err != nil {
 		return err
@@ -3,6 +3,7 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"testing"
@@ -279,6 +280,580 @@ func TestServerLoadConfigEmptyBasicAuth(t *testing.T) {
 	}
 }
 
+func TestGetFrontendAuth(t *testing.T) {
+	auth := &types.Auth{Digest: &types.Digest{Users: []string{"test:traefik:a2688e031edb4be6a3797f3882655c05"}}}
+	cases := []struct {
+		desc     string
+		frontend *types.Frontend
+		expected *types.Auth
+	}{
+		{
+			desc:     "no auth",
+			frontend: &types.Frontend{},
+		},
+		{
+			desc:     "empty basicAuth",
+			frontend: &types.Frontend{BasicAuth: []string{""}},
+		},
+		{
+			desc:     "deprecated basicAuth",
+			frontend: &types.Frontend{BasicAuth: []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"}},
+			expected: &types.Auth{Basic: &types.Basic{Users: []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"}}},
+		},
+		{
+			desc:     "auth over basicAuth",
+			frontend: &types.Frontend{BasicAuth: []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"}, Auth: auth},
+			expected: auth,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.desc, func(t *testing.T) {
+			t.Parallel()
+			if got := getFrontendAuth("frontend", c.frontend); !reflect.DeepEqual(got, c.expected) {
+				t.Errorf("got auth %+v, want %+v", got, c.expected)
+			}
+		})
+	}
+}
+
+func TestServerLoadConfigFrontendAuthSharedBackend(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer backendServer.Close()
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+	auth := &types.Auth{Basic: &types.Basic{Users: []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"}}}
+	// whichever frontend builds the shared backend first
+	for _, names := range [][2]string{{"a-private", "b-public"}, {"b-private", "a-public"}} {
+		privateName, publicName := names[0], names[1]
+		dynamicConfigs := configs{
+			"config": &types.Configuration{
+				Frontends: map[string]*types.Frontend{
+					privateName: {
+						EntryPoints: []string{"http"},
+						Backend:     "backend",
+						Routes:      map[string]types.Route{"route": {Rule: "Host:private.com"}},
+						Auth:        auth,
+					},
+					publicName: {
+						EntryPoints: []string{"http"},
+						Backend:     "backend",
+						Routes:      map[string]types.Route{"route": {Rule: "Host:public.com"}},
+					},
+				},
+				Backends: map[string]*types.Backend{
+					"backend": {
+						Servers: map[string]types.Server{
+							"server": {
+								URL: backendServer.URL,
+							},
+						},
+						LoadBalancer: &types.LoadBalancer{
+							Method: "Wrr",
+						},
+					},
+				},
+			},
+		}
+
+		srv := NewServer(globalConfig)
+		serverEntryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+		if err != nil {
+			t.Fatalf("%s: got error: %s", privateName, err)
+		}
+
+		for _, c := range []struct {
+			url            string
+			user           string
+			expectedStatus int
+		}{
+			{url: "http://private.com/", expectedStatus: http.StatusUnauthorized},
+			{url: "http://private.com/", user: "test", expectedStatus: http.StatusOK},
+			{url: "http://public.com/", expectedStatus: http.StatusOK},
+		} {
+			req := testhelpers.MustNewRequest(http.MethodGet, c.url, nil)
+			if len(c.user) > 0 {
+				req.SetBasicAuth(c.user, "test")
+			}
+			recorder := httptest.NewRecorder()
+			serverEntryPoints["http"].httpRouter.ServeHTTP(recorder, req)
+			if recorder.Code != c.expectedStatus {
+				t.Errorf("%s: %s with user %q: expected status %d, got %d", privateName, c.url, c.user, c.expectedStatus, recorder.Code)
+			}
+		}
+	}
+}
+
+func TestServerLoadConfigFrontendRedirect(t *testing.T) {
+	appServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer appServer.Close()
+
+	cases := []struct {
+		desc             string
+		redirect         *types.Redirect
+		url              string
+		remoteAddr       string
+		forwardedProto   string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			desc:             "entrypoint",
+			redirect:         &types.Redirect{EntryPoint: "https"},
+			url:              "http://foo.com/bar?baz=1",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://foo.com:443/bar?baz=1",
+		},
+		{
+			desc:             "permanent without query",
+			redirect:         &types.Redirect{EntryPoint: "https", Permanent: true, DropQuery: true},
+			url:              "http://foo.com/bar?baz=1",
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "https://foo.com:443/bar",
+		},
+		{
+			desc:             "without path",
+			redirect:         &types.Redirect{EntryPoint: "https", DropPath: true},
+			url:              "http://foo.com/bar?baz=1",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://foo.com:443/?baz=1",
+		},
+		{
+			desc:             "regex",
+			redirect:         &types.Redirect{Regex: `^http://www\.foo\.com/(.*)`, Replacement: "http://foo.com/$1", Permanent: true},
+			url:              "http://www.foo.com/bar",
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "http://foo.com/bar",
+		},
+		{
+			desc:           "scheme forwarded by a trusted peer",
+			redirect:       &types.Redirect{Regex: `^http://(.*)`, Replacement: "https://$1"},
+			url:            "http://foo.com/bar",
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedProto: "https",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:             "scheme forwarded by an untrusted peer",
+			redirect:         &types.Redirect{Regex: `^http://(.*)`, Replacement: "https://$1"},
+			url:              "http://foo.com/bar",
+			remoteAddr:       "192.168.0.1:1234",
+			forwardedProto:   "https",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://foo.com/bar",
+		},
+	}
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http":  &EntryPoint{Address: ":80", ForwardedHeaders: &ForwardedHeaders{TrustedIPs: []string{"10.0.0.0/8"}}},
+			"https": &EntryPoint{Address: ":443", TLS: &TLS{}},
+		},
+	}
+	for _, c := range cases {
+		dynamicConfigs := configs{
+			"config": &types.Configuration{
+				Frontends: map[string]*types.Frontend{
+					"frontend": {
+						EntryPoints: []string{"http"},
+						Backend:     "backend",
+						Routes:      map[string]types.Route{"route": {Rule: "PathPrefix:/"}},
+						Redirect:    c.redirect,
+					},
+				},
+				Backends: map[string]*types.Backend{
+					"backend": {
+						Servers: map[string]types.Server{
+							"server": {
+								URL: appServer.URL,
+							},
+						},
+						LoadBalancer: &types.LoadBalancer{
+							Method: "Wrr",
+						},
+					},
+				},
+			},
+		}
+
+		srv := NewServer(globalConfig)
+		serverEntryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+		if err != nil {
+			t.Fatalf("%s: got error: %s", c.desc, err)
+		}
+
+		recorder := httptest.NewRecorder()
+		req := testhelpers.MustNewRequest(http.MethodGet, c.url, nil)
+		req.RemoteAddr = c.remoteAddr
+		if len(c.forwardedProto) > 0 {
+			req.Header.Set("X-Forwarded-Proto", c.forwardedProto)
+		}
+		serverEntryPoints["http"].httpRouter.ServeHTTP(recorder, req)
+		if recorder.Code != c.expectedStatus {
+			t.Errorf("%s: expected status %d, got %d", c.desc, c.expectedStatus, recorder.Code)
+		}
+		if location := recorder.Header().Get("Location"); location != c.expectedLocation {
+			t.Errorf("%s: expected location %s, got %s", c.desc, c.expectedLocation, location)
+		}
+	}
+}
+
+func TestServerLoadConfigFrontendRedirectUnknownEntryPoint(t *testing.T) {
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{Address: ":80"},
+		},
+	}
+	dynamicConfigs := configs{
+		"config": &types.Configuration{
+			Frontends: map[string]*types.Frontend{
+				"frontend": {
+					EntryPoints: []string{"http"},
+					Backend:     "backend",
+					Redirect:    &types.Redirect{EntryPoint: "https"},
+				},
+			},
+			Backends: map[string]*types.Backend{
+				"backend": {
+					Servers: map[string]types.Server{
+						"server": {
+							URL: "http://localhost",
+						},
+					},
+					LoadBalancer: &types.LoadBalancer{
+						Method: "Wrr",
+					},
+				},
+			},
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	serverEntryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	if err != nil {
+		t.Fatalf("got error: %s", err)
+	}
+	if serverEntryPoints["http"].httpRouter.GetHandler().Get(frontendRef{Provider: "config", Frontend: "frontend"}.routeName()) != nil {
+		t.Errorf("expected frontend redirecting to an unknown entrypoint to be skipped")
+	}
+}
+
+func TestServerLoadConfigErrorPages(t *testing.T) {
+	appServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer appServer.Close()
+	errorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("error page " + r.URL.Path))
+	}))
+	defer errorServer.Close()
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{DefaultBackend: "error"},
+		},
+	}
+	dynamicConfigs := configs{
+		"config": &types.Configuration{
+			Frontends: map[string]*types.Frontend{
+				"frontend": {
+					EntryPoints: []string{"http"},
+					Backend:     "backend",
+					Routes:      map[string]types.Route{"route": {Rule: "Host:foo.com"}},
+					Errors: map[string]*types.ErrorPage{
+						"unavailable": {
+							Status:  []string{"500-599"},
+							Backend: "error",
+							Query:   "/{status}.html",
+						},
+					},
+				},
+			},
+			Backends: map[string]*types.Backend{
+				"backend": {
+					Servers: map[string]types.Server{
+						"server": {
+							URL: appServer.URL,
+						},
+					},
+					LoadBalancer: &types.LoadBalancer{
+						Method: "Wrr",
+					},
+				},
+				"error": {
+					Servers: map[string]types.Server{
+						"server": {
+							URL: errorServer.URL,
+						},
+					},
+					LoadBalancer: &types.LoadBalancer{
+						Method: "Wrr",
+					},
+				},
+			},
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	serverEntryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	if err != nil {
+		t.Fatalf("got error: %s", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	serverEntryPoints["http"].httpRouter.ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodGet, "http://foo.com/bar", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+	if body := recorder.Body.String(); body != "error page /503.html" {
+		t.Errorf("expected error page body, got %q", body)
+	}
+
+	recorder = httptest.NewRecorder()
+	req := testhelpers.MustNewRequest(http.MethodGet, "http://unknown.com/bar", nil)
+	req.RequestURI = "/bar"
+	serverEntryPoints["http"].httpRouter.ServeHTTP(recorder, req)
+	if body := recorder.Body.String(); body != "error page /bar" {
+		t.Errorf("expected unmatched request to be served by the default backend, got %q", body)
+	}
+}
+
+func TestServerLoadConfigErrorPagesBackend(t *testing.T) {
+	appServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer appServer.Close()
+	errorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("error page for " + r.Host))
+	}))
+	defer errorServer.Close()
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{DefaultBackend: "error"},
+		},
+		HealthCheck: &HealthCheckConfig{Interval: flaeg.Duration(5 * time.Second)},
+	}
+	dynamicConfigs := configs{
+		"config": &types.Configuration{
+			Frontends: map[string]*types.Frontend{
+				"frontend": {
+					EntryPoints:    []string{"http"},
+					Backend:        "backend",
+					PassHostHeader: true,
+					Routes:         map[string]types.Route{"route": {Rule: "Host:foo.com"}},
+					Errors: map[string]*types.ErrorPage{
+						"unavailable": {
+							Status:  []string{"500-599"},
+							Backend: "error",
+							Query:   "/{status}.html",
+						},
+					},
+				},
+			},
+			Backends: map[string]*types.Backend{
+				"backend": {
+					Servers: map[string]types.Server{
+						"server": {
+							URL: appServer.URL,
+						},
+					},
+					LoadBalancer: &types.LoadBalancer{
+						Method: "Wrr",
+					},
+				},
+				"error": {
+					Servers: map[string]types.Server{
+						"server": {
+							URL: errorServer.URL,
+						},
+					},
+					LoadBalancer: &types.LoadBalancer{
+						Method: "Wrr",
+					},
+					HealthCheck: &types.HealthCheck{
+						Path: "/health",
+					},
+				},
+			},
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	serverEntryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	if err != nil {
+		t.Fatalf("got error: %s", err)
+	}
+
+	for _, backendID := range []string{"frontend/errors/unavailable", "http/defaultBackend"} {
+		if _, ok := healthcheck.GetHealthCheck().Backends[backendID]; !ok {
+			t.Errorf("expected a health check for %s", backendID)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	serverEntryPoints["http"].httpRouter.ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodGet, "http://foo.com/bar", nil))
+	if body := recorder.Body.String(); body != "error page for foo.com" {
+		t.Errorf("expected the error page to be requested with the frontend host, got %q", body)
+	}
+}
+
+func TestServerLoadConfigRateLimitReload(t *testing.T) {
+	appServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer appServer.Close()
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+	newConfigs := func(average int64) configs {
+		return configs{
+			"config": &types.Configuration{
+				Frontends: map[string]*types.Frontend{
+					"frontend": {
+						EntryPoints: []string{"http"},
+						Backend:     "backend",
+						RateLimit: &types.RateLimit{
+							RateSet: map[string]*types.Rate{
+								"rate": {Period: "1h", Average: average, Burst: 1},
+							},
+						},
+					},
+				},
+				Backends: map[string]*types.Backend{
+					"backend": {
+						Servers: map[string]types.Server{
+							"server": {
+								URL: appServer.URL,
+							},
+						},
+						LoadBalancer: &types.LoadBalancer{
+							Method: "Wrr",
+						},
+					},
+				},
+			},
+		}
+	}
+	srv := NewServer(globalConfig)
+	serve := func(dynamicConfigs configs) int {
+		serverEntryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+		if err != nil {
+			t.Fatalf("got error: %s", err)
+		}
+		recorder := httptest.NewRecorder()
+		req := testhelpers.MustNewRequest(http.MethodGet, "http://foo.com/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		serverEntryPoints["http"].httpRouter.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	if code := serve(newConfigs(1)); code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, code)
+	}
+	// the buckets are kept by the reloads which don't change the rate limit
+	if code := serve(newConfigs(1)); code != http.StatusTooManyRequests {
+		t.Errorf("expected status %d after a reload, got %d", http.StatusTooManyRequests, code)
+	}
+	if code := serve(newConfigs(2)); code != http.StatusOK {
+		t.Errorf("expected status %d after a rate limit change, got %d", http.StatusOK, code)
+	}
+}
+
+func TestServerLoadConfigSplit(t *testing.T) {
+	newAppServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+	}
+	stableServer := newAppServer("stable")
+	defer stableServer.Close()
+	canaryServer := newAppServer("canary")
+	defer canaryServer.Close()
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+	newConfigs := func(canaryWeight int) configs {
+		return configs{
+			"config": &types.Configuration{
+				Frontends: map[string]*types.Frontend{
+					"frontend": {
+						EntryPoints: []string{"http"},
+						Routes:      map[string]types.Route{"route": {Rule: "Host:foo.com"}},
+						Split: &types.Split{
+							Backends: map[string]*types.WeightedBackend{
+								"stable": {Backend: "v1", Weight: 3},
+								"canary": {Backend: "v2", Weight: canaryWeight},
+							},
+						},
+					},
+				},
+				Backends: map[string]*types.Backend{
+					"v1": {
+						Servers:      map[string]types.Server{"server": {URL: stableServer.URL}},
+						LoadBalancer: &types.LoadBalancer{Method: "Wrr"},
+					},
+					"v2": {
+						Servers:      map[string]types.Server{"server": {URL: canaryServer.URL}},
+						LoadBalancer: &types.LoadBalancer{Method: "Wrr"},
+					},
+				},
+			},
+		}
+	}
+	countResponses := func(serverEntryPoints map[string]*serverEntryPoint) map[string]int {
+		counts := map[string]int{}
+		for i := 0; i < 8; i++ {
+			recorder := httptest.NewRecorder()
+			serverEntryPoints["http"].httpRouter.ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodGet, "http://foo.com/", nil))
+			counts[recorder.Body.String()]++
+		}
+		return counts
+	}
+
+	srv := NewServer(globalConfig)
+	dynamicConfigs := newConfigs(1)
+	serverEntryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	if err != nil {
+		t.Fatalf("got error: %s", err)
+	}
+	srv.currentConfigurations.Set(dynamicConfigs)
+	if counts := countResponses(serverEntryPoints); !reflect.DeepEqual(counts, map[string]int{"stable": 6, "canary": 2}) {
+		t.Errorf("expected the requests to be split 3 to 1, got %v", counts)
+	}
+
+	// the weights changed at runtime are kept until the split is reconfigured
+	if err := srv.frontendSplit("config", "frontend").SetWeights(map[string]int{"stable": 1}); err != nil {
+		t.Fatalf("got error: %s", err)
+	}
+	serverEntryPoints, err = srv.loadConfig(dynamicConfigs, globalConfig)
+	if err != nil {
+		t.Fatalf("got error: %s", err)
+	}
+	if counts := countResponses(serverEntryPoints); !reflect.DeepEqual(counts, map[string]int{"stable": 4, "canary": 4}) {
+		t.Errorf("expected the requests to be split evenly, got %v", counts)
+	}
+
+	serverEntryPoints, err = srv.loadConfig(newConfigs(0), globalConfig)
+	if err != nil {
+		t.Fatalf("got error: %s", err)
+	}
+	if counts := countResponses(serverEntryPoints); !reflect.DeepEqual(counts, map[string]int{"stable": 8}) {
+		t.Errorf("expected the requests to be sent to the stable backend, got %v", counts)
+	}
+}
+
 func TestConfigureBackends(t *testing.T) {
 	validMethod := "Drr"
 	defaultMethod := "wrr"
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/codegangsta/negroni"
+	"github.com/containous/traefik/types"
+)
+
+// defaultProfile returns the TLS options of the entry point, which apply to the
+// server names not mapped to a TLS profile
+func (tlsOption *TLS) defaultProfile() *TLSProfile {
+	return &TLSProfile{
+		MinVersion:     tlsOption.MinVersion,
+		CipherSuites:   tlsOption.CipherSuites,
+		ClientCAFiles:  tlsOption.ClientCAFiles,
+		ClientAuth:     tlsOption.ClientAuth,
+		ClientCRLFiles: tlsOption.ClientCRLFiles,
+	}
+}
+
+// configureCipherSuites sets the minimum TLS version and the cipher suites of
+// the TLS profile
+func configureCipherSuites(config *tls.Config, profile *TLSProfile) error {
+	config.PreferServerCipherSuites = false
+	config.MinVersion = 0
+	config.CipherSuites = nil
+	//Set the minimum TLS version if set in the config TOML
+	if minConst, exists := minVersion[profile.MinVersion]; exists {
+		config.PreferServerCipherSuites = true
+		config.MinVersion = minConst
+	}
+	//Set the list of CipherSuites if set in the config TOML
+	if profile.CipherSuites != nil {
+		//if our list of CipherSuites is defined in the entrypoint config, we can re-initilize the suites list as empty
+		config.CipherSuites = make([]uint16, 0)
+		for _, cipher := range profile.CipherSuites {
+			if cipherConst, exists := cipherSuites[cipher]; exists {
+				config.CipherSuites = append(config.CipherSuites, cipherConst)
+			} else {
+				//CipherSuite listed in the toml does not exist in our listed
+				return errors.New("Invalid CipherSuite: " + cipher)
+			}
+		}
+	}
+	return nil
+}
+
+// serverNameConfig identifies the TLS configuration of the connections to a
+// server name
+type serverNameConfig struct {
+	profile    string
+	clientAuth bool
+}
+
+// serverNameConfig returns the TLS profile of the server name, and whether
+// the client authentication applies to it
+func (tlsOption *TLS) serverNameConfig(serverName string) serverNameConfig {
+	return serverNameConfig{
+		profile:    tlsOption.hostProfile(serverName),
+		clientAuth: len(tlsOption.ClientAuthHosts) == 0 || matchDomains(tlsOption.ClientAuthHosts, serverName),
+	}
+}
+
+// hostProfile returns the TLS profile of the server name, preferring the exact
+// matches to the wildcards, or an empty string for the options of the entry
+// point
+func (tlsOption *TLS) hostProfile(serverName string) string {
+	serverName = types.CanonicalDomain(serverName)
+	if len(serverName) == 0 {
+		return ""
+	}
+	wildcard := ""
+	if dot := strings.Index(serverName, "."); dot > 0 {
+		wildcard = "*" + serverName[dot:]
+	}
+	profile := ""
+	for host, hostProfile := range tlsOption.HostProfiles {
+		switch types.CanonicalDomain(host) {
+		case serverName:
+			return hostProfile
+		case wildcard:
+			profile = hostProfile
+		}
+	}
+	return profile
+}
+
+// selectsServerNameConfigs returns whether the TLS configuration of the
+// connections depends on their server name
+func (tlsOption *TLS) selectsServerNameConfigs() bool {
+	return len(tlsOption.HostProfiles) > 0 || len(tlsOption.ClientAuthHosts) > 0
+}
+
+// serverNameConfigs builds the TLS configurations of the profiles from the
+// configuration of the entry point, with and without client authentication
+func serverNameConfigs(config *tls.Config, tlsOption *TLS) (map[serverNameConfig]*tls.Config, error) {
+	for host, profile := range tlsOption.HostProfiles {
+		if _, ok := tlsOption.Profiles[profile]; !ok {
+			return nil, fmt.Errorf("unknown TLS profile %s for host %s", profile, host)
+		}
+	}
+	profiles := map[string]*TLSProfile{"": tlsOption.defaultProfile()}
+	for name, profile := range tlsOption.Profiles {
+		if len(name) == 0 || profile == nil {
+			return nil, fmt.Errorf("invalid TLS profile %q", name)
+		}
+		profiles[name] = profile
+	}
+
+	profileConfigs := map[serverNameConfig]*tls.Config{}
+	for name, profile := range profiles {
+		profileConfig := config
+		if len(name) > 0 {
+			profileConfig = config.Clone()
+			profileConfig.GetConfigForClient = nil
+			if err := configureClientAuth(profileConfig, profile); err != nil {
+				return nil, fmt.Errorf("TLS profile %s: %v", name, err)
+			}
+			if err := configureCipherSuites(profileConfig, profile); err != nil {
+				return nil, fmt.Errorf("TLS profile %s: %v", name, err)
+			}
+		}
+		profileConfigs[serverNameConfig{profile: name, clientAuth: true}] = profileConfig
+
+		noClientAuthConfig := profileConfig.Clone()
+		noClientAuthConfig.GetConfigForClient = nil
+		noClientAuthConfig.ClientAuth = tls.NoClientCert
+		profileConfigs[serverNameConfig{profile: name, clientAuth: false}] = noClientAuthConfig
+	}
+	return profileConfigs, nil
+}
+
+// getConfigForClient returns the TLS configuration of the server name of the
+// connections, or nil for the configuration of the entry point
+func getConfigForClient(tlsOption *TLS, profileConfigs map[serverNameConfig]*tls.Config) func(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		key := tlsOption.serverNameConfig(hello.ServerName)
+		if key == (serverNameConfig{clientAuth: true}) {
+			return nil, nil
+		}
+		return profileConfigs[key], nil
+	}
+}
+
+// serverNameConfigHandler rejects the requests sent on connections whose TLS
+// configuration doesn't apply to their host, so that the TLS profiles and the
+// client authentication can't be bypassed with the Host header.
+func serverNameConfigHandler(tlsOption *TLS) negroni.Handler {
+	return negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if r.TLS != nil {
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = r.Host
+			}
+			if tlsOption.serverNameConfig(host) != tlsOption.serverNameConfig(r.TLS.ServerName) {
+				http.Error(rw, "Misdirected Request", http.StatusMisdirectedRequest)
+				return
+			}
+		}
+		next(rw, r)
+	})
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSProfiles(t *testing.T) {
+	cert, key := generateCertificate(t, "example.com", "*.partner.example.com")
+	tlsOption := &TLS{
+		MinVersion:   "VersionTLS12",
+		Certificates: Certificates{{CertFile: cert, KeyFile: key}},
+		Profiles: map[string]*TLSProfile{
+			"legacy": {MinVersion: "VersionTLS10"},
+			"strict": {
+				MinVersion:   "VersionTLS12",
+				CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"},
+			},
+		},
+		HostProfiles: map[string]string{
+			"partner.example.com":        "legacy",
+			"*.partner.example.com":      "legacy",
+			"secure.partner.example.com": "strict",
+		},
+	}
+	server := &Server{globalConfiguration: GlobalConfiguration{EntryPoints: EntryPoints{"https": &EntryPoint{TLS: tlsOption}}}}
+	config, err := server.createTLSConfig("https", tlsOption, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	require.NotNil(t, config.GetConfigForClient)
+
+	tests := []struct {
+		serverName   string
+		minVersion   uint16
+		cipherSuites []uint16
+		defaults     bool
+	}{
+		{serverName: "example.com", defaults: true},
+		{serverName: "", defaults: true},
+		{serverName: "Partner.example.com", minVersion: tls.VersionTLS10},
+		{serverName: "www.partner.example.com", minVersion: tls.VersionTLS10},
+		{serverName: "secure.partner.example.com", minVersion: tls.VersionTLS12, cipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}},
+	}
+	for _, test := range tests {
+		clientConfig, err := config.GetConfigForClient(&tls.ClientHelloInfo{ServerName: test.serverName})
+		require.NoError(t, err)
+		if test.defaults {
+			assert.Nil(t, clientConfig, test.serverName)
+			continue
+		}
+		require.NotNil(t, clientConfig, test.serverName)
+		assert.Equal(t, test.minVersion, clientConfig.MinVersion, test.serverName)
+		assert.Equal(t, test.cipherSuites, clientConfig.CipherSuites, test.serverName)
+		assert.Len(t, clientConfig.Certificates, 1, test.serverName)
+	}
+
+	// the requests are served with the TLS profile of their host
+	handler := serverNameConfigHandler(tlsOption)
+	for host, expected := range map[string]int{
+		"partner.example.com":        http.StatusOK,
+		"www.partner.example.com":    http.StatusOK,
+		"example.com":                http.StatusMisdirectedRequest,
+		"secure.partner.example.com": http.StatusMisdirectedRequest,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "https://"+host+"/", nil)
+		req.TLS = &tls.ConnectionState{ServerName: "partner.example.com"}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req, func(rw http.ResponseWriter, r *http.Request) {})
+		assert.Equal(t, expected, recorder.Code, host)
+	}
+}
+
+func TestTLSProfilesInvalid(t *testing.T) {
+	cert, key := generateCertificate(t, "example.com")
+	for desc, tlsOption := range map[string]*TLS{
+		"unknown profile": {HostProfiles: map[string]string{"example.com": "unknown"}},
+		"invalid cipher suite": {
+			Profiles:     map[string]*TLSProfile{"legacy": {CipherSuites: []string{"TLS_UNKNOWN"}}},
+			HostProfiles: map[string]string{"example.com": "legacy"},
+		},
+		"invalid client auth": {
+			Profiles:     map[string]*TLSProfile{"clients": {ClientAuth: "requireAndVerify"}},
+			HostProfiles: map[string]string{"example.com": "clients"},
+		},
+	} {
+		tlsOption.Certificates = Certificates{{CertFile: cert, KeyFile: key}}
+		server := &Server{globalConfiguration: GlobalConfiguration{EntryPoints: EntryPoints{"https": &EntryPoint{TLS: tlsOption}}}}
+		_, err := server.createTLSConfig("https", tlsOption, nil)
+		assert.Error(t, err, desc)
+	}
+}
@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"runtime"
+	"strings"
 
 	"github.com/codegangsta/negroni"
 	"github.com/containous/mux"
+	"github.com/containous/traefik/acme"
 	"github.com/containous/traefik/autogen"
 	"github.com/containous/traefik/log"
 	"github.com/containous/traefik/middlewares"
@@ -105,6 +108,9 @@ func (provider *WebProvider) Provide(configurationChan chan<- types.ConfigMessag
 		configuration := new(types.Configuration)
 		body, _ := ioutil.ReadAll(request.Body)
 		err := json.Unmarshal(body, configuration)
+		if err == nil {
+			err = checkHiddenKeys(configuration)
+		}
 		if err == nil {
 			configurationChan <- types.ConfigMessage{ProviderName: "web", Configuration: configuration}
 			provider.getConfigHandler(response, request)
@@ -119,8 +125,14 @@ func (provider *WebProvider) Provide(configurationChan chan<- types.ConfigMessag
 	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/backends/{backend}/servers/{server}").HandlerFunc(provider.getServerHandler)
 	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/frontends").HandlerFunc(provider.getFrontendsHandler)
 	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/frontends/{frontend}").HandlerFunc(provider.getFrontendHandler)
+	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/frontends/{frontend}/split").HandlerFunc(provider.getSplitHandler)
+	systemRouter.Methods("PUT").Path(provider.Path + "api/providers/{provider}/frontends/{frontend}/split").HandlerFunc(provider.putSplitHandler)
 	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/frontends/{frontend}/routes").HandlerFunc(provider.getRoutesHandler)
 	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/frontends/{frontend}/routes/{route}").HandlerFunc(provider.getRouteHandler)
+	systemRouter.Methods("GET").Path(provider.Path + "api/entrypoints/{entrypoint}/match").HandlerFunc(provider.getMatchHandler)
+	systemRouter.Methods("GET").Path(provider.Path + "api/diagnostics").HandlerFunc(provider.getDiagnosticsHandler)
+	systemRouter.Methods("GET").Path(provider.Path + "api/ocsp").HandlerFunc(provider.getOCSPHandler)
+	systemRouter.Methods("GET").Path(provider.Path + "api/acme/domains").HandlerFunc(provider.getACMEDomainsHandler)
 
 	// Expose dashboard
 	systemRouter.Methods("GET").Path(provider.Path).HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
@@ -285,6 +297,44 @@ func (provider *WebProvider) getFrontendHandler(response http.ResponseWriter, re
 	http.NotFound(response, request)
 }
 
+func (provider *WebProvider) getSplitHandler(response http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	split := provider.server.frontendSplit(vars["provider"], vars["frontend"])
+	if split == nil {
+		http.NotFound(response, request)
+		return
+	}
+	templatesRenderer.JSON(response, http.StatusOK, split.Weights())
+}
+
+// putSplitHandler changes the weights of the split backends of a frontend,
+// until its split is reconfigured
+func (provider *WebProvider) putSplitHandler(response http.ResponseWriter, request *http.Request) {
+	if provider.ReadOnly {
+		response.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(response, "REST API is in read-only mode")
+		return
+	}
+	vars := mux.Vars(request)
+	split := provider.server.frontendSplit(vars["provider"], vars["frontend"])
+	if split == nil {
+		http.NotFound(response, request)
+		return
+	}
+
+	weights := map[string]int{}
+	if err := json.NewDecoder(request.Body).Decode(&weights); err != nil {
+		http.Error(response, fmt.Sprintf("Invalid weights: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := split.SetWeights(weights); err != nil {
+		http.Error(response, fmt.Sprintf("Invalid weights: %v", err), http.StatusBadRequest)
+		return
+	}
+	log.Infof("Weights of frontend %s changed to %v", vars["frontend"], split.Weights())
+	templatesRenderer.JSON(response, http.StatusOK, split.Weights())
+}
+
 func (provider *WebProvider) getRoutesHandler(response http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 	providerID := vars["provider"]
@@ -317,6 +367,85 @@ func (provider *WebProvider) getRouteHandler(response http.ResponseWriter, reque
 	http.NotFound(response, request)
 }
 
+func (provider *WebProvider) getDiagnosticsHandler(response http.ResponseWriter, request *http.Request) {
+	templatesRenderer.JSON(response, http.StatusOK, provider.server.diagnostics.Get())
+}
+
+// getOCSPHandler returns the status of the OCSP staples of the served certificates
+func (provider *WebProvider) getOCSPHandler(response http.ResponseWriter, request *http.Request) {
+	templatesRenderer.JSON(response, http.StatusOK, provider.server.ocspStapler.statuses())
+}
+
+// getACMEDomainsHandler returns the domains whose ACME certificates are being
+// obtained or failed
+func (provider *WebProvider) getACMEDomainsHandler(response http.ResponseWriter, request *http.Request) {
+	if provider.server.globalConfiguration.ACME == nil {
+		templatesRenderer.JSON(response, http.StatusOK, []*acme.DomainStatus{})
+		return
+	}
+	templatesRenderer.JSON(response, http.StatusOK, provider.server.globalConfiguration.ACME.DomainsStatuses())
+}
+
+// checkHiddenKeys rejects the certificates whose private key was hidden by the
+// API: a configuration read from the API must be sent back with the keys.
+func checkHiddenKeys(configuration *types.Configuration) error {
+	for i, tlsConfiguration := range configuration.TLS {
+		if tlsConfiguration != nil && tlsConfiguration.KeyFile == types.HiddenKeyFile {
+			return fmt.Errorf("the private key of tls[%d] is hidden, it must be given again", i)
+		}
+	}
+	return nil
+}
+
+// getMatchHandler explains which frontend of an entrypoint handles the request
+// described by the host, path, method, header and clientIP query parameters.
+func (provider *WebProvider) getMatchHandler(response http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	entryPointID := vars["entrypoint"]
+	if _, ok := provider.server.serverEntryPoints[entryPointID]; !ok {
+		http.NotFound(response, request)
+		return
+	}
+
+	query := request.URL.Query()
+	path := query.Get("path")
+	if len(path) == 0 {
+		path = "/"
+	}
+	target, err := url.ParseRequestURI(path)
+	if err != nil {
+		http.Error(response, fmt.Sprintf("Invalid path %s: %v", path, err), http.StatusBadRequest)
+		return
+	}
+	method := query.Get("method")
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+	req := &http.Request{
+		Method:     strings.ToUpper(method),
+		URL:        target,
+		RequestURI: target.RequestURI(),
+		Host:       query.Get("host"),
+		Header:     make(http.Header),
+		RemoteAddr: query.Get("clientIP"),
+	}
+	for _, header := range query["header"] {
+		kv := strings.SplitN(header, ":", 2)
+		if len(kv) != 2 {
+			http.Error(response, "Invalid header "+header+", expected Name:Value", http.StatusBadRequest)
+			return
+		}
+		req.Header.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+
+	report, err := provider.server.explainMatch(entryPointID, req)
+	if err != nil {
+		http.Error(response, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	templatesRenderer.JSON(response, http.StatusOK, report)
+}
+
 func expvarHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	fmt.Fprint(w, "{\n")
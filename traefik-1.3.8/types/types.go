@@ -1,12 +1,18 @@
 package types
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"strconv"
 	"strings"
 
+	"github.com/containous/traefik/log"
 	"github.com/docker/libkv/store"
 	"github.com/ryanuber/go-glob"
 )
@@ -18,6 +24,7 @@ type Backend struct {
 	LoadBalancer   *LoadBalancer     `json:"loadBalancer,omitempty"`
 	MaxConn        *MaxConn          `json:"maxConn,omitempty"`
 	HealthCheck    *HealthCheck      `json:"healthCheck,omitempty"`
+	Buffering      *Buffering        `json:"buffering,omitempty"`
 }
 
 // MaxConn holds maximum connection configuration
@@ -26,6 +33,18 @@ type MaxConn struct {
 	ExtractorFunc string `json:"extractorFunc,omitempty"`
 }
 
+// Buffering holds the buffering configuration of a backend: the request bodies
+// are read before being forwarded, in memory up to MemRequestBodyBytes and in a
+// temporary file beyond, and the responses are buffered when their size is
+// limited or when the requests are retried on RetryExpression. A zero maximum
+// means no limit.
+type Buffering struct {
+	MaxRequestBodyBytes  int64  `json:"maxRequestBodyBytes,omitempty"`
+	MemRequestBodyBytes  int64  `json:"memRequestBodyBytes,omitempty"`
+	MaxResponseBodyBytes int64  `json:"maxResponseBodyBytes,omitempty"`
+	RetryExpression      string `json:"retryExpression,omitempty"`
+}
+
 // LoadBalancer holds load balancing configuration.
 type LoadBalancer struct {
 	Method string `json:"method,omitempty"`
@@ -56,12 +75,158 @@ type Route struct {
 
 // Frontend holds frontend configuration.
 type Frontend struct {
-	EntryPoints    []string         `json:"entryPoints,omitempty"`
-	Backend        string           `json:"backend,omitempty"`
-	Routes         map[string]Route `json:"routes,omitempty"`
-	PassHostHeader bool             `json:"passHostHeader,omitempty"`
-	Priority       int              `json:"priority"`
-	BasicAuth      []string         `json:"basicAuth"`
+	EntryPoints       []string              `json:"entryPoints,omitempty"`
+	Backend           string                `json:"backend,omitempty"`
+	Routes            map[string]Route      `json:"routes,omitempty"`
+	PassHostHeader    bool                  `json:"passHostHeader,omitempty"`
+	Priority          int                   `json:"priority"`
+	BasicAuth         []string              `json:"basicAuth"` // Deprecated: use Auth.Basic
+	Auth              *Auth                 `json:"auth,omitempty"`
+	Redirect          *Redirect             `json:"redirect,omitempty"`
+	Errors            map[string]*ErrorPage `json:"errors,omitempty"`
+	Headers           *Headers              `json:"headers,omitempty"`
+	RateLimit         *RateLimit            `json:"rateLimit,omitempty"`
+	Mirror            *Mirror               `json:"mirror,omitempty"`
+	Split             *Split                `json:"split,omitempty"`
+	PassTLSClientCert *PassTLSClientCert    `json:"passTLSClientCert,omitempty"`
+}
+
+// PassTLSClientCert sets the TLS client certificate of the requests, or some of
+// its fields, in the request headers with these names: the PEM certificate,
+// URL-escaped, in PEM, its subject common name in SubjectCN, its comma separated
+// subject alternative names in SANs, its hexadecimal serial number in
+// SerialNumber, its issuer in Issuer, and the start and end of its validity,
+// in the RFC 3339 format, in NotBefore and NotAfter. These headers are removed
+// from the requests of the clients.
+type PassTLSClientCert struct {
+	PEM          string `json:"pem,omitempty"`
+	SubjectCN    string `json:"subjectCN,omitempty"`
+	SANs         string `json:"sans,omitempty"`
+	SerialNumber string `json:"serialNumber,omitempty"`
+	Issuer       string `json:"issuer,omitempty"`
+	NotBefore    string `json:"notBefore,omitempty"`
+	NotAfter     string `json:"notAfter,omitempty"`
+}
+
+// Split holds the backends between which a frontend splits its requests, in
+// proportion of their weights, instead of sending them to its Backend. When
+// Sticky is set, the clients keep being sent to the backend of their first
+// request.
+type Split struct {
+	Backends map[string]*WeightedBackend `json:"backends,omitempty"`
+	Sticky   bool                        `json:"sticky,omitempty"`
+}
+
+// WeightedBackend holds a backend of a split and its weight
+type WeightedBackend struct {
+	Backend string `json:"backend,omitempty"`
+	Weight  int    `json:"weight"`
+}
+
+// Mirror holds the backends receiving a copy of a percentage of the requests
+// of a frontend, whose responses are discarded. The requests whose body is
+// larger than MaxBodySize bytes (1MB by default) are not mirrored.
+type Mirror struct {
+	Backends    map[string]*MirrorBackend `json:"backends,omitempty"`
+	MaxBodySize int64                     `json:"maxBodySize,omitempty"`
+}
+
+// MirrorBackend holds a mirror backend, receiving Percent percent of the
+// requests
+type MirrorBackend struct {
+	Backend string `json:"backend,omitempty"`
+	Percent int    `json:"percent,omitempty"`
+}
+
+// RateLimit holds the rate limiting configuration of a frontend: its requests
+// are grouped by the source extracted from them (client.ip, request.host or
+// request.header.<name>), and each source is limited by every rate set.
+type RateLimit struct {
+	RateSet       map[string]*Rate `json:"rateset,omitempty"`
+	ExtractorFunc string           `json:"extractorFunc,omitempty"`
+}
+
+// Rate holds a rate limit: Average requests per Period, with bursts of up to
+// Burst requests
+type Rate struct {
+	Period  string `json:"period,omitempty"`
+	Average int64  `json:"average,omitempty"`
+	Burst   int64  `json:"burst,omitempty"`
+}
+
+// Headers holds the headers set by a frontend on the requests forwarded to its
+// backend, and on the responses sent back to the client. An empty value
+// removes the header.
+// The security options check the host of the requests, redirect them to HTTPS,
+// and add the security headers (HSTS, frame options...) to the responses. They
+// are bypassed, except for the response headers, when IsDevelopment is set.
+type Headers struct {
+	CustomRequestHeaders  map[string]string `json:"customRequestHeaders,omitempty"`
+	CustomResponseHeaders map[string]string `json:"customResponseHeaders,omitempty"`
+
+	AllowedHosts            []string          `json:"allowedHosts,omitempty"`
+	SSLRedirect             bool              `json:"sslRedirect,omitempty"`
+	SSLTemporaryRedirect    bool              `json:"sslTemporaryRedirect,omitempty"`
+	SSLHost                 string            `json:"sslHost,omitempty"`
+	SSLProxyHeaders         map[string]string `json:"sslProxyHeaders,omitempty"`
+	STSSeconds              int64             `json:"stsSeconds,omitempty"`
+	STSIncludeSubdomains    bool              `json:"stsIncludeSubdomains,omitempty"`
+	STSPreload              bool              `json:"stsPreload,omitempty"`
+	ForceSTSHeader          bool              `json:"forceSTSHeader,omitempty"`
+	FrameDeny               bool              `json:"frameDeny,omitempty"`
+	CustomFrameOptionsValue string            `json:"customFrameOptionsValue,omitempty"`
+	ContentTypeNosniff      bool              `json:"contentTypeNosniff,omitempty"`
+	BrowserXSSFilter        bool              `json:"browserXSSFilter,omitempty"`
+	ContentSecurityPolicy   string            `json:"contentSecurityPolicy,omitempty"`
+	ReferrerPolicy          string            `json:"referrerPolicy,omitempty"`
+	IsDevelopment           bool              `json:"isDevelopment,omitempty"`
+}
+
+// HasCustomHeadersDefined checks if any of the custom headers is set
+func (h *Headers) HasCustomHeadersDefined() bool {
+	return h != nil && (len(h.CustomRequestHeaders) != 0 || len(h.CustomResponseHeaders) != 0)
+}
+
+// HasSecureHeadersDefined checks if any of the security options is set
+func (h *Headers) HasSecureHeadersDefined() bool {
+	return h != nil && (len(h.AllowedHosts) != 0 ||
+		h.SSLRedirect ||
+		h.SSLTemporaryRedirect ||
+		len(h.SSLHost) != 0 ||
+		len(h.SSLProxyHeaders) != 0 ||
+		h.STSSeconds != 0 ||
+		h.STSIncludeSubdomains ||
+		h.STSPreload ||
+		h.ForceSTSHeader ||
+		h.FrameDeny ||
+		len(h.CustomFrameOptionsValue) != 0 ||
+		h.ContentTypeNosniff ||
+		h.BrowserXSSFilter ||
+		len(h.ContentSecurityPolicy) != 0 ||
+		len(h.ReferrerPolicy) != 0 ||
+		h.IsDevelopment)
+}
+
+// ErrorPage holds the custom error page of a frontend: the responses whose
+// status is in one of the ranges (e.g. 500-599, or 404) are replaced with the
+// page served by the backend at the query (e.g. /{status}.html).
+type ErrorPage struct {
+	Status  []string `json:"status,omitempty"`
+	Backend string   `json:"backend,omitempty"`
+	Query   string   `json:"query,omitempty"`
+}
+
+// Redirect holds the redirection of a frontend, either to another entrypoint,
+// or to the replacement of a regex matching the request URL.
+// When redirecting to an entrypoint, the path and the query of the request are
+// preserved unless DropPath or DropQuery is set.
+type Redirect struct {
+	EntryPoint  string `json:"entryPoint,omitempty"`
+	Regex       string `json:"regex,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	Permanent   bool   `json:"permanent,omitempty"`
+	DropPath    bool   `json:"dropPath,omitempty"`
+	DropQuery   bool   `json:"dropQuery,omitempty"`
 }
 
 // LoadBalancerMethod holds the method of load balancing to use.
@@ -97,6 +262,30 @@ func NewLoadBalancerMethod(loadBalancer *LoadBalancer) (LoadBalancerMethod, erro
 type Configuration struct {
 	Backends  map[string]*Backend  `json:"backends,omitempty"`
 	Frontends map[string]*Frontend `json:"frontends,omitempty"`
+	TLS       []*TLSConfiguration  `json:"tls,omitempty"`
+}
+
+// TLSConfiguration holds a certificate/key pair delivered by a provider, and the
+// entrypoints serving it, all the TLS entrypoints by default.
+// CertFile and KeyFile could be either a file path, or the file content itself
+type TLSConfiguration struct {
+	EntryPoints []string `json:"entryPoints,omitempty"`
+	CertFile    string   `json:"certFile,omitempty"`
+	KeyFile     string   `json:"keyFile,omitempty"`
+}
+
+// HiddenKeyFile replaces the private keys given as contents in the JSON
+// representation of the TLS configurations
+const HiddenKeyFile = "<hidden>"
+
+// MarshalJSON hides the private keys given as contents, so that they are not
+// exposed by the API nor the logs
+func (t TLSConfiguration) MarshalJSON() ([]byte, error) {
+	type tlsConfiguration TLSConfiguration
+	if strings.Contains(t.KeyFile, "PRIVATE KEY") {
+		t.KeyFile = HiddenKeyFile
+	}
+	return json.Marshal(tlsConfiguration(t))
 }
 
 // ConfigMessage hold configuration information exchanged between parts of traefik.
@@ -229,26 +418,131 @@ type Cluster struct {
 	Store *Store
 }
 
-// Auth holds authentication configuration (BASIC, DIGEST, users)
+// Auth holds authentication configuration (BASIC, DIGEST, users, FORWARD, JWT)
 type Auth struct {
-	Basic       *Basic
-	Digest      *Digest
-	HeaderField string
+	Basic       *Basic   `json:"basic,omitempty"`
+	Digest      *Digest  `json:"digest,omitempty"`
+	Forward     *Forward `json:"forward,omitempty"`
+	JWT         *JWT     `json:"jwt,omitempty"`
+	HeaderField string   `json:"headerField,omitempty"`
 }
 
 // Users authentication users
 type Users []string
 
-// Basic HTTP basic authentication
+// Basic HTTP basic authentication, the users are checked in the realm Realm
+// ("traefik" by default). RemoveHeader removes the Authorization header from
+// the authenticated requests before they are forwarded.
 type Basic struct {
-	Users     `mapstructure:","`
-	UsersFile string
+	Users        `mapstructure:"," json:"users,omitempty"`
+	UsersFile    string `json:"usersFile,omitempty"`
+	Realm        string `json:"realm,omitempty"`
+	RemoveHeader bool   `json:"removeHeader,omitempty"`
 }
 
-// Digest HTTP authentication
+// Digest HTTP authentication, in the realm Realm ("traefik" by default)
 type Digest struct {
-	Users     `mapstructure:","`
-	UsersFile string
+	Users        `mapstructure:"," json:"users,omitempty"`
+	UsersFile    string `json:"usersFile,omitempty"`
+	Realm        string `json:"realm,omitempty"`
+	RemoveHeader bool   `json:"removeHeader,omitempty"`
+}
+
+// Forward authentication, delegated to the service at Address, which accepts
+// the requests with a 2xx response. AuthResponseHeaders are copied from its
+// response to the forwarded request. When TrustForwardHeader is set, the
+// X-Forwarded-* headers of the client are sent to the service. Timeout bounds
+// the requests to the service (30s by default).
+type Forward struct {
+	Address             string     `description:"Authentication server address" json:"address,omitempty"`
+	TLS                 *ClientTLS `description:"Enable TLS support" json:"tls,omitempty"`
+	TrustForwardHeader  bool       `description:"Trust X-Forwarded-* headers" json:"trustForwardHeader,omitempty"`
+	AuthResponseHeaders []string   `description:"Headers to be forwarded from auth response" json:"authResponseHeaders,omitempty"`
+	Timeout             string     `description:"Timeout of the requests to the authentication server" json:"timeout,omitempty"`
+}
+
+// JWT authentication of the bearer tokens of the requests, whose signature is
+// verified with the PEM public Keys, which can be either paths or contents,
+// or with the keys of the JWKS document at JWKSURL or in JWKSFile, reloaded
+// every JWKSRefreshInterval (15m by default). When they are set, the issuer
+// and one of the audiences of the tokens must be in Issuers and Audiences,
+// and the tokens must have the RequiredClaims. ClaimHeaders sets the claims
+// of the tokens in request headers, by claim name.
+type JWT struct {
+	Keys                []string          `description:"PEM public keys" json:"keys,omitempty"`
+	JWKSURL             string            `description:"JWKS document URL" json:"jwksURL,omitempty"`
+	JWKSFile            string            `description:"JWKS document file" json:"jwksFile,omitempty"`
+	JWKSRefreshInterval string            `description:"JWKS document refresh interval" json:"jwksRefreshInterval,omitempty"`
+	Issuers             []string          `description:"Accepted token issuers" json:"issuers,omitempty"`
+	Audiences           []string          `description:"Accepted token audiences" json:"audiences,omitempty"`
+	RequiredClaims      []string          `description:"Claims required in the tokens" json:"requiredClaims,omitempty"`
+	ClaimHeaders        map[string]string `description:"Request headers set from the token claims" json:"claimHeaders,omitempty"`
+}
+
+// ClientTLS holds TLS specific configurations as client
+// CA, Cert and Key can be either path or file contents
+type ClientTLS struct {
+	CA                 string `description:"TLS CA"`
+	Cert               string `description:"TLS cert"`
+	Key                string `description:"TLS key"`
+	InsecureSkipVerify bool   `description:"TLS insecure skip verify"`
+}
+
+// CreateTLSConfig creates a TLS config from ClientTLS structures
+func (clientTLS *ClientTLS) CreateTLSConfig() (*tls.Config, error) {
+	var err error
+	if clientTLS == nil {
+		log.Warnf("clientTLS is nil")
+		return nil, nil
+	}
+	caPool := x509.NewCertPool()
+	if clientTLS.CA != "" {
+		var ca []byte
+		if _, errCA := os.Stat(clientTLS.CA); errCA == nil {
+			ca, err = ioutil.ReadFile(clientTLS.CA)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to read CA. %s", err)
+			}
+		} else {
+			ca = []byte(clientTLS.CA)
+		}
+		caPool.AppendCertsFromPEM(ca)
+	}
+
+	TLSConfig := &tls.Config{
+		RootCAs:            caPool,
+		InsecureSkipVerify: clientTLS.InsecureSkipVerify,
+	}
+	if clientTLS.Cert == "" && clientTLS.Key == "" {
+		// no client certificate
+		return TLSConfig, nil
+	}
+
+	cert := tls.Certificate{}
+	_, errKeyIsFile := os.Stat(clientTLS.Key)
+
+	if _, errCertIsFile := os.Stat(clientTLS.Cert); errCertIsFile == nil {
+		if errKeyIsFile == nil {
+			cert, err = tls.LoadX509KeyPair(clientTLS.Cert, clientTLS.Key)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to load TLS keypair: %v", err)
+			}
+		} else {
+			return nil, fmt.Errorf("tls cert is a file, but tls key is not")
+		}
+	} else {
+		if errKeyIsFile != nil {
+			cert, err = tls.X509KeyPair([]byte(clientTLS.Cert), []byte(clientTLS.Key))
+			if err != nil {
+				return nil, fmt.Errorf("Failed to load TLS keypair: %v", err)
+
+			}
+		} else {
+			return nil, fmt.Errorf("tls key is a file, but tls cert is not")
+		}
+	}
+	TLSConfig.Certificates = []tls.Certificate{cert}
+	return TLSConfig, nil
 }
 
 // CanonicalDomain returns a lower case domain with trim space
@@ -0,0 +1,103 @@
+package whitelist
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IP holds a list of IP ranges, expressed in CIDR notation or as single addresses
+type IP struct {
+	whitelists []*net.IPNet
+}
+
+// NewIP builds a new IP given a list of CIDR-Strings or single addresses
+func NewIP(whitelistStrings []string) (*IP, error) {
+	if len(whitelistStrings) == 0 {
+		return nil, fmt.Errorf("no whitelists provided")
+	}
+
+	ip := IP{}
+	for _, whitelistString := range whitelistStrings {
+		whitelistString = strings.TrimSpace(whitelistString)
+		if !strings.Contains(whitelistString, "/") {
+			if parsedIP := net.ParseIP(whitelistString); parsedIP != nil {
+				if parsedIP.To4() != nil {
+					whitelistString += "/32"
+				} else {
+					whitelistString += "/128"
+				}
+			}
+		}
+		_, whitelist, err := net.ParseCIDR(whitelistString)
+		if err != nil {
+			return nil, fmt.Errorf("parsing CIDR whitelist %s: %v", whitelistString, err)
+		}
+		ip.whitelists = append(ip.whitelists, whitelist)
+	}
+
+	return &ip, nil
+}
+
+// Contains checks if provided address is in the white list
+func (ip *IP) Contains(addr string) (bool, error) {
+	ipAddr, err := ipFromRemoteAddr(addr)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse address: %s: %s", addr, err)
+	}
+	return ip.ContainsIP(ipAddr), nil
+}
+
+// ContainsIP checks if provided address is in the white list
+func (ip *IP) ContainsIP(addr net.IP) bool {
+	for _, whitelist := range ip.whitelists {
+		if whitelist.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client which sent the request.
+// When the request comes from a trusted address, the X-Forwarded-For header
+// is walked from the right, and the first address which is not trusted is
+// returned. A nil IP trusts no one.
+func (ip *IP) ClientIP(req *http.Request) (net.IP, error) {
+	remoteIP, err := ipFromRemoteAddr(req.RemoteAddr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse address: %s: %s", req.RemoteAddr, err)
+	}
+	if ip == nil || !ip.ContainsIP(remoteIP) {
+		return remoteIP, nil
+	}
+
+	var forwarded []string
+	for _, value := range req.Header["X-Forwarded-For"] {
+		forwarded = append(forwarded, strings.Split(value, ",")...)
+	}
+	clientIP := remoteIP
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		forwardedIP := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if forwardedIP == nil {
+			break
+		}
+		clientIP = forwardedIP
+		if !ip.ContainsIP(forwardedIP) {
+			break
+		}
+	}
+	return clientIP, nil
+}
+
+func ipFromRemoteAddr(addr string) (net.IP, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("can't parse IP from address %s", addr)
+	}
+	return ip, nil
+}
@@ -0,0 +1,125 @@
+package whitelist
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIP(t *testing.T) {
+	cases := []struct {
+		desc       string
+		whitelists []string
+		expectErr  bool
+	}{
+		{desc: "nil whitelist", whitelists: nil, expectErr: true},
+		{desc: "invalid CIDR", whitelists: []string{"foo"}, expectErr: true},
+		{desc: "valid IPv4 CIDR", whitelists: []string{"10.0.0.0/8"}},
+		{desc: "valid IPv6 CIDR", whitelists: []string{"::1/128"}},
+		{desc: "single addresses", whitelists: []string{"10.0.0.1", " ::1 "}},
+	}
+
+	for _, test := range cases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			ip, err := NewIP(test.whitelists)
+			if test.expectErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.NotNil(t, ip)
+			}
+		})
+	}
+}
+
+func TestContains(t *testing.T) {
+	ip, err := NewIP([]string{"10.0.0.0/8", "192.168.1.1", "2a03:4000:6:d080::/64"})
+	require.NoError(t, err)
+
+	cases := []struct {
+		addr     string
+		expected bool
+	}{
+		{addr: "10.1.2.3", expected: true},
+		{addr: "10.1.2.3:1234", expected: true},
+		{addr: "192.168.1.1", expected: true},
+		{addr: "192.168.1.2", expected: false},
+		{addr: "[2a03:4000:6:d080::42]:443", expected: true},
+		{addr: "2a03:4000:7:d080::42", expected: false},
+	}
+
+	for _, test := range cases {
+		contains, err := ip.Contains(test.addr)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, contains, test.addr)
+	}
+
+	_, err = ip.Contains("foo")
+	assert.Error(t, err)
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := NewIP([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	cases := []struct {
+		desc          string
+		trusted       *IP
+		remoteAddr    string
+		xForwardedFor []string
+		expected      string
+	}{
+		{
+			desc:          "no trusted addresses",
+			remoteAddr:    "10.0.0.1:1234",
+			xForwardedFor: []string{"1.2.3.4"},
+			expected:      "10.0.0.1",
+		},
+		{
+			desc:          "untrusted remote address",
+			trusted:       trusted,
+			remoteAddr:    "8.8.8.8:1234",
+			xForwardedFor: []string{"1.2.3.4"},
+			expected:      "8.8.8.8",
+		},
+		{
+			desc:          "trusted remote address",
+			trusted:       trusted,
+			remoteAddr:    "10.0.0.1:1234",
+			xForwardedFor: []string{"1.2.3.4"},
+			expected:      "1.2.3.4",
+		},
+		{
+			desc:          "spoofed forwarded addresses",
+			trusted:       trusted,
+			remoteAddr:    "10.0.0.1:1234",
+			xForwardedFor: []string{"10.0.0.5, 1.2.3.4", "10.0.0.2"},
+			expected:      "1.2.3.4",
+		},
+		{
+			desc:       "trusted remote address without header",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			expected:   "10.0.0.1",
+		},
+	}
+
+	for _, test := range cases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			req, err := http.NewRequest(http.MethodGet, "http://foo.bar", nil)
+			require.NoError(t, err)
+			req.RemoteAddr = test.remoteAddr
+			req.Header["X-Forwarded-For"] = test.xForwardedFor
+
+			clientIP, err := test.trusted.ClientIP(req)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, clientIP.String())
+		})
+	}
+}